@@ -8,6 +8,7 @@ require (
 	github.com/charmbracelet/lipgloss v0.13.0
 	github.com/creack/pty v1.1.23
 	github.com/google/uuid v1.6.0
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/sethvargo/go-diceware v0.4.0
 	golang.org/x/crypto v0.35.0
 	golang.org/x/sync v0.11.0
@@ -24,7 +25,6 @@ require (
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-localereader v0.0.1 // indirect
-	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
 	github.com/muesli/cancelreader v0.2.2 // indirect
 	github.com/muesli/termenv v0.15.3-0.20240618155329-98d742f6907a // indirect
@@ -19,8 +19,6 @@ import (
 	"willofdaedalus/superluminal/internal/utils"
 
 	err1 "willofdaedalus/superluminal/internal/payload/error"
-
-	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -33,27 +31,51 @@ const (
 	clientKickTimeout     = time.Second * 30
 	maxHandleTime         = time.Minute * 1
 	passRegenTimeout      = time.Minute * 5
+	approvalTimeout       = time.Minute * 2
+	resumeGrace           = time.Minute * 2
 	serverShutdownTimeout = time.Minute * 1
+
+	// how many payloads from a client can wait to be handled
+	maxQueuedPayloads = 64
 )
 
 func NewSession(owner string, maxConns uint8) (*Session, error) {
-	clients := make(map[string]*sessionClient, maxConns)
+	return NewCommandSession(owner, maxConns, pipeline.Command{})
+}
 
-	listener, err := net.Listen("tcp", "0.0.0.0:42024")
+// NewCommandSession creates a session that shares cmd instead of the owner's
+// shell. The session ends when cmd does
+func NewCommandSession(owner string, maxConns uint8, cmd pipeline.Command) (*Session, error) {
+	p, err := pipeline.NewCommandPipeline(maxConns, cmd)
 	if err != nil {
 		return nil, err
 	}
 
-	p, err := pipeline.NewPipeline(maxConns)
+	s, err := newSession(owner, maxConns, windowName(cmd), p)
 	if err != nil {
+		p.Close()
 		return nil, err
 	}
 
-	pass, hash, err := genPassAndHash(debugPassCount)
+	return s, nil
+}
+
+// newSession sets up a session around a pipeline that's already been created
+// which becomes its first window
+func newSession(owner string, maxConns uint8, name string, p *pipeline.Pipeline) (*Session, error) {
+	clients := make(map[string]*sessionClient, maxConns)
+
+	listener, err := net.Listen("tcp", "0.0.0.0:42024")
 	if err != nil {
 		return nil, err
 	}
 
+	pass, err := utils.GeneratePassphrase(debugPassCount)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
 	fmt.Println("your pass is ", pass)
 
 	master := createClient(owner, nil, true)
@@ -65,19 +87,30 @@ func NewSession(owner string, maxConns uint8) (*Session, error) {
 		syscall.SIGQUIT,
 	}
 
-	return &Session{
+	s := &Session{
 		Owner:         owner,
 		maxConns:      maxConns + 1,
 		clients:       clients,
 		listener:      listener,
 		pass:          pass,
-		hash:          hash,
-		pipeline:      p,
 		heartbeatTime: heartbeatTimeout,
 		passRegenTime: passRegenTimeout,
 		signals:       signals,
 		tracker:       utils.NewSyncTracker(),
-	}, nil
+		pending:       make(map[string]*pendingClient),
+		needsApproval: true,
+		approvalTime:  approvalTimeout,
+		detached:      make(map[string]*sessionClient),
+		resumeGrace:   resumeGrace,
+		ended:         make(chan struct{}),
+		scrollback:    pipeline.DefaultScrollback,
+		batchWindow:   pipeline.DefaultBatchWindow,
+		frameRate:     pipeline.DefaultFrameRate,
+		compression:   true,
+	}
+	s.addWindowLocked(name, p)
+
+	return s, nil
 }
 
 func (s *Session) Start() error {
@@ -87,16 +120,30 @@ func (s *Session) Start() error {
 	doneChan := make(chan struct{})
 
 	defer func() {
-		s.pipeline.Close()
 		cancel()
+		// clients are told how the session ended through the pipelines so
+		// they're closed last
 		s.End()
+		for _, p := range s.pipelines() {
+			p.Close()
+		}
 		// defer close(doneChan)
 		close(errChan)
 	}()
 
-	go s.pipeline.ReadStdin()
-	go s.pipeline.Start(doneChan)
+	go s.readHostInput()
+	s.mu.Lock()
+	s.started = true
+	for _, id := range s.windowIDsLocked() {
+		s.startWindow(s.windows[id])
+	}
+	s.mu.Unlock()
 	go s.regenPassLoop(ctx)
+	go s.heartbeatLoop(ctx)
+	if !s.playback {
+		// a recording keeps the size it was made at
+		go s.resizeLoop(ctx)
+	}
 	go s.listen(ctx, doneChan, errChan)
 
 	// wait for and handle errors
@@ -110,6 +157,9 @@ func (s *Session) Start() error {
 	case <-doneChan:
 		fmt.Println("exiting by done chan...")
 		return nil
+	case <-s.ended:
+		fmt.Println("exiting by the last window closing...")
+		return nil
 	case <-ctx.Done():
 		fmt.Println("exiting by context done...")
 		// return s.End()
@@ -119,8 +169,6 @@ func (s *Session) Start() error {
 }
 
 func (s *Session) listen(ctx context.Context, doneChan chan<- struct{}, errChan chan error) {
-	idChan := make(chan string, 1)
-
 	log.Println("server started...")
 	defer close(doneChan)
 	for {
@@ -144,7 +192,8 @@ func (s *Session) listen(ctx context.Context, doneChan chan<- struct{}, errChan
 		// upon successful connection
 		go func(ctx context.Context, conn net.Conn) {
 			fmt.Println("new connection...")
-			if len(s.clients) >= int(s.maxConns) {
+			// a client coming back for its held place still has to be let through
+			if s.isFull() && !s.hasDetached() {
 				tempCtx, tempCancel := context.WithTimeout(ctx, clientKickTimeout)
 				defer tempCancel()
 
@@ -175,12 +224,12 @@ func (s *Session) listen(ctx context.Context, doneChan chan<- struct{}, errChan
 				return
 			}
 
-			idChan <- s.handleNewConn(ctx, conn)
+			// authentication and approval can take a while so each client gets
+			// its own goroutine to keep the session accepting new connections
+			if id := s.handleNewConn(ctx, conn); id != "" {
+				s.handleClientIO(ctx, id)
+			}
 		}(ctx, conn)
-
-		// probably not the best way to go at this but a check in handleClientIO
-		// will return early if idChan passes an empty string due to an error
-		go s.handleClientIO(ctx, <-idChan)
 	}
 }
 
@@ -235,53 +284,97 @@ func (s *Session) End() error {
 		}
 	}
 
-	// notify and close clients
-	if len(s.clients) > 1 {
-		group, gCtx := errgroup.WithContext(ctx)
-		group.SetLimit(len(s.clients))
+	// clients find out how the program ended before they're told to leave.
+	// both go through each client's queue so they arrive after whatever output
+	// it still has waiting
+	var payloads [][]byte
+	if status, ok := s.GetExitStatus(); ok {
+		msg := fmt.Sprintf("the session's program exited with status %d", status)
+		payload, err := base.EncodePayload(common.Header_HEADER_INFO, base.GenerateExitInfo(msg, int32(status)))
+		if err != nil {
+			log.Println("failed to encode exit status:", err)
+		} else {
+			payloads = append(payloads, payload)
+		}
+	}
+	infoPayload := base.GenerateInfo(info.Info_INFO_SHUTDOWN, "Server is shutting down")
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO, infoPayload)
+	if err != nil {
+		return err
+	}
+	payloads = append(payloads, payload)
 
-		s.mu.Lock()
-		defer s.mu.Unlock()
+	s.mu.Lock()
+	for _, client := range s.clients {
+		if client.isOwner {
+			continue
+		}
 
-		for _, client := range s.clients {
-			if client.isOwner {
-				continue
+		p := s.watchedLocked(client)
+		for _, payload := range payloads {
+			if err := p.Send(client.conn, payload); err != nil {
+				log.Printf("Error sending shutdown message to client: %v", err)
+				break
 			}
+		}
+	}
+	pipelines := s.pipelinesLocked()
+	s.mu.Unlock()
 
-			// client := client // capture loop variable
-			group.Go(func() error {
-				// Prepare shutdown notification
-				infoPayload := base.GenerateInfo(info.Info_INFO_SHUTDOWN, "Server is shutting down")
-				payload, err := base.EncodePayload(common.Header_HEADER_INFO, infoPayload)
-				if err != nil {
-					return err
-				}
-
-				// attempt to send shutdown message
-				err = utils.WriteFull(gCtx, client.conn, s.tracker, payload)
-				if err != nil &&
-					!errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
-					log.Printf("Error sending shutdown message to client: %v", err)
-				}
+	// the pipelines close their connections once we're done so everything
+	// queued has to be written before then
+	for _, p := range pipelines {
+		if err := p.Drain(ctx); err != nil {
+			log.Println("clients didn't catch up before shutting down:", err)
+			break
+		}
+	}
 
-				// ensure connection is closed
-				s.pipeline.Unsubscribe(client.conn)
-				client.conn.Close()
-				delete(s.clients, client.uuid)
-				return nil
-			})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, client := range s.clients {
+		if client.isOwner {
+			continue
 		}
 
-		// wait for all client shutdown attempts
-		return group.Wait()
+		s.unsubscribeLocked(client.conn)
+		client.conn.Close()
+		delete(s.clients, client.uuid)
 	}
 
 	return nil
 }
 
 func (s *Session) handleNewConn(ctx context.Context, conn net.Conn) string {
-	name, err := s.authenticateClient(ctx, conn)
+	if reason, banned := s.banReason(remoteIP(conn), ""); banned {
+		s.kickBanned(ctx, conn, reason)
+		return ""
+	}
+
+	res, err := s.authenticateClient(ctx, conn)
+	if errors.Is(err, utils.ErrVersionMismatch) {
+		s.kickClient(ctx,
+			conn,
+			err1.ErrorMessage_ERROR_VERSION_MISMATCH,
+			[]string{"version_mismatch", fmt.Sprintf("the session speaks protocol %s but you speak %s; use a build that matches",
+				versionRange(base.MinProtocolVersion, base.MaxProtocolVersion), versionRange(res.peerMin, res.peerMax))},
+		)
+		conn.Close()
+		log.Printf("turned away %s; it speaks protocol %s", res.name, versionRange(res.peerMin, res.peerMax))
+		return ""
+	}
 	if err != nil {
+		if errors.Is(err, utils.ErrResumeFailed) {
+			s.kickClient(ctx,
+				conn,
+				err1.ErrorMessage_ERROR_RESUME_FAILED,
+				[]string{"resume_failed", "the session didn't keep your place; join again with the passphrase"},
+			)
+			conn.Close()
+			log.Println("sent resume_failed message")
+			return ""
+		}
+
 		// if errors.Is(err, utils.ErrClientEarlyExit) {
 		// 	conn.Close(
 		// } else if errors.Is(err, utils.ErrFailedServerAuth) {
@@ -295,22 +388,70 @@ func (s *Session) handleNewConn(ctx context.Context, conn net.Conn) string {
 		return ""
 	}
 
-	s.mu.Lock()
-	newClient := createClient(name, conn, false)
-	s.clients[newClient.uuid] = newClient
-
-	s.pipeline.Subscribe(newClient.conn)
-	s.mu.Unlock()
+	// the name only counts once the client has proved it knows the passphrase
+	if reason, banned := s.banReason("", res.name); banned {
+		s.kickBanned(ctx, conn, reason)
+		return ""
+	}
 
-	// send a congratulatory message to the client
-	infoPayload := base.GenerateInfo(info.Info_INFO_AUTH_SUCCESS, "welcome to the session")
-	payload, err := base.EncodePayload(common.Header_HEADER_INFO, infoPayload)
+	// prove to the client that we know the passphrase too; everything after
+	// this goes over the encrypted connection
+	confirmPayload, err := base.EncodePayload(common.Header_HEADER_AUTH, base.GenerateAuthConfirm(res.keys.ServerConfirm, res.version, res.caps))
 	if err != nil {
+		conn.Close()
+		return ""
+	}
+	if err := utils.WriteFull(ctx, conn, s.tracker, confirmPayload); err != nil {
+		conn.Close()
 		return ""
 	}
 
-	err = utils.WriteFull(ctx, conn, s.tracker, payload)
+	secureConn, err := utils.NewSecureConn(conn, res.keys.ServerKey, res.keys.ClientKey)
 	if err != nil {
+		conn.Close()
+		return ""
+	}
+
+	if res.resumed != nil {
+		// the client may have come back on a different build
+		s.mu.Lock()
+		res.resumed.version, res.resumed.caps = res.version, res.caps
+		s.mu.Unlock()
+		return s.rejoin(ctx, res.resumed, secureConn)
+	}
+
+	// a session with places held for dropped clients lets everyone get as far as
+	// authenticating so only now do we know this one can't fit
+	if s.isFull() {
+		s.kickClient(ctx, secureConn, err1.ErrorMessage_ERROR_SERVER_FULL, []string{"server_full", "server is full"})
+		secureConn.Close()
+		return ""
+	}
+
+	newClient := createClient(res.name, secureConn, false)
+	newClient.version, newClient.caps = res.version, res.caps
+	if s.requiresApproval() {
+		if err := s.waitForApproval(ctx, newClient); err != nil {
+			code := err1.ErrorMessage_ERROR_APPROVAL_DENIED
+			details := []string{"approval_denied", "the host didn't let you in"}
+			if errors.Is(err, utils.ErrApprovalTimeout) {
+				code = err1.ErrorMessage_ERROR_APPROVAL_TIMEOUT
+				details = []string{"approval_timeout", "the host didn't let you in in time"}
+			}
+			s.kickClient(ctx, newClient.conn, code, details)
+			newClient.conn.Close()
+			log.Printf("%s wasn't let in: %v", res.name, err)
+			return ""
+		}
+	}
+
+	s.mu.Lock()
+	s.clients[newClient.uuid] = newClient
+	s.mu.Unlock()
+
+	// send a congratulatory message to the client
+	if err := s.welcome(ctx, newClient, "welcome to the session"); err != nil {
+		s.removeClient(newClient.uuid)
 		return ""
 	}
 
@@ -318,6 +459,40 @@ func (s *Session) handleNewConn(ctx context.Context, conn net.Conn) string {
 	return newClient.uuid
 }
 
+// kickBanned turns away a banned client before it gets anywhere near the session
+func (s *Session) kickBanned(ctx context.Context, conn net.Conn, reason string) {
+	s.kickClient(ctx,
+		conn,
+		err1.ErrorMessage_ERROR_BANNED,
+		[]string{"banned", withReason("you're banned from this session", reason)},
+	)
+	conn.Close()
+	log.Println("turned away banned client from", conn.RemoteAddr())
+}
+
+// rejoin puts a client that resumed back in its old place without going
+// through approval again
+func (s *Session) rejoin(ctx context.Context, client *sessionClient, conn net.Conn) string {
+	if err := s.resumeClient(client, conn); err != nil {
+		s.kickClient(ctx,
+			conn,
+			err1.ErrorMessage_ERROR_RESUME_FAILED,
+			[]string{"resume_failed", "the session didn't keep your place; join again with the passphrase"},
+		)
+		conn.Close()
+		return ""
+	}
+
+	if err := s.welcome(ctx, client, "welcome back"); err != nil {
+		s.detachClient(client.uuid, conn)
+		return ""
+	}
+
+	s.notifyHost(fmt.Sprintf("%s is back", client.name))
+	log.Println("resumed client", client.uuid)
+	return client.uuid
+}
+
 func (s *Session) kickClientGracefully(clientID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -336,18 +511,47 @@ func (s *Session) kickClientGracefully(clientID string) error {
 	// 	cancel()
 	// }
 
-	s.pipeline.Unsubscribe(curClient.conn)
+	s.unsubscribeLocked(curClient.conn)
 	delete(s.clients, clientID)
 	return nil
 }
 
+// removeClient drops the client from the session and closes its connection
+// which frees up its slot for someone else
+func (s *Session) removeClient(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	curClient, ok := s.clients[clientID]
+	if !ok || curClient.isOwner {
+		return
+	}
+
+	s.unsubscribeLocked(curClient.conn)
+	curClient.conn.Close()
+	delete(s.clients, clientID)
+}
+
 func (s *Session) handleClientIO(ctx context.Context, clientID string) {
 	var wg sync.WaitGroup
 
 	readErr := make(chan error, 1)
 	readData := make(chan []byte, 1)
 	errChan := make(chan error, 1)
+	// payloads are handled one at a time in the order they came in so input
+	// from writers reaches the pty the way it was typed
+	work := make(chan []byte, maxQueuedPayloads)
 	defer func() {
+		// keep draining so no goroutine is stuck on a send while we wait
+		go func() {
+			for range errChan {
+			}
+		}()
+		go func() {
+			for range readData {
+			}
+		}()
+		close(work)
 		wg.Wait()      // wait for all goroutines to finish
 		close(errChan) // only close after all usage is done
 		close(readErr)
@@ -355,10 +559,14 @@ func (s *Session) handleClientIO(ctx context.Context, clientID string) {
 	}()
 
 	log.Println("handling new client io")
+	s.mu.Lock()
 	client, ok := s.clients[clientID]
+	s.mu.Unlock()
 	if !ok {
 		return
 	}
+	// the client gets a new connection if it resumes so hold on to this one
+	conn := client.conn
 
 	key := clientUniqID("client_id")
 	procCtx := context.WithValue(ctx, key, clientID)
@@ -372,7 +580,7 @@ func (s *Session) handleClientIO(ctx context.Context, clientID string) {
 			case <-ctx.Done():
 				return
 			default:
-				read, err := utils.ReadFull(ctx, client.conn, s.tracker)
+				read, err := utils.ReadFull(ctx, conn, s.tracker)
 				if err != nil {
 					log.Println("we got this error:", err.Error())
 					readErr <- err
@@ -383,6 +591,14 @@ func (s *Session) handleClientIO(ctx context.Context, clientID string) {
 		}
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for data := range work {
+			s.processPayload(procCtx, data, errChan)
+		}
+	}()
+
 	// main loop
 	for {
 		select {
@@ -394,13 +610,14 @@ func (s *Session) handleClientIO(ctx context.Context, clientID string) {
 			} else if errors.Is(err, utils.ErrFailedAfterRetries) {
 				log.Println("failed to read from the server")
 			}
+			s.detachClient(clientID, conn)
 			return
+		case err := <-errChan:
+			if err != nil {
+				log.Printf("client %s payload err: %v", clientID, err)
+			}
 		case read := <-readData:
-			wg.Add(1)
-			go func(data []byte) {
-				defer wg.Done()
-				s.processPayload(procCtx, data, errChan)
-			}(read)
+			work <- read
 		}
 	}
 }
@@ -412,10 +629,21 @@ func (s *Session) processPayload(ctx context.Context, data []byte, errChan chan<
 		return
 	}
 
+	id, ok := ctx.Value(clientUniqID("client_id")).(string)
+	if !ok {
+		errChan <- fmt.Errorf("unable to get value")
+		return
+	}
+	s.markSeen(id)
+
 	switch payload.GetHeader() {
 	case common.Header_HEADER_HEARTBEAT:
-		// TODO; this is incomplete FIXME!
-		errChan <- s.handleHeartbeatResp()
+		hbPayload, ok := payload.GetContent().(*base.Payload_Heartbeat)
+		if !ok {
+			errChan <- fmt.Errorf("couldn't assert heartbeat payload")
+			return
+		}
+		errChan <- s.handleHeartbeat(ctx, id, hbPayload.Heartbeat)
 	case common.Header_HEADER_INFO:
 		infoPayload, ok := payload.GetContent().(*base.Payload_Info)
 		if !ok {
@@ -423,6 +651,34 @@ func (s *Session) processPayload(ctx context.Context, data []byte, errChan chan<
 		}
 		log.Println("got an info payload")
 		errChan <- s.handleClientInfoMsg(ctx, infoPayload)
+	case common.Header_HEADER_RESEND_REQ:
+		resendPayload, ok := payload.GetContent().(*base.Payload_Resend)
+		if !ok {
+			errChan <- fmt.Errorf("couldn't assert resend payload")
+			return
+		}
+		errChan <- s.handleResendReq(ctx, id, resendPayload)
+	case common.Header_HEADER_CLIENT_INPUT:
+		inputPayload, ok := payload.GetContent().(*base.Payload_Input)
+		if !ok {
+			errChan <- fmt.Errorf("couldn't assert input payload")
+			return
+		}
+		errChan <- s.handleClientInput(id, inputPayload.Input)
+	case common.Header_HEADER_WINDOW_SELECT:
+		selectPayload, ok := payload.GetContent().(*base.Payload_WindowSelect)
+		if !ok {
+			errChan <- fmt.Errorf("couldn't assert window select payload")
+			return
+		}
+		errChan <- s.selectWindow(id, selectPayload.WindowSelect)
+	case common.Header_HEADER_SCROLLBACK_REQ:
+		scrollbackPayload, ok := payload.GetContent().(*base.Payload_ScrollbackRequest)
+		if !ok {
+			errChan <- fmt.Errorf("couldn't assert scrollback payload")
+			return
+		}
+		errChan <- s.handleScrollbackReq(id, scrollbackPayload.ScrollbackRequest)
 	}
 
 }
@@ -441,7 +697,35 @@ func (s *Session) handleClientInfoMsg(ctx context.Context, infoPayload *base.Pay
 	return nil
 }
 
-func (*Session) handleHeartbeatResp() error {
+// handleResendReq retransmits the terminal frames the client says it missed. if
+// some of them have already fallen out of the pipeline's ring, the client gets a
+// keyframe of the current screen instead which makes the missing frames moot
+func (s *Session) handleResendReq(ctx context.Context, clientID string, req *base.Payload_Resend) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("failed to find client in handleResendReq")
+	}
+
+	from, to := req.Resend.GetFrom(), req.Resend.GetTo()
+	if from > to {
+		return fmt.Errorf("invalid resend range %d-%d", from, to)
+	}
+
+	p := s.watched(client)
+	frames, oldest := p.Frames(from, to)
+	if from < oldest {
+		return p.SendKeyframe(client.conn)
+	}
+
+	// the frames go through the client's queue so they can't jump ahead of
+	// whatever is already waiting to be written to it
+	for _, frame := range frames {
+		if err := p.Send(client.conn, frame); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
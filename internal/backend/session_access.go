@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/payload/input"
+	"willofdaedalus/superluminal/internal/pipeline"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+func (r clientRole) String() string {
+	switch r {
+	case roleViewer:
+		return "viewer"
+	case roleWriter:
+		return "writer"
+	}
+
+	return "unknown"
+}
+
+// handleClientInput passes keystrokes from a client on to the pty if the host
+// gave it write access. input from viewers is dropped
+func (s *Session) handleClientInput(clientID string, in *input.ClientInput) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	canWrite := ok && client.role == roleWriter
+	canType := ok && client.caps.Has(auth.Capability_CAPABILITY_INPUT)
+	var p *pipeline.Pipeline
+	if ok {
+		// writers type into whichever window they're watching
+		p = s.watchedLocked(client)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return utils.ErrNoSuchClient
+	}
+	if !canType {
+		return utils.ErrNotSupported
+	}
+	if !canWrite {
+		return utils.ErrReadOnlyClient
+	}
+
+	p.WriteTo(in.GetData())
+	return nil
+}
+
+// setRole changes what a client is allowed to do and lets it know
+func (s *Session) setRole(clientID string, role clientRole) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	if !ok || client.isOwner {
+		s.mu.Unlock()
+		return utils.ErrNoSuchClient
+	}
+	changed := client.role != role
+	client.role = role
+	conn := client.conn
+	p := s.watchedLocked(client)
+	s.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	infoPayload := base.GenerateInfo(info.Info_INFO_WRITE_REVOKED, "you can only watch now")
+	if role == roleWriter {
+		infoPayload = base.GenerateInfo(info.Info_INFO_WRITE_GRANTED, "you can type into the session now")
+	}
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO, infoPayload)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Send(conn, payload); err != nil {
+		log.Printf("couldn't tell %s about their new role: %v", client.name, err)
+	}
+
+	return nil
+}
+
+// how much of a client's id list shows; plenty to tell the clients in a session
+// apart
+const shortIDLen = 8
+
+// shortID is the part of a client's id list shows for picking it out
+func shortID(id string) string {
+	return id[:min(len(id), shortIDLen)]
+}
+
+// describeClient is how a client is shown when the host has to pick it out
+func describeClient(c *sessionClient) string {
+	if c.conn == nil || c.conn.RemoteAddr() == nil {
+		return fmt.Sprintf("%s [%s]", c.name, shortID(c.uuid))
+	}
+	return fmt.Sprintf("%s [%s] (%s)", c.name, shortID(c.uuid), c.conn.RemoteAddr())
+}
+
+// hasID reports whether ref is c's id or the part of it list shows
+func hasID(c *sessionClient, ref string) bool {
+	return c.uuid == ref || shortID(c.uuid) == ref
+}
+
+// matchClient picks the client ref refers to out of groups by the id list shows
+// for it, its whole id or its name. ids are tried first so nobody can name
+// themselves after someone else's id. names aren't unique so one that more than
+// one client goes by is refused with who they are for the host to pick from.
+// must be called with s.mu held
+func matchClient(ref string, groups ...map[string]*sessionClient) (*sessionClient, error) {
+	var byID, byName []*sessionClient
+	for _, clients := range groups {
+		for _, c := range clients {
+			if c.isOwner {
+				continue
+			}
+			if hasID(c, ref) {
+				byID = append(byID, c)
+			}
+			if c.name == ref {
+				byName = append(byName, c)
+			}
+		}
+	}
+
+	matches := byID
+	if len(matches) == 0 {
+		matches = byName
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, utils.ErrNoSuchClient
+	case 1:
+		return matches[0], nil
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, c := range matches {
+		candidates = append(candidates, describeClient(c))
+	}
+	sort.Strings(candidates)
+	return nil, fmt.Errorf("%w: %s", utils.ErrAmbiguousClient, strings.Join(candidates, ", "))
+}
+
+// findClient looks up a client in the session by its id or a name only it goes by
+func (s *Session) findClient(ref string) (*sessionClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return matchClient(ref, s.clients)
+}
+
+func (s *Session) roleCommand(args []string, role clientRole) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a name or id from list")
+	}
+
+	client, err := s.findClient(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.setRole(client.uuid, role); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s is a %s now", client.name, role), nil
+}
@@ -1,8 +1,14 @@
 package backend
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+	"willofdaedalus/superluminal/internal/pipeline"
+	"willofdaedalus/superluminal/internal/utils"
+)
 
-// name, ipaddr, timejoined
+// name, ipaddr, timejoined, role
 func (s *Session) GetAllClients() []string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -10,10 +16,11 @@ func (s *Session) GetAllClients() []string {
 	allClients := make([]string, len(s.clients))
 	for _, v := range s.clients {
 		if !v.isOwner {
-			client := fmt.Sprintf("%s$$%s$$%s",
+			client := fmt.Sprintf("%s$$%s$$%s$$%s",
 				v.name,
 				v.conn.RemoteAddr().String(),
 				v.joined.String(),
+				v.role,
 			)
 			allClients = append(allClients, client)
 		}
@@ -33,3 +40,296 @@ func (s *Session) GetCurrentPass() string {
 func (s *Session) SetMaxConns(max uint8) {
 	s.maxConns = max
 }
+
+// name, rtt, queued frames, dropped frames, coalesced frames
+func (s *Session) GetClientStats() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allStats := make([]string, 0, len(s.clients))
+	for _, v := range s.clients {
+		if v.isOwner {
+			continue
+		}
+
+		stats, _ := s.watchedLocked(v).ConsumerStats(v.conn)
+		allStats = append(allStats, fmt.Sprintf("%s$$%s$$%d$$%d$$%d",
+			v.name,
+			v.rtt.String(),
+			stats.Queued,
+			stats.Dropped,
+			stats.Coalesced,
+		))
+	}
+
+	return allStats
+}
+
+// SetOverflowPolicy sets what happens to clients that can't keep up with the
+// stream; it only applies to clients that join after it's called
+func (s *Session) SetOverflowPolicy(policy pipeline.OverflowPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policy = policy
+	for _, p := range s.pipelinesLocked() {
+		p.SetOverflowPolicy(policy)
+	}
+}
+
+// SetScrollback sets how many lines that scrolled off the screen each window
+// keeps for clients to look back at. 0 stops keeping them
+func (s *Session) SetScrollback(lines int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scrollback = lines
+	for _, p := range s.pipelinesLocked() {
+		p.SetScrollback(lines)
+	}
+}
+
+// SetBatching sets how long the output of every window is held back to go out
+// as one frame with whatever comes right after it. 0 sends it as it comes
+func (s *Session) SetBatching(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batchWindow = window
+	for _, p := range s.pipelinesLocked() {
+		p.SetBatching(window, pipeline.DefaultBatchSize)
+	}
+}
+
+// SetFrameRate caps how many frames a second clients are sent; output in between
+// is merged into the next frame. 0 doesn't cap it. It only applies to clients
+// that join after it's called
+func (s *Session) SetFrameRate(fps int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frameRate = fps
+	for _, p := range s.pipelinesLocked() {
+		p.SetFrameRate(fps)
+	}
+}
+
+// SetCompression sets whether terminal frames are deflated for clients that can
+// take them. It only applies to clients that join after it's called
+func (s *Session) SetCompression(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compression = on
+}
+
+// cols x rows of the shared terminal
+func (s *Session) GetTermSize() string {
+	rows, cols := s.active().Size()
+	return fmt.Sprintf("%dx%d", cols, rows)
+}
+
+// empty when the session isn't using tls
+func (s *Session) GetFingerprint() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fingerprint
+}
+
+// id, name, ipaddr, time waiting; oldest first
+func (s *Session) GetPendingClients() []string {
+	pending := s.pendingClients()
+
+	allPending := make([]string, 0, len(pending))
+	for _, p := range pending {
+		allPending = append(allPending, fmt.Sprintf("%s$$%s$$%s$$%s",
+			p.client.uuid,
+			p.client.name,
+			p.client.conn.RemoteAddr().String(),
+			time.Since(p.requested).Round(time.Second).String(),
+		))
+	}
+
+	return allPending
+}
+
+// ApproveClient lets a client waiting for approval into the session
+func (s *Session) ApproveClient(clientID string) error {
+	return s.decide(clientID, true)
+}
+
+// DenyClient turns away a client waiting for approval
+func (s *Session) DenyClient(clientID string) error {
+	return s.decide(clientID, false)
+}
+
+// SetApprovalRequired sets whether clients need the host's approval to join
+// after they authenticate
+func (s *Session) SetApprovalRequired(required bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.needsApproval = required
+}
+
+// SetApprovalTimeout sets how long clients wait for approval before they're
+// turned away
+func (s *Session) SetApprovalTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvalTime = timeout
+}
+
+// GrantWrite lets a client type into the session
+func (s *Session) GrantWrite(clientID string) error {
+	return s.setRole(clientID, roleWriter)
+}
+
+// RevokeWrite makes a client go back to only watching the session
+func (s *Session) RevokeWrite(clientID string) error {
+	return s.setRole(clientID, roleViewer)
+}
+
+// StartRecording writes the window the host has in front to an asciicast v2
+// file at path until StopRecording is called or the window closes
+func (s *Session) StartRecording(path string) error {
+	if s.GetRecording() != "" {
+		return utils.ErrAlreadyRecording
+	}
+
+	s.mu.Lock()
+	recordInput := s.recordInput
+	p := s.pipeline
+	s.mu.Unlock()
+
+	return p.StartRecording(path, recordInput)
+}
+
+// StopRecording finishes the recording and returns the file it went to
+func (s *Session) StopRecording() (string, error) {
+	for _, p := range s.pipelines() {
+		if _, ok := p.Recording(); ok {
+			return p.StopRecording()
+		}
+	}
+
+	return "", utils.ErrNotRecording
+}
+
+// empty when the session isn't being recorded
+func (s *Session) GetRecording() string {
+	for _, p := range s.pipelines() {
+		if path, ok := p.Recording(); ok {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// SetRecordInput sets whether what's typed into the session goes into
+// recordings. It only applies to recordings started after it's called
+func (s *Session) SetRecordInput(record bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordInput = record
+}
+
+// RedactSecrets masks anything matching patterns in what clients see, or the
+// pipeline's default secret patterns if there aren't any. The host's own
+// screen isn't touched
+func (s *Session) RedactSecrets(patterns ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// every window gets its own redactor since they hold on to output
+	for _, p := range s.pipelinesLocked() {
+		r, err := pipeline.NewRedactor(patterns...)
+		if err != nil {
+			return err
+		}
+		p.SetFilter(r)
+	}
+
+	s.redacting = true
+	s.redactPatterns = patterns
+	return nil
+}
+
+func (s *Session) StopRedacting() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.pipelinesLocked() {
+		p.SetFilter(nil)
+	}
+	s.redacting = false
+	s.redactPatterns = nil
+}
+
+// GetExitStatus returns the exit status of the program the session shares and
+// whether it has exited
+func (s *Session) GetExitStatus() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitStatus, s.exited
+}
+
+// PauseStream stops sending the session to clients. They're told it's paused
+// and get the screen as it is when ResumeStream is called
+func (s *Session) PauseStream() error {
+	for _, p := range s.pipelines() {
+		if err := p.Pause(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) ResumeStream() error {
+	for _, p := range s.pipelines() {
+		if err := p.Resume(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) IsStreamPaused() bool {
+	return s.active().Paused()
+}
+
+// SetResumeGrace sets how long the place of a client whose connection dropped is
+// held for it to come back. 0 frees it up straight away
+func (s *Session) SetResumeGrace(grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resumeGrace = grace
+}
+
+// KickClient removes a client from the session telling it why. It can join
+// again with the passphrase
+func (s *Session) KickClient(clientID, reason string) error {
+	return s.kick(clientID, reason)
+}
+
+// BanClient removes a client from the session and keeps its address and name
+// from joining again
+func (s *Session) BanClient(clientID, reason string) error {
+	return s.ban(clientID, reason)
+}
+
+// Unban lets a banned address or name join again
+func (s *Session) Unban(ref string) error {
+	return s.unban(ref)
+}
+
+// kind (address or name), address or name, reason
+func (s *Session) GetBans() []string {
+	bans := s.banned()
+
+	allBans := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		allBans = append(allBans, strings.Join(ban[:], "$$"))
+	}
+
+	return allBans
+}
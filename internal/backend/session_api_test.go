@@ -17,7 +17,7 @@ func TestGetAllClients(t *testing.T) {
 		"e": createClient("bruce wayne", in, false),
 	}
 
-	sess := session{
+	sess := Session{
 		clients:  mockClients,
 		maxConns: uint8(len(mockClients)),
 	}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// waitForApproval parks a client that passed authentication until the host lets
+// it in, turns it away or the approval window runs out. it returns nil if the
+// client was let in
+func (s *Session) waitForApproval(ctx context.Context, client *sessionClient) error {
+	p := &pendingClient{
+		client:    client,
+		requested: time.Now(),
+		decision:  make(chan bool, 1),
+	}
+
+	s.mu.Lock()
+	s.pending[client.uuid] = p
+	timeout := s.approvalTime
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, client.uuid)
+		s.mu.Unlock()
+	}()
+
+	infoPayload := base.GenerateInfo(info.Info_INFO_AWAITING_APPROVAL, "waiting for the host to let you in")
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO, infoPayload)
+	if err != nil {
+		return err
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, clientKickTimeout)
+	err = utils.WriteFull(writeCtx, client.conn, s.tracker, payload)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	s.notifyHost(fmt.Sprintf("%s wants to join; press ctrl-] and type approve %s or deny %s",
+		describeClient(client), shortID(client.uuid), shortID(client.uuid)))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case approved := <-p.decision:
+		if !approved {
+			return utils.ErrApprovalDenied
+		}
+		return nil
+	case <-timer.C:
+		s.notifyHost(fmt.Sprintf("%s wasn't let in in time", client.name))
+		return utils.ErrApprovalTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// decide lets a pending client in or turns it away
+func (s *Session) decide(clientID string, approve bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[clientID]
+	if !ok {
+		return utils.ErrNoSuchClient
+	}
+
+	select {
+	case p.decision <- approve:
+	default:
+		// already decided
+	}
+	delete(s.pending, clientID)
+	return nil
+}
+
+// pendingClients returns the clients waiting for approval oldest first
+func (s *Session) pendingClients() []*pendingClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make([]*pendingClient, 0, len(s.pending))
+	for _, p := range s.pending {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].requested.Before(pending[j].requested)
+	})
+
+	return pending
+}
+
+// findPending looks up a pending client by its id, its place in the queue
+// (starting at 1) or a name only it goes by. places are tried after ids and
+// before names since anyone can call themselves a number
+func (s *Session) findPending(ref string) (*pendingClient, error) {
+	pending := s.pendingClients()
+
+	waiting := make(map[string]*sessionClient, len(pending))
+	isID := false
+	for _, p := range pending {
+		waiting[p.client.uuid] = p.client
+		isID = isID || hasID(p.client, ref)
+	}
+
+	if n, err := strconv.Atoi(ref); err == nil && !isID {
+		if n < 1 || n > len(pending) {
+			return nil, utils.ErrNoSuchClient
+		}
+		return pending[n-1], nil
+	}
+
+	client, err := matchClient(ref, waiting)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pending {
+		if p.client == client {
+			return p, nil
+		}
+	}
+
+	return nil, utils.ErrNoSuchClient
+}
+
+func (s *Session) requiresApproval() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.needsApproval
+}
+
+// isFull reports whether there's room for another client. clients waiting for
+// approval count since they'd take up a slot once they're let in and so do the
+// places held for clients that dropped
+func (s *Session) isFull() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)+len(s.pending)+len(s.detached) >= int(s.maxConns)
+}
@@ -3,16 +3,29 @@ package backend
 import (
 	"net"
 	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
 
 	"github.com/google/uuid"
 )
 
 func createClient(name string, conn net.Conn, isOwner bool) *sessionClient {
+	now := time.Now()
+	// everyone starts off watching; the host has to hand out write access
+	role := roleViewer
+	if isOwner {
+		role = roleWriter
+	}
+
 	return &sessionClient{
-		name:    name,
-		conn:    conn,
-		uuid:    uuid.NewString(),
-		joined:  time.Now(),
-		isOwner: isOwner,
+		name:     name,
+		conn:     conn,
+		uuid:     uuid.NewString(),
+		joined:   now,
+		lastSeen: now,
+		role:     role,
+		isOwner:  isOwner,
+		// until we hear otherwise the client is taken to be this build
+		version: base.MaxProtocolVersion,
+		caps:    base.DefaultCapabilities,
 	}
 }
@@ -0,0 +1,320 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ctrl-] opens the command prompt; everything else the host types goes to the pty
+	commandKey = 0x1d
+	ctrlC      = 0x03
+	backspace  = 0x7f
+)
+
+// hostCommand is something the host can do to the session from the command prompt
+type hostCommand struct {
+	usage string
+	help  string
+	run   func(s *Session, args []string) (string, error)
+}
+
+var hostCommands = map[string]hostCommand{
+	"list": {
+		usage: "list",
+		help:  "show who's waiting to join and who's in the session",
+		run: func(s *Session, args []string) (string, error) {
+			return s.listClients(), nil
+		},
+	},
+	"approve": {
+		usage: "approve <name|id|number>",
+		help:  "let a waiting client in",
+		run: func(s *Session, args []string) (string, error) {
+			return s.decideCommand(args, true)
+		},
+	},
+	"deny": {
+		usage: "deny <name|id|number>",
+		help:  "turn a waiting client away",
+		run: func(s *Session, args []string) (string, error) {
+			return s.decideCommand(args, false)
+		},
+	},
+	"grant": {
+		usage: "grant <name|id>",
+		help:  "let a client type into the session",
+		run: func(s *Session, args []string) (string, error) {
+			return s.roleCommand(args, roleWriter)
+		},
+	},
+	"revoke": {
+		usage: "revoke <name|id>",
+		help:  "make a client go back to watching",
+		run: func(s *Session, args []string) (string, error) {
+			return s.roleCommand(args, roleViewer)
+		},
+	},
+	"kick": {
+		usage: "kick <name|id> [reason]",
+		help:  "remove a client from the session",
+		run: func(s *Session, args []string) (string, error) {
+			return s.kickCommand(args)
+		},
+	},
+	"ban": {
+		usage: "ban <name|id|address> [reason]",
+		help:  "remove a client and keep its address and name out",
+		run: func(s *Session, args []string) (string, error) {
+			return s.banCommand(args)
+		},
+	},
+	"unban": {
+		usage: "unban <name|address>",
+		help:  "let a banned address or name back in",
+		run: func(s *Session, args []string) (string, error) {
+			return s.unbanCommand(args)
+		},
+	},
+	"bans": {
+		usage: "bans",
+		help:  "show who's banned",
+		run: func(s *Session, args []string) (string, error) {
+			return s.listBans(), nil
+		},
+	},
+	"record": {
+		usage: "record [file|stop]",
+		help:  "start or stop recording the session to an asciicast file",
+		run: func(s *Session, args []string) (string, error) {
+			return s.recordCommand(args)
+		},
+	},
+	"windows": {
+		usage: "windows",
+		help:  "show the session's windows; * is the one you're looking at",
+		run: func(s *Session, args []string) (string, error) {
+			return s.listWindows(), nil
+		},
+	},
+	"new": {
+		usage: "new [name] [command...]",
+		help:  "open a window running a command or your shell",
+		run: func(s *Session, args []string) (string, error) {
+			return s.newWindowCommand(args)
+		},
+	},
+	"close": {
+		usage: "close <name|number>",
+		help:  "close a window and end what's running in it",
+		run: func(s *Session, args []string) (string, error) {
+			return s.closeWindowCommand(args)
+		},
+	},
+	"switch": {
+		usage: "switch <name|number>",
+		help:  "look at another window; clients following you switch too",
+		run: func(s *Session, args []string) (string, error) {
+			return s.switchWindowCommand(args)
+		},
+	},
+	"pause": {
+		usage: "pause",
+		help:  "stop showing the session to clients for a while",
+		run: func(s *Session, args []string) (string, error) {
+			if err := s.PauseStream(); err != nil {
+				return "", err
+			}
+			return "paused the stream; type resume to show it again", nil
+		},
+	},
+	"resume": {
+		usage: "resume",
+		help:  "show the session to clients again",
+		run: func(s *Session, args []string) (string, error) {
+			if err := s.ResumeStream(); err != nil {
+				return "", err
+			}
+			return "resumed the stream", nil
+		},
+	},
+}
+
+// readHostInput forwards whatever the host types to the pty except for the
+// command key which opens a prompt for managing the session
+func (s *Session) readHostInput() {
+	buf := make([]byte, 1024)
+	var line []byte
+	inCommand := false
+
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			log.Println("error reading standard input:", err)
+			return
+		}
+
+		forward := make([]byte, 0, n)
+		for _, b := range buf[:n] {
+			if !inCommand {
+				if b == commandKey {
+					inCommand = true
+					line = line[:0]
+					s.hostPrint("\r\nsprlmnl> ")
+					continue
+				}
+				forward = append(forward, b)
+				continue
+			}
+
+			switch b {
+			case '\r', '\n':
+				inCommand = false
+				s.hostPrint("\r\n")
+				if out := s.runCommand(string(line)); out != "" {
+					s.hostPrint(strings.ReplaceAll(out, "\n", "\r\n") + "\r\n")
+				}
+			case commandKey, ctrlC:
+				inCommand = false
+				s.hostPrint("\r\n")
+			case backspace, '\b':
+				if len(line) > 0 {
+					line = line[:len(line)-1]
+					s.hostPrint("\b \b")
+				}
+			default:
+				if b >= 0x20 {
+					line = append(line, b)
+					s.hostPrint(string(b))
+				}
+			}
+		}
+
+		if len(forward) > 0 {
+			s.active().WriteTo(forward)
+		}
+	}
+}
+
+// runCommand runs a line typed at the command prompt and returns what to show
+// the host
+func (s *Session) runCommand(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	if fields[0] == "help" {
+		return commandHelp()
+	}
+
+	cmd, ok := hostCommands[fields[0]]
+	if !ok {
+		return fmt.Sprintf("unknown command %q; type help to see what's available", fields[0])
+	}
+
+	out, err := cmd.run(s, fields[1:])
+	if err != nil {
+		return fmt.Sprintf("%s: %v", fields[0], err)
+	}
+
+	return out
+}
+
+func commandHelp() string {
+	names := make([]string, 0, len(hostCommands))
+	for name := range hostCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		cmd := hostCommands[name]
+		fmt.Fprintf(&b, "%-28s %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintf(&b, "%-28s %s\n", "help", "show this")
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (s *Session) listClients() string {
+	var b strings.Builder
+
+	pending := s.pendingClients()
+	if len(pending) > 0 {
+		b.WriteString("waiting to join:\n")
+	}
+	for i, p := range pending {
+		fmt.Fprintf(&b, "  %d. %s waiting %s\n",
+			i+1, describeClient(p.client), time.Since(p.requested).Round(time.Second))
+	}
+
+	s.mu.Lock()
+	names := make([]string, 0, len(s.clients))
+	for _, c := range s.clients {
+		if !c.isOwner {
+			names = append(names, fmt.Sprintf("  %s %s", describeClient(c), c.role))
+		}
+	}
+
+	dropped := make([]string, 0, len(s.detached))
+	for _, c := range s.detached {
+		left := s.resumeGrace - time.Since(c.detachedAt)
+		dropped = append(dropped, fmt.Sprintf("  %s [%s] holding its place for %s",
+			c.name, shortID(c.uuid), left.Round(time.Second)))
+	}
+	s.mu.Unlock()
+
+	sort.Strings(names)
+	if len(names) > 0 {
+		b.WriteString("in the session:\n")
+		b.WriteString(strings.Join(names, "\n") + "\n")
+	}
+
+	sort.Strings(dropped)
+	if len(dropped) > 0 {
+		b.WriteString("dropped:\n")
+		b.WriteString(strings.Join(dropped, "\n"))
+	}
+
+	if b.Len() == 0 {
+		return "nobody's here yet"
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (s *Session) decideCommand(args []string, approve bool) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a name, id or number from list")
+	}
+
+	p, err := s.findPending(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.decide(p.client.uuid, approve); err != nil {
+		return "", err
+	}
+
+	if approve {
+		return fmt.Sprintf("let %s in", p.client.name), nil
+	}
+	return fmt.Sprintf("turned %s away", p.client.name), nil
+}
+
+// notifyHost shows the host a message from the session on its own line
+func (s *Session) notifyHost(msg string) {
+	s.hostPrint("\r\n[sprlmnl] " + msg + "\r\n")
+}
+
+// hostPrint writes straight to the host's terminal. stdout is in raw mode so
+// callers have to use \r\n for new lines
+func (s *Session) hostPrint(msg string) {
+	os.Stdout.WriteString(msg)
+}
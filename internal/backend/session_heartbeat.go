@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/heartbeat"
+)
+
+// heartbeatLoop pings every connected client at a third of the heartbeat
+// window so that a client gets a couple of chances to answer before it's
+// considered dead and evicted from the session
+func (s *Session) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.heartbeatTime / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evictDeadClients()
+			s.dropExpiredDetached()
+			s.pingClients()
+		}
+	}
+}
+
+// pingClients sends a heartbeat request to every client that isn't the owner
+func (s *Session) pingClients() {
+	ping := base.GenerateHeartbeatReq()
+	payload, err := base.EncodePayload(common.Header_HEADER_HEARTBEAT, &ping)
+	if err != nil {
+		log.Println("failed to encode heartbeat:", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, client := range s.clients {
+		if client.isOwner {
+			continue
+		}
+
+		// the ping goes through the client's queue like everything else so it
+		// can't end up in the middle of another frame. a client that's still
+		// being welcomed isn't subscribed yet and gets the next one
+		if err := s.watchedLocked(client).Send(client.conn, payload); err != nil {
+			log.Printf("failed to ping %s: %v", client.uuid, err)
+			continue
+		}
+		client.pingSent = time.Now()
+	}
+}
+
+// evictDeadClients removes all clients that haven't been heard from within the
+// heartbeat window. these are usually half-open connections that would otherwise
+// hold on to a slot in the session forever. their places are held like any other
+// dropped connection since a laptop going to sleep looks just like this
+func (s *Session) evictDeadClients() {
+	s.mu.Lock()
+	dead := make(map[string]net.Conn)
+	for id, client := range s.clients {
+		if client.isOwner {
+			continue
+		}
+
+		if time.Since(client.lastSeen) > s.heartbeatTime {
+			dead[id] = client.conn
+		}
+	}
+	s.mu.Unlock()
+
+	for id, conn := range dead {
+		log.Println("evicting unresponsive client", id)
+		s.detachClient(id, conn)
+	}
+}
+
+// handleHeartbeat records the round trip time for a pong or answers a ping
+// from the client
+func (s *Session) handleHeartbeat(ctx context.Context, clientID string, hb *heartbeat.Heartbeat) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
+
+	if hb.GetType() == heartbeat.Heartbeat_HEARTBEAT_TYPE_PONG {
+		if !client.pingSent.IsZero() {
+			client.rtt = time.Since(client.pingSent)
+			client.pingSent = time.Time{}
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	conn, p := client.conn, s.watchedLocked(client)
+	s.mu.Unlock()
+
+	pong := base.GenerateHeartbeatResp()
+	payload, err := base.EncodePayload(common.Header_HEADER_HEARTBEAT, &pong)
+	if err != nil {
+		return err
+	}
+
+	// the pong goes through the client's queue so it can't end up in the
+	// middle of another frame
+	return p.Send(conn, payload)
+}
+
+// markSeen refreshes the last time we heard anything from the client;
+// any traffic counts as proof of life and not just heartbeats
+func (s *Session) markSeen(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client, ok := s.clients[clientID]; ok {
+		client.lastSeen = time.Now()
+	}
+}
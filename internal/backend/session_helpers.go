@@ -7,49 +7,39 @@ import (
 	"log"
 	"net"
 	"time"
-	"willofdaedalus/superluminal/internal/payload/auth"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
 	"willofdaedalus/superluminal/internal/payload/info"
 	"willofdaedalus/superluminal/internal/utils"
 )
 
-// genPassAndHash generates, hashes and returns a new pass and hash
-func genPassAndHash(count int) (string, string, error) {
-	pass, err := utils.GeneratePassphrase(count)
-	if err != nil {
-		return "", "", err
-	}
-
-	hash, err := utils.HashPassphrase(pass)
-	if err != nil {
-		return "", "", err
-	}
-
-	return pass, hash, nil
-}
-
-// authenticateClient exchanges authentication with the client by sending an auth
-// request to the client upon successful connection
-// it tries to read from the client up to a minute and if no activity such as an
-// auth response, close the client with a message otherwise for every wrong passphrase
-// reset the wait timeout up to 3x
-func (s *Session) authenticateClient(ctx context.Context, conn net.Conn) (string, error) {
-	authReq := base.GenerateAuthReq()
-	authPayload, err := base.EncodePayload(common.Header_HEADER_AUTH, authReq)
-	if err != nil {
-		return "", err
-	}
+// authenticateClient runs a key exchange with the client using the session's
+// passphrase. the passphrase itself is never sent; the client proves it knows it
+// by deriving the same keys. if no answer comes within the timeout the client is
+// closed with a message otherwise every wrong passphrase gets a fresh exchange up
+// to 3x. a client taking back its place in the session asks to resume instead and
+// the exchange is redone with its resume secret in place of the passphrase
+func (s *Session) authenticateClient(ctx context.Context, conn net.Conn) (*authResult, error) {
+	secret := s.pass
+	var resumed *sessionClient
 
-	return s.tryValidateClientPass(ctx, conn, authPayload)
-}
-
-func (s *Session) tryValidateClientPass(ctx context.Context, conn net.Conn, authPayload []byte) (string, error) {
 	for try := 0; try < maxAuthChances; try++ {
 		log.Println("try no", try)
 
+		// every try needs a fresh exchange; reusing one would give a client
+		// pretending to be someone else more than one guess at it
+		pake, err := utils.NewPake(utils.PakeServer, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		authPayload, err := base.EncodePayload(common.Header_HEADER_AUTH, base.GenerateAuthReq(pake.Message()))
+		if err != nil {
+			return nil, err
+		}
+
 		tempCtx, cancel := context.WithTimeout(ctx, clientKickTimeout)
-		err := utils.WriteFull(tempCtx, conn, s.tracker, authPayload)
+		err = utils.WriteFull(tempCtx, conn, s.tracker, authPayload)
 		cancel()
 		if err != nil {
 			if errors.Is(err, utils.ErrCtxTimeOut) {
@@ -57,41 +47,86 @@ func (s *Session) tryValidateClientPass(ctx context.Context, conn net.Conn, auth
 				continue
 			}
 			// let handleNewConn handle the error; send it upstream
-			return "", err
+			return nil, err
 		}
 
 		clientResp, err := utils.ReadFull(ctx, conn, s.tracker)
 		if err != nil {
-			// if errors.Is(err, utils.ErrCtxTimeOut) {
-			// 	continue
-			// }
-			return "", err
+			return nil, err
 		}
 
-		authPayload, err := base.DecodePayload(clientResp)
+		respPayload, err := base.DecodePayload(clientResp)
 		if err != nil {
-			return "", fmt.Errorf("failed to decode payload %v", err)
+			return nil, fmt.Errorf("failed to decode payload %v", err)
 		}
 
-		if authPayload.GetHeader() != common.Header_HEADER_AUTH {
-			return "", utils.ErrInvalidHeader
+		if respPayload.GetHeader() == common.Header_HEADER_INFO &&
+			respPayload.GetInfo().GetInfoType() == info.Info_INFO_SHUTDOWN {
+			return nil, utils.ErrClientEarlyExit
+		}
+		if respPayload.GetHeader() != common.Header_HEADER_AUTH {
+			return nil, utils.ErrInvalidHeader
 		}
 
 		// extract an auth response
-		authResp, ok := authPayload.Content.(*base.Payload_Auth).Auth.AuthType.(*auth.Authentication_Response)
+		authResp := respPayload.GetAuth().GetResponse()
+		if authResp == nil {
+			return nil, fmt.Errorf("received wrong response")
+		}
+
+		// there's no point asking for the passphrase again if we can't
+		// understand each other afterwards
+		negotiated, ok := base.NegotiateWithClient(authResp)
 		if !ok {
-			if authPayload.Content.(*base.Payload_Info).Info.InfoType == info.Info_INFO_SHUTDOWN {
-				return "", utils.ErrClientEarlyExit
+			return &authResult{
+				name:    authResp.GetUsername(),
+				peerMin: authResp.GetMinVersion(),
+				peerMax: authResp.GetMaxVersion(),
+			}, utils.ErrVersionMismatch
+		}
+
+		if resumeID := authResp.GetResumeId(); resumeID != "" && resumed == nil {
+			resumed = s.findResumable(resumeID)
+			if resumed == nil {
+				return nil, utils.ErrResumeFailed
 			}
-			return "", fmt.Errorf("received wrong response")
+
+			s.mu.Lock()
+			secret = resumed.resumeSecret
+			s.mu.Unlock()
+			// asking to resume doesn't use up one of the client's tries
+			try -= 1
+			continue
 		}
 
-		if utils.CheckPassphrase(s.hash, authResp.Response.GetPassphrase()) {
-			return authResp.Response.GetUsername(), nil
+		// the offers went over in the clear so they're bound into the exchange
+		// where changing them makes the confirmations fail
+		keys, err := pake.Finish(authResp.GetPakeMessage(), negotiated.Transcript())
+		if err != nil {
+			log.Println("client sent a bad key exchange message:", err)
+			continue
+		}
+
+		if utils.ConfirmationMatches(authResp.GetConfirmation(), keys.ClientConfirm) {
+			return &authResult{
+				name:    authResp.GetUsername(),
+				keys:    keys,
+				resumed: resumed,
+				version: negotiated.Version,
+				caps:    negotiated.Caps,
+			}, nil
 		}
 	}
 
-	return "", utils.ErrFailedServerAuth
+	return nil, utils.ErrFailedServerAuth
+}
+
+// versionRange describes the protocol versions from min to max
+func versionRange(minVersion, maxVersion uint32) string {
+	if minVersion == maxVersion {
+		return fmt.Sprintf("version %d", minVersion)
+	}
+	return fmt.Sprintf("versions %d-%d", minVersion, maxVersion)
 }
 
 // generate a random passphrase
@@ -102,7 +137,7 @@ func (s *Session) regenPassLoop(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			s.pass, s.hash, _ = genPassAndHash(debugPassCount)
+			s.pass, _ = utils.GeneratePassphrase(debugPassCount)
 			fmt.Println(s.pass)
 		case <-ctx.Done():
 			return
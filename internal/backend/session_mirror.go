@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"willofdaedalus/superluminal/internal/pipeline"
+)
+
+// NewTmuxSession creates a session that mirrors a pane of a tmux session the
+// owner already has running instead of starting a shell. What's typed in the
+// session goes to the pane and the session ends when the pane does
+func NewTmuxSession(owner string, maxConns uint8, target string) (*Session, error) {
+	p, err := pipeline.NewTmuxPipeline(maxConns, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSourceSession(owner, maxConns, "tmux "+target, p)
+}
+
+// NewMirrorSession creates a session that mirrors the terminal output another
+// program is writing to path like a log from script -f or a fifo from tmux's
+// pipe-pane. Clients can only watch
+func NewMirrorSession(owner string, maxConns uint8, path string) (*Session, error) {
+	p, err := pipeline.NewMirrorPipeline(maxConns, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSourceSession(owner, maxConns, path, p)
+}
+
+func newSourceSession(owner string, maxConns uint8, name string, p *pipeline.Pipeline) (*Session, error) {
+	s, err := newSession(owner, maxConns, name, p)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,246 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"willofdaedalus/superluminal/internal/utils"
+
+	err1 "willofdaedalus/superluminal/internal/payload/error"
+)
+
+// remoteIP is the address a connection came from without the port so a
+// banned client can't get back in just by reconnecting
+func remoteIP(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// banReason reports whether the address or name is banned and why
+func (s *Session) banReason(addr, name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reason, ok := s.bannedAddrs[addr]; ok && addr != "" {
+		return reason, true
+	}
+	if reason, ok := s.bannedNames[name]; ok && name != "" {
+		return reason, true
+	}
+
+	return "", false
+}
+
+// findMember looks up a client that's in the session or has a place held for
+// it by its id or a name only it goes by
+func (s *Session) findMember(ref string) (*sessionClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return matchClient(ref, s.clients, s.detached)
+}
+
+// expel takes a client out of the session for good telling it why. it can't
+// resume either since its place goes with it
+func (s *Session) expel(clientID string, code err1.ErrorMessage_ErrorCode, detail string) error {
+	s.mu.Lock()
+	client, connected := s.clients[clientID]
+	if !connected {
+		client = s.detached[clientID]
+	}
+	if client == nil || client.isOwner {
+		s.mu.Unlock()
+		return utils.ErrNoSuchClient
+	}
+
+	delete(s.clients, clientID)
+	delete(s.detached, clientID)
+	client.resumeID = ""
+	s.mu.Unlock()
+
+	if !connected {
+		return nil
+	}
+
+	// unsubscribing first means nothing else is written to the client after
+	// it's told why it's leaving
+	s.mu.Lock()
+	s.unsubscribeLocked(client.conn)
+	s.mu.Unlock()
+	label := "kicked"
+	if code == err1.ErrorMessage_ERROR_BANNED {
+		label = "banned"
+	}
+	s.kickClient(context.Background(), client.conn, code, []string{label, detail})
+	client.conn.Close()
+
+	return nil
+}
+
+func withReason(msg, reason string) string {
+	if reason == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", msg, reason)
+}
+
+// kick removes a client from the session. it can join again with the passphrase
+func (s *Session) kick(clientID, reason string) error {
+	return s.expel(clientID, err1.ErrorMessage_ERROR_KICKED,
+		withReason("the host removed you from the session", reason))
+}
+
+// ban removes a client from the session and keeps its address and name out
+func (s *Session) ban(clientID, reason string) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	if !ok {
+		client, ok = s.detached[clientID]
+	}
+	if !ok || client.isOwner {
+		s.mu.Unlock()
+		return utils.ErrNoSuchClient
+	}
+
+	s.banLocked(remoteIP(client.conn), client.name, reason)
+	s.mu.Unlock()
+
+	return s.expel(clientID, err1.ErrorMessage_ERROR_BANNED,
+		withReason("the host banned you from the session", reason))
+}
+
+// banLocked adds an address and name to the ban list; either can be empty.
+// must be called with s.mu held
+func (s *Session) banLocked(addr, name, reason string) {
+	if s.bannedAddrs == nil {
+		s.bannedAddrs = make(map[string]string)
+	}
+	if s.bannedNames == nil {
+		s.bannedNames = make(map[string]string)
+	}
+
+	if addr != "" {
+		s.bannedAddrs[addr] = reason
+	}
+	if name != "" {
+		s.bannedNames[name] = reason
+	}
+}
+
+// unban lets an address or name back in
+func (s *Session) unban(ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, addr := s.bannedAddrs[ref]
+	_, name := s.bannedNames[ref]
+	if !addr && !name {
+		return fmt.Errorf("%s isn't banned", ref)
+	}
+
+	delete(s.bannedAddrs, ref)
+	delete(s.bannedNames, ref)
+	return nil
+}
+
+// banned returns every ban as kind, address or name and reason
+func (s *Session) banned() [][3]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bans := make([][3]string, 0, len(s.bannedAddrs)+len(s.bannedNames))
+	for addr, reason := range s.bannedAddrs {
+		bans = append(bans, [3]string{"address", addr, reason})
+	}
+	for name, reason := range s.bannedNames {
+		bans = append(bans, [3]string{"name", name, reason})
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i][0] != bans[j][0] {
+			return bans[i][0] < bans[j][0]
+		}
+		return bans[i][1] < bans[j][1]
+	})
+
+	return bans
+}
+
+func (s *Session) kickCommand(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("expected a name or id from list")
+	}
+
+	client, err := s.findMember(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.kick(client.uuid, strings.Join(args[1:], " ")); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("kicked %s", client.name), nil
+}
+
+func (s *Session) banCommand(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("expected a name or id from list or an address")
+	}
+	reason := strings.Join(args[1:], " ")
+
+	client, err := s.findMember(args[0])
+	if err != nil {
+		// not someone in the session so it has to be an address to keep out
+		if net.ParseIP(args[0]) == nil {
+			return "", err
+		}
+
+		s.mu.Lock()
+		s.banLocked(args[0], "", reason)
+		s.mu.Unlock()
+		return fmt.Sprintf("banned %s", args[0]), nil
+	}
+
+	addr := remoteIP(client.conn)
+	if err := s.ban(client.uuid, reason); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("banned %s (%s)", client.name, addr), nil
+}
+
+func (s *Session) unbanCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a name or address from bans")
+	}
+
+	if err := s.unban(args[0]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("unbanned %s", args[0]), nil
+}
+
+func (s *Session) listBans() string {
+	bans := s.banned()
+	if len(bans) == 0 {
+		return "nobody's banned"
+	}
+
+	var b strings.Builder
+	for _, ban := range bans {
+		fmt.Fprintf(&b, "  %s %s", ban[0], ban[1])
+		if ban[2] != "" {
+			fmt.Fprintf(&b, " (%s)", ban[2])
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
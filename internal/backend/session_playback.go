@@ -0,0 +1,21 @@
+package backend
+
+import (
+	"willofdaedalus/superluminal/internal/pipeline"
+)
+
+// NewPlaybackSession creates a session that streams a recording to its clients
+// as if it was being typed live. The host controls playback with the player's
+// keys and the session ends when the recording does
+func NewPlaybackSession(owner string, maxConns uint8, cast *pipeline.Cast, opts pipeline.PlaybackOptions) (*Session, error) {
+	p := pipeline.NewPlaybackPipeline(maxConns, cast, opts)
+
+	s, err := newSession(owner, maxConns, "playback", p)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	s.playback = true
+
+	return s, nil
+}
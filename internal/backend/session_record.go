@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+)
+
+// recordingName is where a recording goes when the host doesn't say
+func recordingName() string {
+	return fmt.Sprintf("superluminal-%s.cast", time.Now().Format("20060102-150405"))
+}
+
+func (s *Session) recordCommand(args []string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("expected a file name or stop")
+	}
+
+	if len(args) == 1 && args[0] == "stop" {
+		path, err := s.StopRecording()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("saved the recording to %s", path), nil
+	}
+
+	if len(args) == 0 {
+		if path := s.GetRecording(); path != "" {
+			return fmt.Sprintf("recording to %s; type record stop to finish", path), nil
+		}
+		args = append(args, recordingName())
+	}
+
+	if err := s.StartRecording(args[0]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("recording to %s", args[0]), nil
+}
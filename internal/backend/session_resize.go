@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// resizeLoop follows the size of the host's terminal so that full screen programs
+// running in the pty and every client see the same geometry as the host
+func (s *Session) resizeLoop(ctx context.Context) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// nothing to follow; the pty keeps whatever size it started with
+		return
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-winch:
+			cols, rows, err := term.GetSize(fd)
+			if err != nil {
+				log.Println("couldn't get the terminal size:", err)
+				continue
+			}
+
+			// every window is drawn on the same screen
+			for _, p := range s.pipelines() {
+				if err := p.Resize(rows, cols); err != nil {
+					log.Println("couldn't resize the pty:", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+const (
+	resumeIDLen     = 16
+	resumeSecretLen = 32
+)
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// welcome tells a client it's in the session along with a fresh resume token
+// and starts streaming to it. the token changes every time so an old one can't
+// be used to get back in. clients that can't resume don't get one and lose
+// their place as soon as they drop
+func (s *Session) welcome(ctx context.Context, client *sessionClient, msg string) error {
+	s.mu.Lock()
+	canResume := client.caps.Has(auth.Capability_CAPABILITY_RESUME)
+	opts := s.consumerOptionsLocked(client)
+	s.mu.Unlock()
+
+	welcome := base.GenerateInfo(info.Info_INFO_AUTH_SUCCESS, msg)
+	if canResume {
+		id, err := randomHex(resumeIDLen)
+		if err != nil {
+			return err
+		}
+		secret, err := randomHex(resumeSecretLen)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		client.resumeID = id
+		client.resumeSecret = secret
+		s.mu.Unlock()
+		welcome = base.GenerateAuthSuccess(msg, id, secret)
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO, welcome)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.WriteFull(ctx, client.conn, s.tracker, payload); err != nil {
+		return err
+	}
+
+	// subscribing sends the client a keyframe of the current screen first so
+	// it doesn't start off with half a screen
+	s.watched(client).SubscribeWith(client.conn, opts)
+	s.sendWindowList(client)
+	return nil
+}
+
+// detachClient takes a client whose connection dropped out of the session but
+// holds on to its place for the resume grace period so it can come back without
+// the passphrase. conn is the connection that dropped; if the client has already
+// come back on a new one there's nothing to do
+func (s *Session) detachClient(clientID string, conn net.Conn) {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	if !ok || client.isOwner || client.conn != conn {
+		s.mu.Unlock()
+		return
+	}
+
+	s.unsubscribeLocked(conn)
+	conn.Close()
+	delete(s.clients, clientID)
+
+	grace := s.resumeGrace
+	if grace <= 0 || client.resumeID == "" {
+		s.mu.Unlock()
+		return
+	}
+
+	if s.detached == nil {
+		s.detached = make(map[string]*sessionClient)
+	}
+	client.detachedAt = time.Now()
+	s.detached[clientID] = client
+	s.mu.Unlock()
+
+	s.notifyHost(fmt.Sprintf("%s dropped; holding its place for %s", client.name, grace))
+}
+
+// findResumable returns the client the resume id was handed out to. it can
+// still be in the session if we haven't noticed its old connection drop yet
+func (s *Session) findResumable(resumeID string) *sessionClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resumeID == "" {
+		return nil
+	}
+
+	for _, c := range s.detached {
+		if c.resumeID == resumeID {
+			return c
+		}
+	}
+	for _, c := range s.clients {
+		if !c.isOwner && c.resumeID == resumeID {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// resumeClient puts a client back in the session on its new connection. if the
+// session hadn't noticed the old one drop yet it's closed now
+func (s *Session) resumeClient(client *sessionClient, conn net.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.detached[client.uuid]; ok {
+		delete(s.detached, client.uuid)
+	} else if cur, ok := s.clients[client.uuid]; ok && cur == client {
+		s.unsubscribeLocked(client.conn)
+		client.conn.Close()
+	} else {
+		// its place ran out while it was authenticating
+		return utils.ErrResumeFailed
+	}
+
+	client.conn = conn
+	client.lastSeen = time.Now()
+	client.pingSent = time.Time{}
+	client.detachedAt = time.Time{}
+	s.clients[client.uuid] = client
+
+	return nil
+}
+
+// dropExpiredDetached gives up the places of clients that didn't come back
+// within the resume grace period
+func (s *Session) dropExpiredDetached() {
+	s.mu.Lock()
+	expired := make([]string, 0)
+	for id, c := range s.detached {
+		if time.Since(c.detachedAt) > s.resumeGrace {
+			expired = append(expired, c.name)
+			delete(s.detached, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, name := range expired {
+		log.Printf("%s didn't come back in time", name)
+		s.notifyHost(fmt.Sprintf("%s didn't come back in time; its place is free", name))
+	}
+}
+
+func (s *Session) hasDetached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.detached) > 0
+}
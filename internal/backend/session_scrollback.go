@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"fmt"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/scrollback"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// handleScrollbackReq sends a client the lines that scrolled off the screen of
+// the window it's watching that it asked for
+func (s *Session) handleScrollbackReq(clientID string, req *scrollback.ScrollbackRequest) error {
+	s.mu.Lock()
+	client, ok := s.clients[clientID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("failed to find client in handleScrollbackReq")
+	}
+	if !client.caps.Has(auth.Capability_CAPABILITY_SCROLLBACK) {
+		return utils.ErrNotSupported
+	}
+
+	p := s.watched(client)
+	var lines [][]byte
+	var first, total uint64
+	if req.GetLast() > 0 {
+		lines, first, total = p.LastScrollback(int(req.GetLast()))
+	} else {
+		lines, first, total = p.Scrollback(req.GetFrom(), req.GetTo())
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_SCROLLBACK, base.GenerateScrollback(first, total, lines))
+	if err != nil {
+		return err
+	}
+
+	// it goes through the client's queue so the keyframe can't overtake it
+	if err := p.Send(client.conn, payload); err != nil {
+		return err
+	}
+	if req.GetRedraw() {
+		return p.SendKeyframe(client.conn)
+	}
+
+	return nil
+}
@@ -2,15 +2,24 @@ package backend
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net"
 	"os"
-	"sync"
+	"strings"
 	"testing"
 	"time"
+	"willofdaedalus/superluminal/internal/payload/auth"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/heartbeat"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/payload/input"
 	"willofdaedalus/superluminal/internal/pipeline"
 	"willofdaedalus/superluminal/internal/utils"
+
+	err1 "willofdaedalus/superluminal/internal/payload/error"
+	winpb "willofdaedalus/superluminal/internal/payload/window"
 )
 
 const (
@@ -30,8 +39,6 @@ func TestNewSession(t *testing.T) {
 }
 
 func TestSessionFullMessage(t *testing.T) {
-	payloadBytes := make([]byte, 256)
-
 	testSession, err := NewSession(adminName, 1)
 	if err != nil {
 		t.Fatalf("%v", err)
@@ -50,16 +57,17 @@ func TestSessionFullMessage(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 
-	n, err := conn.Read(payloadBytes)
+	// payloads are prefixed with their length on the wire
+	payloadBytes, err := utils.ReadFull(context.Background(), conn, utils.NewSyncTracker())
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	payload, err := base.DecodePayload(payloadBytes[:n])
+	payload, err := base.DecodePayload(payloadBytes)
 	if err != nil {
 		t.Fatal("couldn't decoded the payload\n")
 	}
-	t.Log(n)
+	t.Log(len(payloadBytes))
 
 	if payload.Header != common.Header_HEADER_ERROR {
 		t.Fatalf("expected error header got %s", payload.GetHeader().String())
@@ -83,12 +91,10 @@ func TestSession_Start(t *testing.T) {
 		Owner         string
 		maxConns      uint8
 		pass          string
-		hash          string
 		clients       map[string]*sessionClient
 		pipeline      *pipeline.Pipeline
 		listener      net.Listener
 		signals       []os.Signal
-		mu            sync.Mutex
 		tracker       *utils.SyncTracker
 		passRegenTime time.Duration
 		heartbeatTime time.Duration
@@ -106,12 +112,10 @@ func TestSession_Start(t *testing.T) {
 				Owner:         tt.fields.Owner,
 				maxConns:      tt.fields.maxConns,
 				pass:          tt.fields.pass,
-				hash:          tt.fields.hash,
 				clients:       tt.fields.clients,
 				pipeline:      tt.fields.pipeline,
 				listener:      tt.fields.listener,
 				signals:       tt.fields.signals,
-				mu:            tt.fields.mu,
 				tracker:       tt.fields.tracker,
 				passRegenTime: tt.fields.passRegenTime,
 				heartbeatTime: tt.fields.heartbeatTime,
@@ -128,12 +132,10 @@ func TestSession_End(t *testing.T) {
 		Owner         string
 		maxConns      uint8
 		pass          string
-		hash          string
 		clients       map[string]*sessionClient
 		pipeline      *pipeline.Pipeline
 		listener      net.Listener
 		signals       []os.Signal
-		mu            sync.Mutex
 		tracker       *utils.SyncTracker
 		passRegenTime time.Duration
 		heartbeatTime time.Duration
@@ -151,12 +153,10 @@ func TestSession_End(t *testing.T) {
 				Owner:         tt.fields.Owner,
 				maxConns:      tt.fields.maxConns,
 				pass:          tt.fields.pass,
-				hash:          tt.fields.hash,
 				clients:       tt.fields.clients,
 				pipeline:      tt.fields.pipeline,
 				listener:      tt.fields.listener,
 				signals:       tt.fields.signals,
-				mu:            tt.fields.mu,
 				tracker:       tt.fields.tracker,
 				passRegenTime: tt.fields.passRegenTime,
 				heartbeatTime: tt.fields.heartbeatTime,
@@ -167,3 +167,919 @@ func TestSession_End(t *testing.T) {
 		})
 	}
 }
+
+func TestClientsHearHowTheSessionEnded(t *testing.T) {
+	p, err := pipeline.NewCommandPipeline(1, pipeline.Command{Args: []string{"sh", "-c", "read line; exit 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{
+		clients:       make(map[string]*sessionClient),
+		listener:      listener,
+		tracker:       utils.NewSyncTracker(),
+		heartbeatTime: heartbeatTimeout,
+		passRegenTime: passRegenTimeout,
+		// without any every signal ends the session
+		signals: []os.Signal{os.Interrupt},
+		ended:   make(chan struct{}),
+	}
+	s.addWindowLocked("sh", p)
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	c := createClient("hello", conn, false)
+	s.clients[c.uuid] = c
+	s.watched(c).Subscribe(conn)
+
+	// reading on the client's end isn't something the session waits on
+	tracker := utils.NewSyncTracker()
+	started := make(chan error, 1)
+	go func() { started <- s.Start() }()
+	p.WriteTo([]byte("\n"))
+
+	// whatever the program printed comes first
+	var exited *info.Info
+	for exited == nil {
+		data, err := utils.ReadFull(context.Background(), peer, tracker)
+		if err != nil {
+			t.Fatalf("expected to hear how the program exited got %v", err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i := payload.GetInfo(); i.GetInfoType() == info.Info_INFO_PROGRAM_EXITED {
+			exited = i
+		}
+	}
+	if exited.GetExitStatus() != 3 {
+		t.Fatalf("expected the program to exit with status 3 got %d", exited.GetExitStatus())
+	}
+
+	data, err := utils.ReadFull(context.Background(), peer, tracker)
+	if err != nil {
+		t.Fatalf("expected to be told the session is shutting down got %v", err)
+	}
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.GetInfo().GetInfoType() != info.Info_INFO_SHUTDOWN {
+		t.Fatalf("expected a shutdown notice got %v", payload)
+	}
+
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvictDeadClients(t *testing.T) {
+	alive, _ := net.Pipe()
+	dead, deadPeer := net.Pipe()
+	defer alive.Close()
+
+	stale := createClient("stale", dead, false)
+	stale.lastSeen = time.Now().Add(-time.Minute)
+	fresh := createClient("fresh", alive, false)
+	owner := createClient(adminName, nil, true)
+	owner.lastSeen = time.Now().Add(-time.Hour)
+
+	s := &Session{
+		clients: map[string]*sessionClient{
+			stale.uuid: stale,
+			fresh.uuid: fresh,
+			owner.uuid: owner,
+		},
+		pipeline:      &pipeline.Pipeline{},
+		heartbeatTime: heartbeatTimeout,
+	}
+
+	s.evictDeadClients()
+
+	if _, ok := s.clients[stale.uuid]; ok {
+		t.Fatal("expected stale client to be evicted")
+	}
+	if _, ok := s.clients[fresh.uuid]; !ok {
+		t.Fatal("fresh client shouldn't have been evicted")
+	}
+	if _, ok := s.clients[owner.uuid]; !ok {
+		t.Fatal("the owner should never be evicted")
+	}
+
+	// the evicted client's connection should have been closed
+	if _, err := deadPeer.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the evicted client's connection to be closed")
+	}
+}
+
+func TestHeartbeatsGoThroughTheQueue(t *testing.T) {
+	cast, err := pipeline.ReadCast(strings.NewReader(`{"version": 2, "width": 80, "height": 24}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{
+		clients:  make(map[string]*sessionClient),
+		pipeline: pipeline.NewPlaybackPipeline(1, cast, pipeline.PlaybackOptions{}),
+		tracker:  utils.NewSyncTracker(),
+	}
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	c := createClient("hello", conn, false)
+	s.clients[c.uuid] = c
+	s.pipeline.Subscribe(conn)
+
+	next := func() *base.Payload {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), peer, s.tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+	next() // the size
+	next() // the keyframe
+
+	s.pingClients()
+	if hb := next().GetHeartbeat(); hb.GetType() != heartbeat.Heartbeat_HEARTBEAT_TYPE_PING {
+		t.Fatalf("expected a ping got %v", hb)
+	}
+	s.mu.Lock()
+	sent := c.pingSent
+	s.mu.Unlock()
+	if sent.IsZero() {
+		t.Fatal("expected the ping to be timed")
+	}
+
+	ping := base.GenerateHeartbeatReq()
+	if err := s.handleHeartbeat(context.Background(), c.uuid, ping.Heartbeat); err != nil {
+		t.Fatal(err)
+	}
+	if hb := next().GetHeartbeat(); hb.GetType() != heartbeat.Heartbeat_HEARTBEAT_TYPE_PONG {
+		t.Fatalf("expected a pong got %v", hb)
+	}
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		pass    string
+		wantErr error
+	}{
+		{"right passphrase", "one two three", nil},
+		{"wrong passphrase", "three two one", utils.ErrFailedServerAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{pass: "one two three", tracker: utils.NewSyncTracker()}
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			// answer every auth request the way a client would
+			go func() {
+				ctx := context.Background()
+				for {
+					data, err := utils.ReadFull(ctx, client, s.tracker)
+					if err != nil {
+						return
+					}
+					req, err := base.DecodePayload(data)
+					if err != nil {
+						return
+					}
+
+					pake, _ := utils.NewPake(utils.PakeClient, tt.pass)
+					negotiated, _ := base.NegotiateWithServer(req.GetAuth().GetRequest(), base.DefaultCapabilities)
+					keys, err := pake.Finish(req.GetAuth().GetRequest().GetPakeMessage(), negotiated.Transcript())
+					if err != nil {
+						return
+					}
+
+					resp, _ := base.EncodePayload(common.Header_HEADER_AUTH,
+						base.GenerateAuthResp(adminName, pake.Message(), keys.ClientConfirm))
+					if err := utils.WriteFull(ctx, client, s.tracker, resp); err != nil {
+						return
+					}
+				}
+			}()
+
+			res, err := s.authenticateClient(context.Background(), server)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if res.name != adminName || res.keys == nil {
+				t.Fatalf("expected %s with keys got %q %v", adminName, res.name, res.keys)
+			}
+		})
+	}
+}
+
+func TestAuthenticateClientVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max uint32
+		caps     []auth.Capability
+		wantErr  error
+		wantCaps base.Capabilities
+	}{
+		{
+			name:     "same build",
+			min:      base.MinProtocolVersion,
+			max:      base.MaxProtocolVersion,
+			caps:     base.SupportedCapabilities.List(),
+			wantCaps: base.SupportedCapabilities,
+		},
+		{
+			name:     "no opt ins",
+			min:      base.MinProtocolVersion,
+			max:      base.MaxProtocolVersion,
+			caps:     base.DefaultCapabilities.List(),
+			wantCaps: base.DefaultCapabilities,
+		},
+		{
+			name:     "fewer capabilities",
+			min:      base.MinProtocolVersion,
+			max:      base.MaxProtocolVersion,
+			caps:     []auth.Capability{auth.Capability_CAPABILITY_INPUT, 63},
+			wantCaps: base.NewCapabilities(auth.Capability_CAPABILITY_INPUT),
+		},
+		{
+			name:    "too new",
+			min:     base.MaxProtocolVersion + 1,
+			max:     base.MaxProtocolVersion + 2,
+			wantErr: utils.ErrVersionMismatch,
+		},
+		{
+			// nothing would be bound into the key exchange
+			name:    "no offer",
+			caps:    base.SupportedCapabilities.List(),
+			wantErr: utils.ErrVersionMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{pass: "one two three", tracker: utils.NewSyncTracker()}
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			go func() {
+				ctx := context.Background()
+				data, err := utils.ReadFull(ctx, client, s.tracker)
+				if err != nil {
+					return
+				}
+				req, err := base.DecodePayload(data)
+				if err != nil {
+					return
+				}
+
+				content := base.GenerateAuthResp(adminName, nil, nil)
+				resp := content.Auth.GetResponse()
+				resp.MinVersion, resp.MaxVersion, resp.Capabilities = tt.min, tt.max, tt.caps
+
+				pake, _ := utils.NewPake(utils.PakeClient, s.pass)
+				negotiated, _ := base.NegotiateWithClient(resp)
+				keys, err := pake.Finish(req.GetAuth().GetRequest().GetPakeMessage(), negotiated.Transcript())
+				if err != nil {
+					return
+				}
+
+				resp.PakeMessage, resp.Confirmation = pake.Message(), keys.ClientConfirm
+				payload, _ := base.EncodePayload(common.Header_HEADER_AUTH, content)
+				utils.WriteFull(ctx, client, s.tracker, payload)
+			}()
+
+			res, err := s.authenticateClient(context.Background(), server)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				if res.peerMin != tt.min || res.peerMax != tt.max {
+					t.Fatalf("expected the client's versions %d-%d got %d-%d", tt.min, tt.max, res.peerMin, res.peerMax)
+				}
+				return
+			}
+			if res.version != base.MaxProtocolVersion || res.caps != tt.wantCaps {
+				t.Fatalf("expected version %d with %v got %d with %v",
+					base.MaxProtocolVersion, tt.wantCaps.List(), res.version, res.caps.List())
+			}
+		})
+	}
+}
+
+func TestAuthenticateClientDowngrade(t *testing.T) {
+	tests := []struct {
+		name string
+		// changes what one side sent before the other sees it
+		tamperReq  func(*auth.AuthRequest)
+		tamperResp func(*auth.AuthResponse)
+	}{
+		{
+			name: "client offer stripped",
+			tamperResp: func(resp *auth.AuthResponse) {
+				resp.Capabilities = []auth.Capability{auth.Capability_CAPABILITY_INPUT}
+			},
+		},
+		{
+			name: "session offer stripped",
+			tamperReq: func(req *auth.AuthRequest) {
+				req.Capabilities = []auth.Capability{auth.Capability_CAPABILITY_INPUT}
+			},
+		},
+		{
+			name: "client versions lowered",
+			tamperResp: func(resp *auth.AuthResponse) {
+				resp.MaxVersion = base.MinProtocolVersion
+				resp.MinVersion = base.MinProtocolVersion
+				resp.Capabilities = nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{pass: "one two three", tracker: utils.NewSyncTracker()}
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			go func() {
+				ctx := context.Background()
+				for {
+					data, err := utils.ReadFull(ctx, client, s.tracker)
+					if err != nil {
+						return
+					}
+					payload, err := base.DecodePayload(data)
+					if err != nil {
+						return
+					}
+					req := payload.GetAuth().GetRequest()
+					if tt.tamperReq != nil {
+						tt.tamperReq(req)
+					}
+
+					pake, _ := utils.NewPake(utils.PakeClient, s.pass)
+					negotiated, _ := base.NegotiateWithServer(req, base.DefaultCapabilities)
+					keys, err := pake.Finish(req.GetPakeMessage(), negotiated.Transcript())
+					if err != nil {
+						return
+					}
+
+					content := base.GenerateAuthResp(adminName, pake.Message(), keys.ClientConfirm)
+					if tt.tamperResp != nil {
+						tt.tamperResp(content.Auth.GetResponse())
+					}
+					resp, _ := base.EncodePayload(common.Header_HEADER_AUTH, content)
+					if err := utils.WriteFull(ctx, client, s.tracker, resp); err != nil {
+						return
+					}
+				}
+			}()
+
+			if _, err := s.authenticateClient(context.Background(), server); !errors.Is(err, utils.ErrFailedServerAuth) {
+				t.Fatalf("expected a changed offer to fail authentication got %v", err)
+			}
+		})
+	}
+}
+
+func TestWaitForApproval(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		wantErr error
+	}{
+		{"approved by number", "approve 1", time.Second * 5, nil},
+		{"approved by name", "approve " + adminName, time.Second * 5, nil},
+		{"denied", "deny 1", time.Second * 5, utils.ErrApprovalDenied},
+		{"timed out", "", time.Millisecond * 50, utils.ErrApprovalTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{
+				clients:      make(map[string]*sessionClient),
+				pending:      make(map[string]*pendingClient),
+				approvalTime: tt.timeout,
+				tracker:      utils.NewSyncTracker(),
+			}
+			server, client := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+
+			// the client should be told it's waiting
+			gotInfo := make(chan info.Info_InfoType, 1)
+			go func() {
+				data, err := utils.ReadFull(context.Background(), client, s.tracker)
+				if err != nil {
+					return
+				}
+				payload, _ := base.DecodePayload(data)
+				gotInfo <- payload.GetInfo().GetInfoType()
+			}()
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- s.waitForApproval(context.Background(), createClient(adminName, server, false))
+			}()
+
+			if got := <-gotInfo; got != info.Info_INFO_AWAITING_APPROVAL {
+				t.Fatalf("expected the client to be told to wait got %v", got)
+			}
+			if tt.command != "" {
+				if out := s.runCommand(tt.command); strings.Contains(out, ":") {
+					t.Fatalf("command failed: %s", out)
+				}
+			}
+
+			if err := <-errChan; !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v got %v", tt.wantErr, err)
+			}
+			if len(s.pendingClients()) != 0 {
+				t.Fatal("expected the client to leave the queue")
+			}
+		})
+	}
+}
+
+func TestApproveSameName(t *testing.T) {
+	s := &Session{
+		clients:      make(map[string]*sessionClient),
+		pending:      make(map[string]*pendingClient),
+		approvalTime: time.Second * 5,
+		tracker:      utils.NewSyncTracker(),
+	}
+
+	// wait queues a client named hello and returns what it's told
+	wait := func() (*sessionClient, chan error) {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		c := createClient("hello", server, false)
+
+		told := make(chan struct{})
+		go func() {
+			utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+			close(told)
+		}()
+		errChan := make(chan error, 1)
+		go func() { errChan <- s.waitForApproval(context.Background(), c) }()
+		<-told
+
+		return c, errChan
+	}
+	first, firstErr := wait()
+	second, secondErr := wait()
+
+	out := s.runCommand("approve hello")
+	if !strings.Contains(out, "more than one client") ||
+		!strings.Contains(out, shortID(first.uuid)) || !strings.Contains(out, shortID(second.uuid)) {
+		t.Fatalf("expected the name to be refused listing both clients got %q", out)
+	}
+	if len(s.pendingClients()) != 2 {
+		t.Fatal("expected nobody to be let in")
+	}
+	if out := s.listClients(); !strings.Contains(out, shortID(second.uuid)) {
+		t.Fatalf("expected list to show the ids got %q", out)
+	}
+
+	if out := s.runCommand("approve " + shortID(second.uuid)); out != "let hello in" {
+		t.Fatalf("unexpected output approving %q", out)
+	}
+	if err := <-secondErr; err != nil {
+		t.Fatalf("expected the client the host picked to be let in got %v", err)
+	}
+
+	if out := s.runCommand("deny " + first.uuid); out != "turned hello away" {
+		t.Fatalf("unexpected output denying %q", out)
+	}
+	if err := <-firstErr; !errors.Is(err, utils.ErrApprovalDenied) {
+		t.Fatalf("expected the other client to be turned away got %v", err)
+	}
+}
+
+func TestClientWriteAccess(t *testing.T) {
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	s := &Session{
+		clients:  make(map[string]*sessionClient),
+		pipeline: &pipeline.Pipeline{},
+	}
+	c := createClient(adminName, conn, false)
+	s.clients[c.uuid] = c
+
+	keys := &input.ClientInput{Data: []byte("ls\r")}
+	if err := s.handleClientInput(c.uuid, keys); !errors.Is(err, utils.ErrReadOnlyClient) {
+		t.Fatalf("expected input from a viewer to be dropped got %v", err)
+	}
+
+	if out := s.runCommand("grant " + adminName); !strings.Contains(out, "writer") {
+		t.Fatalf("unexpected output granting write access %q", out)
+	}
+	if err := s.handleClientInput(c.uuid, keys); err != nil {
+		t.Fatalf("expected input from a writer to go through got %v", err)
+	}
+
+	if out := s.runCommand("revoke " + adminName); !strings.Contains(out, "viewer") {
+		t.Fatalf("unexpected output revoking write access %q", out)
+	}
+	if err := s.handleClientInput(c.uuid, keys); !errors.Is(err, utils.ErrReadOnlyClient) {
+		t.Fatalf("expected input to be dropped after revoking got %v", err)
+	}
+
+	if out := s.runCommand("grant nobody"); !strings.Contains(out, utils.ErrNoSuchClient.Error()) {
+		t.Fatalf("expected an unknown client error got %q", out)
+	}
+}
+
+func TestFindClientByID(t *testing.T) {
+	s := &Session{
+		clients:  make(map[string]*sessionClient),
+		pipeline: &pipeline.Pipeline{},
+	}
+	var same []*sessionClient
+	for range 2 {
+		conn, _ := net.Pipe()
+		defer conn.Close()
+		c := createClient("hello", conn, false)
+		s.clients[c.uuid] = c
+		same = append(same, c)
+	}
+
+	// every client is shown with an id the host can pick it out by
+	list := s.runCommand("list")
+	for _, c := range same {
+		if !strings.Contains(list, shortID(c.uuid)) {
+			t.Fatalf("expected list to show %s got %q", shortID(c.uuid), list)
+		}
+	}
+
+	// a name more than one client goes by doesn't pick one of them at random
+	out := s.runCommand("grant hello")
+	if !strings.Contains(out, utils.ErrAmbiguousClient.Error()) ||
+		!strings.Contains(out, shortID(same[0].uuid)) || !strings.Contains(out, shortID(same[1].uuid)) {
+		t.Fatalf("expected the clients going by hello to be listed got %q", out)
+	}
+	for _, c := range same {
+		if c.role != roleViewer {
+			t.Fatal("expected nobody to be granted write access")
+		}
+	}
+
+	if out := s.runCommand("grant " + shortID(same[1].uuid)); !strings.Contains(out, "writer") {
+		t.Fatalf("unexpected output granting by id %q", out)
+	}
+	if same[0].role != roleViewer || same[1].role != roleWriter {
+		t.Fatal("expected only the client picked by id to be granted write access")
+	}
+
+	// naming yourself after someone else's id doesn't get you picked
+	conn, _ := net.Pipe()
+	defer conn.Close()
+	impostor := createClient(shortID(same[0].uuid), conn, false)
+	s.clients[impostor.uuid] = impostor
+	if c, err := s.findClient(shortID(same[0].uuid)); err != nil || c != same[0] {
+		t.Fatalf("expected the id to win over the name got %v %v", c, err)
+	}
+}
+
+func TestResumeClient(t *testing.T) {
+	old, oldPeer := net.Pipe()
+	defer oldPeer.Close()
+
+	c := createClient(adminName, old, false)
+	c.resumeID = "resume-id"
+	c.resumeSecret = "resume secret"
+	s := &Session{
+		pass:        "one two three",
+		maxConns:    1,
+		clients:     map[string]*sessionClient{c.uuid: c},
+		pipeline:    &pipeline.Pipeline{},
+		tracker:     utils.NewSyncTracker(),
+		resumeGrace: time.Minute,
+	}
+
+	// the connection dropping keeps the client's place
+	s.detachClient(c.uuid, old)
+	if _, ok := s.clients[c.uuid]; ok {
+		t.Fatal("expected the dropped client to leave the session")
+	}
+	if !s.isFull() {
+		t.Fatal("expected the held place to count towards the session being full")
+	}
+
+	authenticate := func(resumeID, secret string) (*authResult, error) {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		go func() {
+			ctx := context.Background()
+			asked := false
+			for {
+				data, err := utils.ReadFull(ctx, client, s.tracker)
+				if err != nil {
+					return
+				}
+				req, err := base.DecodePayload(data)
+				if err != nil {
+					return
+				}
+
+				resp := base.GenerateResumeReq(adminName, resumeID)
+				if asked {
+					pake, _ := utils.NewPake(utils.PakeClient, secret)
+					negotiated, _ := base.NegotiateWithServer(req.GetAuth().GetRequest(), base.DefaultCapabilities)
+					keys, err := pake.Finish(req.GetAuth().GetRequest().GetPakeMessage(), negotiated.Transcript())
+					if err != nil {
+						return
+					}
+					resp = base.GenerateAuthResp(adminName, pake.Message(), keys.ClientConfirm)
+				}
+				asked = true
+
+				payload, _ := base.EncodePayload(common.Header_HEADER_AUTH, resp)
+				if err := utils.WriteFull(ctx, client, s.tracker, payload); err != nil {
+					return
+				}
+			}
+		}()
+
+		return s.authenticateClient(context.Background(), server)
+	}
+
+	if _, err := authenticate("someone else", "resume secret"); !errors.Is(err, utils.ErrResumeFailed) {
+		t.Fatalf("expected an unknown resume id to fail got %v", err)
+	}
+	if _, err := authenticate("resume-id", "wrong secret"); !errors.Is(err, utils.ErrFailedServerAuth) {
+		t.Fatalf("expected the wrong secret to fail got %v", err)
+	}
+
+	res, err := authenticate("resume-id", "resume secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.resumed != c {
+		t.Fatal("expected to get the dropped client back")
+	}
+
+	newConn, _ := net.Pipe()
+	defer newConn.Close()
+	if err := s.resumeClient(res.resumed, newConn); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.clients[c.uuid]; !ok || got.conn != newConn {
+		t.Fatal("expected the client back in the session on its new connection")
+	}
+	if len(s.detached) != 0 {
+		t.Fatal("expected the held place to be taken back")
+	}
+
+	// a place that isn't taken back in time is given up
+	s.detachClient(c.uuid, newConn)
+	c.detachedAt = time.Now().Add(-2 * time.Minute)
+	s.dropExpiredDetached()
+	if s.findResumable("resume-id") != nil {
+		t.Fatal("expected the expired place to be given up")
+	}
+}
+
+func TestKickAndBan(t *testing.T) {
+	s := &Session{
+		clients:  make(map[string]*sessionClient),
+		pipeline: &pipeline.Pipeline{},
+		tracker:  utils.NewSyncTracker(),
+	}
+
+	// join adds a client and returns the other end of its connection
+	join := func(name string) net.Conn {
+		conn, peer := net.Pipe()
+		c := createClient(name, conn, false)
+		s.clients[c.uuid] = c
+		return peer
+	}
+
+	// removed runs a host command and returns what the client was told
+	removed := func(command string, peer net.Conn) (*base.Payload, string) {
+		out := make(chan string, 1)
+		go func() { out <- s.runCommand(command) }()
+
+		data, err := utils.ReadFull(context.Background(), peer, s.tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload, <-out
+	}
+
+	peer := join("troll")
+	payload, out := removed("kick troll being rude", peer)
+	if payload.GetError().GetCode() != err1.ErrorMessage_ERROR_KICKED {
+		t.Fatalf("expected a kicked error got %v", payload.GetError().GetCode())
+	}
+	if !strings.Contains(string(payload.GetError().GetDetail()), "being rude") {
+		t.Fatalf("expected the reason to reach the client got %q", payload.GetError().GetDetail())
+	}
+	if !strings.Contains(out, "kicked troll") || len(s.clients) != 0 {
+		t.Fatalf("expected troll to be kicked got %q", out)
+	}
+
+	peer = join("troll")
+	payload, out = removed("ban troll", peer)
+	if payload.GetError().GetCode() != err1.ErrorMessage_ERROR_BANNED {
+		t.Fatalf("expected a banned error got %v", payload.GetError().GetCode())
+	}
+	if !strings.Contains(out, "banned troll") {
+		t.Fatalf("unexpected output banning %q", out)
+	}
+	// net.Pipe connections all come from "pipe"
+	if _, banned := s.banReason("pipe", ""); !banned {
+		t.Fatal("expected the address to be banned")
+	}
+	if _, banned := s.banReason("", "troll"); !banned {
+		t.Fatal("expected the name to be banned")
+	}
+
+	// the address is turned away before it gets to authenticate
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	go s.handleNewConn(context.Background(), conn)
+	data, err := utils.ReadFull(context.Background(), peer, s.tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err = base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.GetError().GetCode() != err1.ErrorMessage_ERROR_BANNED {
+		t.Fatalf("expected a banned address to be turned away got %v", payload.GetHeader())
+	}
+
+	if out := s.runCommand("unban troll"); out != "unbanned troll" {
+		t.Fatalf("unexpected output unbanning %q", out)
+	}
+	if _, banned := s.banReason("", "troll"); banned {
+		t.Fatal("expected the name to be unbanned")
+	}
+	if _, banned := s.banReason("pipe", ""); !banned {
+		t.Fatal("expected the address to stay banned until it's unbanned too")
+	}
+	if out := s.runCommand("unban pipe"); out != "unbanned pipe" {
+		t.Fatalf("unexpected output unbanning %q", out)
+	}
+
+	// a name more than one client goes by can't pick out who to remove
+	first, second := join("hello"), join("hello")
+	defer first.Close()
+	defer second.Close()
+	for _, command := range []string{"kick hello", "ban hello"} {
+		out := s.runCommand(command)
+		if !strings.Contains(out, "more than one client") || len(s.clients) != 2 {
+			t.Fatalf("expected %q to be refused got %q", command, out)
+		}
+	}
+	if len(s.banned()) != 0 {
+		t.Fatalf("expected nobody to be banned got %v", s.banned())
+	}
+}
+
+func TestBannedNameTurnedAway(t *testing.T) {
+	s := &Session{
+		pass:        "one two three",
+		tracker:     utils.NewSyncTracker(),
+		bannedNames: map[string]string{"troll": "spamming"},
+	}
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handleNewConn(context.Background(), server)
+
+	next := func() *base.Payload {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), client, s.tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+
+	// the name is only checked once the client has authenticated
+	req := next().GetAuth().GetRequest()
+	pake, _ := utils.NewPake(utils.PakeClient, s.pass)
+	negotiated, _ := base.NegotiateWithServer(req, base.DefaultCapabilities)
+	keys, err := pake.Finish(req.GetPakeMessage(), negotiated.Transcript())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := base.EncodePayload(common.Header_HEADER_AUTH,
+		base.GenerateAuthResp("troll", pake.Message(), keys.ClientConfirm))
+	if err := utils.WriteFull(context.Background(), client, s.tracker, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := next()
+	if payload.GetError().GetCode() != err1.ErrorMessage_ERROR_BANNED {
+		t.Fatalf("expected a banned name to be turned away got %v", payload)
+	}
+	if !strings.Contains(string(payload.GetError().GetDetail()), "spamming") {
+		t.Fatalf("expected the reason to reach the client got %q", payload.GetError().GetDetail())
+	}
+}
+
+func TestWindows(t *testing.T) {
+	newWindow := func() *pipeline.Pipeline {
+		cast, err := pipeline.ReadCast(strings.NewReader(`{"version": 2, "width": 80, "height": 24}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pipeline.NewPlaybackPipeline(1, cast, pipeline.PlaybackOptions{})
+	}
+
+	s := &Session{
+		clients: make(map[string]*sessionClient),
+		tracker: utils.NewSyncTracker(),
+	}
+	s.addWindowLocked("editor", newWindow())
+	s.addWindowLocked("tests", newWindow())
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	c := createClient(adminName, conn, false)
+	s.clients[c.uuid] = c
+	s.watched(c).Subscribe(conn)
+
+	next := func() *base.Payload {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), peer, s.tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+	// expectWindow reads what a client is sent when it's moved to a window
+	expectWindow := func(watching, active uint32, windows int) {
+		t.Helper()
+		next() // the size
+		if kf := next().GetTermContent(); !kf.GetKeyframe() || kf.GetWindow() != watching {
+			t.Fatalf("expected a keyframe of window %d got %v", watching, kf)
+		}
+		list := next().GetWindowList()
+		if list.GetWatching() != watching || list.GetActive() != active || len(list.GetWindows()) != windows {
+			t.Fatalf("expected to watch %d with %d in front got %v", watching, active, list)
+		}
+	}
+
+	next()
+	if kf := next().GetTermContent(); kf.GetWindow() != 1 {
+		t.Fatalf("expected to start off watching window 1 got %d", kf.GetWindow())
+	}
+
+	// following the host
+	if out := s.runCommand("switch tests"); !strings.Contains(out, "switched") {
+		t.Fatalf("unexpected output switching windows %q", out)
+	}
+	expectWindow(2, 2, 2)
+
+	// pinned to a window the host isn't looking at
+	go s.selectWindow(c.uuid, &winpb.WindowSelect{Id: 1})
+	expectWindow(1, 2, 2)
+
+	// closing it puts the client back on the host's window
+	go s.CloseWindow("editor")
+	expectWindow(2, 2, 1)
+
+	if err := s.CloseWindow("tests"); !errors.Is(err, utils.ErrLastWindow) {
+		t.Fatalf("expected the last window to stay open got %v", err)
+	}
+	if err := s.SwitchWindow("nope"); !errors.Is(err, utils.ErrNoSuchWindow) {
+		t.Fatalf("expected an unknown window error got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// EnableTLS makes the session only accept clients over tls using the certificate
+// and key at the given paths, generating them if they don't exist. empty paths
+// use the ones in the superluminal config directory. it has to be called before
+// Start and returns the fingerprint clients will pin
+func (s *Session) EnableTLS(certFile, keyFile string) (string, error) {
+	if certFile == "" || keyFile == "" {
+		dir, err := utils.ConfigDir()
+		if err != nil {
+			return "", err
+		}
+		if certFile == "" {
+			certFile = filepath.Join(dir, "server.crt")
+		}
+		if keyFile == "" {
+			keyFile = filepath.Join(dir, "server.key")
+		}
+	}
+
+	cert, err := utils.LoadOrCreateCert(certFile, keyFile)
+	if err != nil {
+		return "", fmt.Errorf("couldn't load the session certificate: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fingerprint = utils.Fingerprint(cert.Certificate[0])
+	s.listener = tls.NewListener(s.listener, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	})
+
+	return s.fingerprint, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"sync"
 	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/pipeline"
 	"willofdaedalus/superluminal/internal/utils"
 )
@@ -12,20 +13,74 @@ import (
 type errMessage [2]string
 type clientUniqID string
 
+// clientRole is what a client is allowed to do in the session
+type clientRole int
+
+const (
+	roleViewer clientRole = iota + 1
+	roleWriter
+)
+
 type sessionClient struct {
-	name    string
-	pass    string
-	uuid    string
-	conn    net.Conn
-	joined  time.Time
-	isOwner bool
+	name     string
+	pass     string
+	uuid     string
+	conn     net.Conn
+	joined   time.Time
+	lastSeen time.Time
+	pingSent time.Time
+	rtt      time.Duration
+	role     clientRole
+	isOwner  bool
+	// the window the client is watching; 0 follows the host's
+	window uint32
+	// lets the client take back its place if its connection drops
+	resumeID     string
+	resumeSecret string
+	detachedAt   time.Time
+	// the protocol version and capabilities agreed on with the client
+	version uint32
+	caps    base.Capabilities
+}
+
+// window is one of the programs a session shares. clients watch one window at a
+// time and the host has one in front on its own screen
+type window struct {
+	id       uint32
+	name     string
+	pipeline *pipeline.Pipeline
+	// done gets a value when the program exits and closed is closed when the
+	// host closes the window
+	done   chan struct{}
+	closed chan struct{}
+}
+
+// authResult is who a client turned out to be once it's authenticated
+type authResult struct {
+	name string
+	keys *utils.PakeKeys
+	// set when the client is taking back its place in the session
+	resumed *sessionClient
+	// the protocol version and capabilities agreed on or the versions the
+	// client speaks if there wasn't one in common
+	version uint32
+	caps    base.Capabilities
+	peerMin uint32
+	peerMax uint32
+}
+
+// pendingClient has passed authentication and is waiting for the host to let it in
+type pendingClient struct {
+	client    *sessionClient
+	requested time.Time
+	decision  chan bool
 }
 
 type Session struct {
 	Owner         string
 	maxConns      uint8
 	pass          string
-	hash          string
+	fingerprint   string
 	clients       map[string]*sessionClient
 	pipeline      *pipeline.Pipeline
 	listener      net.Listener
@@ -34,4 +89,34 @@ type Session struct {
 	tracker       *utils.SyncTracker
 	passRegenTime time.Duration
 	heartbeatTime time.Duration
+	pending       map[string]*pendingClient
+	needsApproval bool
+	approvalTime  time.Duration
+	recordInput   bool
+	playback      bool
+	// clients whose connection dropped but still have a place in the session
+	detached    map[string]*sessionClient
+	resumeGrace time.Duration
+	// addresses and names the host banned and why
+	bannedAddrs map[string]string
+	bannedNames map[string]string
+	// the windows of the session; pipeline is always the one the host has in
+	// front and what the session works with when there's only one
+	windows      map[uint32]*window
+	activeWindow uint32
+	nextWindow   uint32
+	started      bool
+	// closed once the last window's program has exited
+	ended      chan struct{}
+	exitStatus int
+	exited     bool
+	// settings new windows pick up from the ones already open
+	policy         pipeline.OverflowPolicy
+	redacting      bool
+	redactPatterns []string
+	scrollback     int
+	batchWindow    time.Duration
+	frameRate      int
+	// whether terminal frames are deflated for clients that can take them
+	compression bool
 }
@@ -0,0 +1,492 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	winpb "willofdaedalus/superluminal/internal/payload/window"
+	"willofdaedalus/superluminal/internal/pipeline"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// windowName is what a window running cmd is called when the host doesn't say
+func windowName(cmd pipeline.Command) string {
+	if len(cmd.Args) == 0 {
+		return "shell"
+	}
+	return filepath.Base(cmd.Args[0])
+}
+
+// addWindowLocked makes p a window of the session. the first window is the one
+// the host has in front; the rest start off behind it. must be called with s.mu held
+func (s *Session) addWindowLocked(name string, p *pipeline.Pipeline) *window {
+	if s.windows == nil {
+		s.windows = make(map[uint32]*window)
+	}
+
+	s.nextWindow += 1
+	w := &window{
+		id:       s.nextWindow,
+		name:     name,
+		pipeline: p,
+		done:     make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	p.SetWindow(w.id)
+
+	if len(s.windows) == 0 {
+		s.activeWindow = w.id
+		s.pipeline = p
+	} else {
+		// new windows take after the ones already open
+		p.HideLocally()
+		if s.policy != 0 {
+			p.SetOverflowPolicy(s.policy)
+		}
+		p.SetScrollback(s.scrollback)
+		p.SetBatching(s.batchWindow, pipeline.DefaultBatchSize)
+		p.SetFrameRate(s.frameRate)
+		if s.redacting {
+			if r, err := pipeline.NewRedactor(s.redactPatterns...); err == nil {
+				p.SetFilter(r)
+			}
+		}
+		if s.pipeline != nil && s.pipeline.Paused() {
+			p.Pause()
+		}
+	}
+	s.windows[w.id] = w
+
+	return w
+}
+
+// startWindow runs the window's program and closes the window once it exits
+func (s *Session) startWindow(w *window) {
+	go w.pipeline.Start(w.done)
+	go s.watchWindow(w)
+}
+
+// watchWindow waits for the window's program to exit. the session ends with the
+// last window
+func (s *Session) watchWindow(w *window) {
+	select {
+	case <-w.closed:
+		return
+	case <-w.done:
+	}
+
+	status, _ := w.pipeline.ExitStatus()
+	last := false
+	s.changeWindows(func() error {
+		last = s.removeWindowLocked(w)
+		if last {
+			s.exitStatus = status
+			s.exited = true
+		}
+		return nil
+	})
+
+	if last {
+		close(s.ended)
+		return
+	}
+
+	w.pipeline.Close()
+	s.notifyHost(fmt.Sprintf("%s exited with status %d", w.name, status))
+}
+
+// removeWindowLocked takes w out of the session putting another window in front
+// if it was the host's. it reports whether w was the last window.
+// must be called with s.mu held
+func (s *Session) removeWindowLocked(w *window) bool {
+	if _, ok := s.windows[w.id]; !ok {
+		return false
+	}
+	if len(s.windows) == 1 {
+		// the session ends with its last window so there's nowhere to move
+		// anyone to
+		return true
+	}
+
+	delete(s.windows, w.id)
+	for _, c := range s.clients {
+		if c.window == w.id {
+			c.window = 0
+		}
+	}
+	if s.activeWindow == w.id {
+		next := s.windowIDsLocked()[0]
+		s.activeWindow = next
+		s.pipeline = s.windows[next].pipeline
+	}
+
+	return false
+}
+
+// windowIDsLocked returns the ids of the session's windows in the order they were
+// opened. must be called with s.mu held
+func (s *Session) windowIDsLocked() []uint32 {
+	ids := make([]uint32, 0, len(s.windows))
+	for id := range s.windows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+// findWindowLocked looks up a window by its name or id. must be called with s.mu held
+func (s *Session) findWindowLocked(ref string) (*window, error) {
+	if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		if w, ok := s.windows[uint32(id)]; ok {
+			return w, nil
+		}
+	}
+	for _, id := range s.windowIDsLocked() {
+		if s.windows[id].name == ref {
+			return s.windows[id], nil
+		}
+	}
+
+	return nil, utils.ErrNoSuchWindow
+}
+
+// watchedLocked returns the pipeline of the window client is watching.
+// must be called with s.mu held
+func (s *Session) watchedLocked(client *sessionClient) *pipeline.Pipeline {
+	if w, ok := s.windows[client.window]; ok {
+		return w.pipeline
+	}
+	return s.pipeline
+}
+
+// watched returns the pipeline of the window client is watching
+func (s *Session) watched(client *sessionClient) *pipeline.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watchedLocked(client)
+}
+
+// active returns the pipeline of the window the host has in front
+func (s *Session) active() *pipeline.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pipeline
+}
+
+// pipelinesLocked returns the pipelines of every window.
+// must be called with s.mu held
+func (s *Session) pipelinesLocked() []*pipeline.Pipeline {
+	if len(s.windows) == 0 {
+		return []*pipeline.Pipeline{s.pipeline}
+	}
+
+	all := make([]*pipeline.Pipeline, 0, len(s.windows))
+	for _, id := range s.windowIDsLocked() {
+		all = append(all, s.windows[id].pipeline)
+	}
+	return all
+}
+
+// pipelines returns the pipelines of every window
+func (s *Session) pipelines() []*pipeline.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pipelinesLocked()
+}
+
+// unsubscribeLocked stops sending conn whichever window it was watching.
+// must be called with s.mu held
+func (s *Session) unsubscribeLocked(conn net.Conn) {
+	for _, p := range s.pipelinesLocked() {
+		p.Unsubscribe(conn)
+	}
+}
+
+// consumerOptionsLocked returns how client wants its windows streamed to it.
+// must be called with s.mu held
+func (s *Session) consumerOptionsLocked(client *sessionClient) pipeline.ConsumerOptions {
+	return pipeline.ConsumerOptions{
+		Compress:    s.compression && client.caps.Has(auth.Capability_CAPABILITY_COMPRESSION),
+		ScreenDiffs: client.caps.Has(auth.Capability_CAPABILITY_SCREEN_DIFF),
+	}
+}
+
+// changeWindows makes a change to the session's windows and then moves every
+// client whose window changed because of it over to its new one
+func (s *Session) changeWindows(change func() error) error {
+	s.mu.Lock()
+	before := make(map[*sessionClient]*pipeline.Pipeline, len(s.clients))
+	for _, c := range s.clients {
+		if !c.isOwner {
+			before[c] = s.watchedLocked(c)
+		}
+	}
+	front := s.pipeline
+
+	if err := change(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	type move struct {
+		conn     net.Conn
+		from, to *pipeline.Pipeline
+		opts     pipeline.ConsumerOptions
+	}
+	moves := make([]move, 0)
+	for c, from := range before {
+		if to := s.watchedLocked(c); to != from {
+			moves = append(moves, move{c.conn, from, to, s.consumerOptionsLocked(c)})
+		}
+	}
+	newFront := s.pipeline
+	s.mu.Unlock()
+
+	if newFront != front {
+		front.HideLocally()
+		newFront.ShowLocally()
+	}
+	for _, m := range moves {
+		m.from.Unsubscribe(m.conn)
+		// subscribing sends the size and a keyframe of the new window first
+		m.to.SubscribeWith(m.conn, m.opts)
+	}
+
+	s.sendWindowLists()
+	return nil
+}
+
+// windowListLocked encodes the session's windows as client sees them.
+// must be called with s.mu held
+func (s *Session) windowListLocked(client *sessionClient) ([]byte, error) {
+	windows := make([]*winpb.Window, 0, len(s.windows))
+	for _, id := range s.windowIDsLocked() {
+		windows = append(windows, &winpb.Window{Id: id, Name: s.windows[id].name})
+	}
+
+	watching := s.activeWindow
+	if _, ok := s.windows[client.window]; ok {
+		watching = client.window
+	}
+
+	return base.EncodePayload(common.Header_HEADER_WINDOW_LIST,
+		base.GenerateWindowList(windows, s.activeWindow, watching))
+}
+
+// sendWindowList lets client know what windows there are and which it's watching.
+// it goes out behind the client's frames so it arrives after a switch's keyframe
+func (s *Session) sendWindowList(client *sessionClient) {
+	s.mu.Lock()
+	if !client.caps.Has(auth.Capability_CAPABILITY_WINDOWS) {
+		// it only ever sees the window the host has in front
+		s.mu.Unlock()
+		return
+	}
+	payload, err := s.windowListLocked(client)
+	p, conn := s.watchedLocked(client), client.conn
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Println("failed to encode the window list:", err)
+		return
+	}
+	if err := p.Send(conn, payload); err != nil {
+		log.Printf("couldn't send the window list to %s: %v", client.name, err)
+	}
+}
+
+// sendWindowLists sends every client the window list
+func (s *Session) sendWindowLists() {
+	s.mu.Lock()
+	clients := make([]*sessionClient, 0, len(s.clients))
+	for _, c := range s.clients {
+		if !c.isOwner {
+			clients = append(clients, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		s.sendWindowList(c)
+	}
+}
+
+// OpenWindow starts cmd in a new window of the session behind the one the host
+// has in front and returns its id
+func (s *Session) OpenWindow(name string, cmd pipeline.Command) (uint32, error) {
+	s.mu.Lock()
+	playback := s.playback
+	s.mu.Unlock()
+	if playback {
+		return 0, fmt.Errorf("can't open windows while playing a recording")
+	}
+
+	p, err := pipeline.NewCommandPipeline(s.maxConns, cmd)
+	if err != nil {
+		return 0, err
+	}
+	if rows, cols := s.active().Size(); rows > 0 && cols > 0 {
+		p.Resize(rows, cols)
+	}
+	if name == "" {
+		name = windowName(cmd)
+	}
+
+	var w *window
+	s.changeWindows(func() error {
+		w = s.addWindowLocked(name, p)
+		if s.started {
+			s.startWindow(w)
+		}
+		return nil
+	})
+
+	return w.id, nil
+}
+
+// CloseWindow ends the program in a window and moves everyone watching it to the
+// host's window. The last window can't be closed; the session ends with it
+func (s *Session) CloseWindow(ref string) error {
+	var w *window
+	err := s.changeWindows(func() error {
+		var err error
+		if w, err = s.findWindowLocked(ref); err != nil {
+			return err
+		}
+		if len(s.windows) == 1 {
+			return utils.ErrLastWindow
+		}
+
+		s.removeWindowLocked(w)
+		close(w.closed)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.pipeline.Close()
+	return nil
+}
+
+// SwitchWindow puts a window in front on the host's screen. Clients following
+// the host switch with it
+func (s *Session) SwitchWindow(ref string) error {
+	return s.changeWindows(func() error {
+		w, err := s.findWindowLocked(ref)
+		if err != nil {
+			return err
+		}
+
+		s.activeWindow = w.id
+		s.pipeline = w.pipeline
+		return nil
+	})
+}
+
+// selectWindow has a client watch window id or follow the host if follow is set
+func (s *Session) selectWindow(clientID string, sel *winpb.WindowSelect) error {
+	return s.changeWindows(func() error {
+		client, ok := s.clients[clientID]
+		if !ok {
+			return utils.ErrNoSuchClient
+		}
+		if !client.caps.Has(auth.Capability_CAPABILITY_WINDOWS) {
+			return utils.ErrNotSupported
+		}
+		if sel.GetFollow() {
+			client.window = 0
+			return nil
+		}
+		if _, ok := s.windows[sel.GetId()]; !ok {
+			return utils.ErrNoSuchWindow
+		}
+
+		client.window = sel.GetId()
+		return nil
+	})
+}
+
+// id, name, whether the host has it in front, how many clients are watching it
+func (s *Session) GetWindows() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allWindows := make([]string, 0, len(s.windows))
+	for _, id := range s.windowIDsLocked() {
+		w := s.windows[id]
+		watching := 0
+		for _, c := range s.clients {
+			if !c.isOwner && s.watchedLocked(c) == w.pipeline {
+				watching += 1
+			}
+		}
+
+		allWindows = append(allWindows, strings.Join([]string{
+			strconv.FormatUint(uint64(id), 10),
+			w.name,
+			strconv.FormatBool(id == s.activeWindow),
+			strconv.Itoa(watching),
+		}, "$$"))
+	}
+
+	return allWindows
+}
+
+// newWindowCommand opens a window running a command or the host's shell
+func (s *Session) newWindowCommand(args []string) (string, error) {
+	var name string
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	id, err := s.OpenWindow(name, pipeline.Command{Args: args})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("opened window %d; type switch %d to bring it to the front", id, id), nil
+}
+
+func (s *Session) closeWindowCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a window from windows")
+	}
+
+	if err := s.CloseWindow(args[0]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("closed window %s", args[0]), nil
+}
+
+func (s *Session) switchWindowCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a window from windows")
+	}
+
+	if err := s.SwitchWindow(args[0]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("switched to window %s", args[0]), nil
+}
+
+func (s *Session) listWindows() string {
+	var b strings.Builder
+	for _, w := range s.GetWindows() {
+		fields := strings.Split(w, "$$")
+		front := " "
+		if fields[2] == "true" {
+			front = "*"
+		}
+		fmt.Fprintf(&b, "%s %s. %s (%s watching)\n", front, fields[0], fields[1], fields[3])
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -14,27 +15,97 @@ import (
 	"time"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/payload/window"
+	"willofdaedalus/superluminal/internal/pipeline"
 	"willofdaedalus/superluminal/internal/utils"
+
+	"golang.org/x/term"
 )
 
 const (
 	maxConnTries = 3
+
+	serverHeartbeatTimeout = time.Second * 45
+	// how long we keep trying to get back into the session after losing the
+	// connection; the session holds our place for about this long
+	reconnectTimeout     = time.Minute * 2
+	reconnectBaseBackoff = time.Millisecond * 500
+	reconnectMaxBackoff  = time.Second * 15
+	// how many payloads can be read ahead of the one being processed
+	readQueueSize = 64
 )
 
+// pendingFrame is terminal data waiting for the frames before it to arrive
+type pendingFrame struct {
+	last uint64
+	data []byte
+}
+
 type Client struct {
 	TermContent chan string
 	name        string
 	joined      time.Time
+	host        string
 	serverConn  net.Conn
 	signals     []os.Signal
 	exitChan    chan struct{}
 	sigChan     chan os.Signal
 	// channel for bubbletea ui to send the password to the backend
-	bbltPass   chan string
-	SentPass   bool
-	isApproved bool
-	mu         sync.Mutex
-	tracker    *utils.SyncTracker
+	bbltPass      chan string
+	SentPass      bool
+	isApproved    bool
+	lastHeartbeat time.Time
+	// sequencing state for terminal frames
+	nextSeq     uint64
+	requestedTo uint64
+	pending     map[uint64]pendingFrame
+	out         io.Writer
+	// the session window the frames we're showing belong to and what we know
+	// about the session's windows
+	window   uint32
+	watching uint32
+	windows  []*window.Window
+	// size of the host's terminal and a way to get the size of ours
+	hostRows  uint32
+	hostCols  uint32
+	localSize func() (int, int, error)
+	// set when the session has to be reached over tls
+	knownHosts *knownHosts
+	// keys from the key exchange with the session
+	keys    *utils.PakeKeys
+	secured bool
+	// the protocol version and capabilities agreed on with the session and
+	// the ones we ask it for
+	version uint32
+	caps    base.Capabilities
+	wants   base.Capabilities
+	// what we worked out with the session from the offers while authenticating
+	negotiated base.Negotiation
+	// our copy of the session's screen when we're sent diffs of it
+	screen *screenModel
+	// turns the session's output into plain text when we're only printing that
+	transcript *pipeline.Transcript
+	// undoes the session's compression of terminal frames. only the reader
+	// touches it; inflateLost is set once a frame couldn't be inflated
+	inflater    *utils.Inflater
+	inflateLost bool
+	// closed once we're in the session
+	joinedChan chan struct{}
+	canWrite   bool
+	// how the session's program exited if it has
+	exitStatus int
+	// lets us back into the session without the passphrase if the connection
+	// drops; resuming is set while we're getting back in
+	resume      *info.ResumeToken
+	resuming    bool
+	resumeAsked bool
+	// terminal state from before we switched to raw mode
+	termState *term.State
+	termMu    sync.Mutex
+	altScreen bool
+	mu        sync.Mutex
+	tracker   *utils.SyncTracker
 }
 
 func New(name string) *Client {
@@ -49,18 +120,55 @@ func New(name string) *Client {
 		isApproved:  false,
 		bbltPass:    make(chan string, 1),
 		serverConn:  nil,
+		pending:     make(map[uint64]pendingFrame),
+		out:         os.Stdout,
+		localSize:   stdoutSize,
+		joinedChan:  make(chan struct{}),
 		tracker:     utils.NewSyncTracker(),
+		inflater:    utils.NewInflater(),
+		// until the session says otherwise it's taken to be this build
+		version: base.MaxProtocolVersion,
+		caps:    base.DefaultCapabilities,
+		wants:   base.DefaultCapabilities,
 	}
 }
 
+// UseTLS makes the client connect to sessions over tls, pinning their fingerprints
+// in the known hosts file at path. an empty path uses the one in the superluminal
+// config directory
+func (c *Client) UseTLS(knownHostsPath string) error {
+	kh, err := newKnownHosts(knownHostsPath)
+	if err != nil {
+		return err
+	}
+
+	c.knownHosts = kh
+	return nil
+}
+
 func (c *Client) ConnectToSession(host string) error {
 	var dialer net.Dialer
 	var err error
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
+	c.host = host
 
-	c.serverConn, err = dialer.DialContext(ctx, "tcp", host)
+	if c.knownHosts != nil {
+		tlsDialer := tls.Dialer{
+			NetDialer: &dialer,
+			Config: &tls.Config{
+				// the session's certificate is self signed so instead of checking
+				// it against a ca we pin its fingerprint the first time we see it
+				InsecureSkipVerify:    true,
+				VerifyPeerCertificate: c.knownHosts.verify(host),
+				MinVersion:            tls.VersionTLS13,
+			},
+		}
+		c.serverConn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	} else {
+		c.serverConn, err = dialer.DialContext(ctx, "tcp", host)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, io.EOF):
@@ -83,60 +191,24 @@ func (c *Client) ConnectToSession(host string) error {
 func (c *Client) ListenForMessages(errChan chan<- error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), c.signals...)
 	defer func() {
+		c.RestoreTerminal()
 		// cleanup has its own context timeout
 		c.startCleanup()
 		close(errChan)
 	}()
+	defer c.restoreOnPanic()
 
 	readErr := make(chan error, 1)
-	readData := make(chan []byte, 1)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
 
-	go func(wg *sync.WaitGroup) {
-		defer func() {
-			close(readErr)
-			close(readData)
-			wg.Done()
-		}()
+	go c.readLoop(ctx, &wg, readErr, errChan)
 
-		ticker := time.NewTicker(100 * time.Millisecond)
-		defer ticker.Stop()
+	go c.forwardInput(ctx)
 
-		for {
-			// check context cancellation first, before the select
-			if ctx.Err() != nil {
-				return
-			}
-
-			// use a select with a default case to prevent blocking
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				// attempt to read data with a timeout
-				read, err := utils.ReadFull(ctx, c.serverConn, c.tracker)
-				if err != nil {
-					select {
-					case readErr <- err:
-					default:
-					}
-					return
-				}
-				if read != nil {
-					select {
-					case readData <- read:
-					default:
-						fmt.Println("dropped message: readData is full")
-					}
-				}
-			default:
-				// allow immediate context cancellation check
-				time.Sleep(10 * time.Millisecond)
-			}
-		}
-	}(&wg)
+	watchdog := time.NewTicker(serverHeartbeatTimeout / 3)
+	defer watchdog.Stop()
 
 	// main loop
 	for {
@@ -144,11 +216,43 @@ func (c *Client) ListenForMessages(errChan chan<- error) {
 		case <-ctx.Done():
 			wg.Wait()
 			return
+		case <-watchdog.C:
+			if c.serverUnresponsive() {
+				log.Println("server stopped answering heartbeats")
+				if c.canResume() {
+					// the reader fails once the connection is closed and we
+					// try to get back in from there
+					c.serverConn.Close()
+					continue
+				}
+				select {
+				case errChan <- utils.ErrServerUnresponsive:
+				default:
+				}
+				cancel()
+				wg.Wait()
+				return
+			}
 		case <-c.exitChan:
 			cancel()
 			wg.Wait()
 			return
 		case err := <-readErr:
+			if err != nil && c.canResume() && ctx.Err() == nil {
+				log.Println("lost the connection to the session:", err)
+				// the reader deals with whatever came in before the connection
+				// dropped before it's done since it could be the session
+				// telling us why
+				wg.Wait()
+				if !c.canResume() {
+					continue
+				}
+				if err = c.reconnect(ctx); err == nil {
+					wg.Add(1)
+					go c.readLoop(ctx, &wg, readErr, errChan)
+					continue
+				}
+			}
 			if err != nil {
 				log.Println("critical error: ", err)
 				select {
@@ -160,13 +264,100 @@ func (c *Client) ListenForMessages(errChan chan<- error) {
 				wg.Wait()
 				return
 			}
-		case read := <-readData:
-			go c.processPayload(ctx, read, errChan)
 		}
 	}
 }
 
+// readLoop reads payloads from the session until the connection fails or ctx is
+// done. it's started again on the new connection after reconnecting. payloads
+// are processed one at a time in the order they came in and reading waits
+// when processing falls behind instead of dropping them; everything that was
+// read is dealt with before it returns
+func (c *Client) readLoop(ctx context.Context, wg *sync.WaitGroup, readErr chan<- error, errChan chan<- error) {
+	defer wg.Done()
+	defer c.restoreOnPanic()
+
+	reads := make(chan []byte, readQueueSize)
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		defer c.restoreOnPanic()
+		for read := range reads {
+			// there's no one left to tell once we're shutting down
+			if ctx.Err() == nil {
+				c.handleRead(ctx, read, errChan)
+			}
+		}
+	}()
+	defer func() {
+		close(reads)
+		<-processed
+	}()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		read, err := utils.ReadFull(ctx, c.serverConn, c.tracker)
+		if err != nil {
+			select {
+			case readErr <- err:
+			default:
+			}
+			return
+		}
+		if read == nil {
+			continue
+		}
+
+		if c.handshaking() {
+			// the connection switches to being encrypted as soon as the
+			// handshake is done so nothing else can be read until we've
+			// dealt with what we just got
+			c.handleRead(ctx, read, errChan)
+			continue
+		}
+
+		select {
+		case reads <- read:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleRead processes a payload read off the connection
+func (c *Client) handleRead(ctx context.Context, read []byte, errChan chan<- error) {
+	if read = c.readInOrder(ctx, read); read != nil {
+		c.processPayload(ctx, read, errChan)
+	}
+}
+
+// readInOrder deals with the payloads that keep state of their own across the
+// stream before they're processed. it returns what's left to be processed
+func (c *Client) readInOrder(ctx context.Context, read []byte) []byte {
+	payload, err := base.DecodePayload(read)
+	if err != nil {
+		// whatever's wrong with it is reported when it's processed
+		return read
+	}
+
+	switch payload.GetHeader() {
+	case common.Header_HEADER_TERMINAL_DATA:
+		return c.inflate(ctx, payload, read)
+	case common.Header_HEADER_SCREEN_DIFF:
+		if err := c.handleScreenDiff(payload.GetScreenDiff()); err != nil {
+			log.Println("couldn't apply the screen diff:", err)
+		}
+		return nil
+	}
+
+	return read
+}
+
 func (c *Client) processPayload(ctx context.Context, data []byte, errChan chan<- error) {
+	defer c.restoreOnPanic()
 	procCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -186,10 +377,9 @@ func (c *Client) processPayload(ctx context.Context, data []byte, errChan chan<-
 		}
 
 	case common.Header_HEADER_AUTH:
-		// we only need to check its type but we won't use the actual payload
-		_, ok := payload.GetContent().(*base.Payload_Auth)
+		authPayload, ok := payload.GetContent().(*base.Payload_Auth)
 		if ok {
-			errChan <- c.handleAuthPayload(procCtx)
+			errChan <- c.handleAuthPayload(procCtx, *authPayload)
 			return
 		}
 
@@ -203,14 +393,34 @@ func (c *Client) processPayload(ctx context.Context, data []byte, errChan chan<-
 	case common.Header_HEADER_TERMINAL_DATA:
 		termPayload, ok := payload.GetContent().(*base.Payload_TermContent)
 		if ok {
-			errChan <- c.handleTermPayload(*termPayload)
+			errChan <- c.handleTermPayload(procCtx, *termPayload)
 			return
 		}
 
 	case common.Header_HEADER_HEARTBEAT:
-		_, ok := payload.GetContent().(*base.Payload_Heartbeat)
+		hbPayload, ok := payload.GetContent().(*base.Payload_Heartbeat)
+		if ok {
+			errChan <- c.handleHeartbeatPayload(procCtx, *hbPayload)
+			return
+		}
+
+	case common.Header_HEADER_RESIZE:
+		resizePayload, ok := payload.GetContent().(*base.Payload_Resize)
+		if ok {
+			errChan <- c.handleResizePayload(*resizePayload)
+			return
+		}
+
+	case common.Header_HEADER_WINDOW_LIST:
+		listPayload, ok := payload.GetContent().(*base.Payload_WindowList)
+		if ok {
+			errChan <- c.handleWindowList(*listPayload)
+			return
+		}
+	case common.Header_HEADER_SCROLLBACK:
+		scrollbackPayload, ok := payload.GetContent().(*base.Payload_Scrollback)
 		if ok {
-			errChan <- c.handleHeartbeatPayload()
+			errChan <- c.handleScrollback(*scrollbackPayload)
 			return
 		}
 	default:
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"log"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/term"
+)
+
+// inflate decompresses the terminal frame read was decoded into payload from if
+// it's deflated. every deflated frame carries on from the one before it so this
+// has to happen as they come off the connection. it returns nil if the frame
+// can't be made sense of and should be dropped
+func (c *Client) inflate(ctx context.Context, payload *base.Payload, read []byte) []byte {
+	termContent := payload.GetTermContent()
+	if termContent.GetCompression() != term.Compression_COMPRESSION_DEFLATE {
+		return read
+	}
+
+	if termContent.GetCompressionReset() {
+		c.inflater.Reset()
+		c.inflateLost = false
+	} else if c.inflateLost {
+		// nothing from here on can be inflated until the session starts over
+		// which it does with the keyframe we asked for
+		return nil
+	}
+
+	data, err := c.inflater.Inflate(termContent.GetData(), int(termContent.GetMessageLength()))
+	if err != nil {
+		log.Println(err)
+		c.inflateLost = true
+		// keyframes aren't kept around so asking for one from before the
+		// stream started gets us a new one
+		if err := c.requestResend(ctx, 0, termContent.GetSequence()); err != nil {
+			log.Println("failed to ask for a keyframe:", err)
+		}
+		return nil
+	}
+
+	termContent.Data = data
+	termContent.Compression = term.Compression_COMPRESSION_NONE
+	termContent.CompressionReset = false
+	inflated, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, payload.GetContent())
+	if err != nil {
+		log.Println("failed to re-encode inflated frame:", err)
+		return nil
+	}
+	return inflated
+}
@@ -11,18 +11,27 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	"willofdaedalus/superluminal/internal/payload/auth"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
 	err1 "willofdaedalus/superluminal/internal/payload/error"
+	"willofdaedalus/superluminal/internal/payload/heartbeat"
 	"willofdaedalus/superluminal/internal/payload/info"
 	"willofdaedalus/superluminal/internal/utils"
+
+	"golang.org/x/term"
 )
 
 const (
 	passEntryTimeout = time.Minute * 5
 	// passEntryTimeout   = time.Second * 5
-	cleanupTime        = time.Second * 30
-	serverShutdownTime = time.Second * 20
+	cleanupTime          = time.Second * 30
+	serverShutdownTime   = time.Second * 20
+	heartbeatRespTimeout = time.Second * 5
+	resendReqTimeout     = time.Second * 5
+	inputWriteTimeout    = time.Second * 5
+	// how many out of order frames we hold on to while waiting for a resend
+	maxPendingFrames = 256
 )
 
 func (c *Client) handleErrPayload(payload base.Payload_Error) error {
@@ -38,6 +47,33 @@ func (c *Client) handleErrPayload(payload base.Payload_Error) error {
 		log.Println(string(payload.Error.GetDetail()))
 		c.exitChan <- struct{}{}
 		return utils.ErrClientFailedAuth
+	case err1.ErrorMessage_ERROR_APPROVAL_DENIED:
+		log.Println(string(payload.Error.GetDetail()))
+		c.exitChan <- struct{}{}
+		return utils.ErrApprovalDenied
+	case err1.ErrorMessage_ERROR_APPROVAL_TIMEOUT:
+		log.Println(string(payload.Error.GetDetail()))
+		c.exitChan <- struct{}{}
+		return utils.ErrApprovalTimeout
+	case err1.ErrorMessage_ERROR_RESUME_FAILED:
+		log.Println(string(payload.Error.GetDetail()))
+		c.exitChan <- struct{}{}
+		return utils.ErrResumeFailed
+	case err1.ErrorMessage_ERROR_VERSION_MISMATCH:
+		log.Println(string(payload.Error.GetDetail()))
+		c.exitChan <- struct{}{}
+		return utils.ErrVersionMismatch
+	case err1.ErrorMessage_ERROR_KICKED, err1.ErrorMessage_ERROR_BANNED:
+		log.Println(string(payload.Error.GetDetail()))
+		// there's no getting back in after being removed
+		c.mu.Lock()
+		c.resume = nil
+		c.mu.Unlock()
+		c.exitChan <- struct{}{}
+		if payload.Error.GetCode() == err1.ErrorMessage_ERROR_BANNED {
+			return utils.ErrClientBanned
+		}
+		return utils.ErrClientKicked
 	}
 
 	return utils.ErrUnspecifiedPayload
@@ -47,11 +83,97 @@ func (c *Client) SendPassphrase(pass string) {
 	c.bbltPass <- pass
 }
 
-func (c *Client) handleAuthPayload(ctx context.Context) error {
-	var passphrase string
+func (c *Client) handleAuthPayload(ctx context.Context, payload base.Payload_Auth) error {
+	switch payload.Auth.GetAuth() {
+	case auth.Authentication_AUTH_TYPE_REQUEST:
+		return c.answerAuthRequest(ctx, payload.Auth.GetRequest())
+	case auth.Authentication_AUTH_TYPE_CONFIRM:
+		return c.handleAuthConfirm(payload.Auth.GetConfirm())
+	}
+
+	return utils.ErrUnspecifiedPayload
+}
+
+// answerAuthRequest asks the user for the passphrase and uses it to finish the
+// key exchange the session started. only our half of the exchange and proof that
+// we derived the right keys are sent back. when we're getting back into the
+// session we ask for our old place instead and use the resume secret in place of
+// the passphrase for the exchange that follows
+func (c *Client) answerAuthRequest(ctx context.Context, req *auth.AuthRequest) error {
 	authCtx, cancel := context.WithTimeout(ctx, passEntryTimeout)
 	defer cancel()
 
+	c.mu.Lock()
+	negotiated, ok := base.NegotiateWithServer(req, c.wants)
+	c.mu.Unlock()
+	if !ok {
+		// don't bother the user for a passphrase; the session turns us away
+		// telling them why once it sees which versions we speak
+		payload, err := base.EncodePayload(common.Header_HEADER_AUTH, base.GenerateVersionResp(c.name))
+		if err != nil {
+			return err
+		}
+		return utils.WriteFull(authCtx, c.serverConn, c.tracker, payload)
+	}
+
+	c.mu.Lock()
+	resuming, asked, token := c.resuming, c.resumeAsked, c.resume
+	c.resumeAsked = resuming
+	c.mu.Unlock()
+
+	if resuming && !asked {
+		resumeReq := base.GenerateResumeReq(c.name, token.GetId())
+		resumeReq.Auth.GetResponse().Capabilities = c.wants.List()
+		payload, err := base.EncodePayload(common.Header_HEADER_AUTH, resumeReq)
+		if err != nil {
+			return err
+		}
+		return utils.WriteFull(authCtx, c.serverConn, c.tracker, payload)
+	}
+
+	passphrase := token.GetSecret()
+	if !resuming {
+		var err error
+		if passphrase, err = c.readPassphrase(ctx); err != nil {
+			return err
+		}
+	}
+
+	pake, err := utils.NewPake(utils.PakeClient, passphrase)
+	if err != nil {
+		return err
+	}
+
+	// the offers went over in the clear so they're bound into the exchange
+	// where changing them makes the confirmations fail
+	keys, err := pake.Finish(req.GetPakeMessage(), negotiated.Transcript())
+	if err != nil {
+		return err
+	}
+
+	authResp := base.GenerateAuthResp(c.name, pake.Message(), keys.ClientConfirm)
+	authResp.Auth.GetResponse().Capabilities = c.wants.List()
+	payload, err := base.EncodePayload(common.Header_HEADER_AUTH, authResp)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.WriteFull(authCtx, c.serverConn, c.tracker, payload); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.negotiated = negotiated
+	c.mu.Unlock()
+
+	c.SentPass = true
+	return nil
+}
+
+// readPassphrase asks the user for the session's passphrase
+func (c *Client) readPassphrase(ctx context.Context) (string, error) {
+	var passphrase string
 	passChan := make(chan string, 1)
 	errChan := make(chan error, 1)
 
@@ -75,44 +197,153 @@ func (c *Client) handleAuthPayload(ctx context.Context) error {
 	// case pass := <-c.bbltPass:
 	// 	passphrase = pass
 	case <-ctx.Done():
-		return errors.New("passphrase entry timed out")
+		return "", errors.New("passphrase entry timed out")
 		// case passphrase = <-c.bbltPass:
 	case inputErr := <-errChan:
-		return fmt.Errorf("input error: %w", inputErr)
+		return "", fmt.Errorf("input error: %w", inputErr)
 	case passphrase = <-passChan:
 		// Continue with authentication
 	}
 
-	authResp := base.GenerateAuthResp(c.name, passphrase)
-	payload, err := base.EncodePayload(common.Header_HEADER_AUTH, authResp)
+	return passphrase, nil
+}
+
+// handleAuthConfirm checks that the session knows the passphrase as well and
+// switches the connection over to being encrypted. anyone pretending to be the
+// session couldn't have derived the same keys so we leave if they don't match
+func (c *Client) handleAuthConfirm(confirm *auth.AuthConfirm) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.keys == nil || !utils.ConfirmationMatches(confirm.GetConfirmation(), c.keys.ServerConfirm) {
+		c.exitChan <- struct{}{}
+		return utils.ErrServerFailedAuth
+	}
+
+	secureConn, err := utils.NewSecureConn(c.serverConn, c.keys.ClientKey, c.keys.ServerKey)
 	if err != nil {
 		return err
 	}
 
-	if err := utils.WriteFull(authCtx, c.serverConn, c.tracker, payload); err != nil {
-		return err
+	version := confirm.GetVersion()
+	caps := base.NewCapabilities(confirm.GetCapabilities()...) & base.SupportedCapabilities
+	if version != c.negotiated.Version || caps != c.negotiated.Caps {
+		// the session agreed to what we worked out from the offers; anything
+		// else was changed on the way
+		c.exitChan <- struct{}{}
+		return utils.ErrServerFailedAuth
 	}
 
-	c.SentPass = true
+	c.serverConn = secureConn
+	c.secured = true
+	c.version, c.caps = version, caps
 	return nil
 }
 
-func (c *Client) handleHeartbeatPayload() error {
-	return nil
+// supports reports whether we and the session agreed on using capability
+func (c *Client) supports(capability auth.Capability) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps.Has(capability)
+}
+
+// handshaking reports whether the connection hasn't switched over to being
+// encrypted yet
+func (c *Client) handshaking() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.secured
+}
+
+// handleHeartbeatPayload answers the server's pings so that it knows we're
+// still around and doesn't evict us from the session
+func (c *Client) handleHeartbeatPayload(ctx context.Context, payload base.Payload_Heartbeat) error {
+	c.mu.Lock()
+	c.lastHeartbeat = time.Now()
+	c.mu.Unlock()
+
+	if payload.Heartbeat.GetType() != heartbeat.Heartbeat_HEARTBEAT_TYPE_PING {
+		return nil
+	}
+
+	pong := base.GenerateHeartbeatResp()
+	resp, err := base.EncodePayload(common.Header_HEADER_HEARTBEAT, &pong)
+	if err != nil {
+		return err
+	}
+
+	hbCtx, cancel := context.WithTimeout(ctx, heartbeatRespTimeout)
+	defer cancel()
+
+	return utils.WriteFull(hbCtx, c.serverConn, c.tracker, resp)
+}
+
+// serverUnresponsive reports whether the server has gone quiet for longer than
+// the heartbeat window. we only start watching once we're in the session since
+// the server doesn't ping clients that are still authenticating
+func (c *Client) serverUnresponsive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.isApproved && time.Since(c.lastHeartbeat) > serverHeartbeatTimeout
 }
 
 func (c *Client) handleInfoPayload(ctx context.Context, payload base.Payload_Info) error {
 	switch payload.Info.GetInfoType() {
 	case info.Info_INFO_AUTH_SUCCESS:
 		log.Println(payload.Info.GetMessage())
+		c.mu.Lock()
+		if token := payload.Info.GetResume(); token.GetId() != "" {
+			c.resume = token
+		}
+		c.resuming = false
+		if !c.isApproved {
+			close(c.joinedChan)
+		}
 		c.isApproved = true
+		c.lastHeartbeat = time.Now()
+		c.mu.Unlock()
+		return nil
+
+	case info.Info_INFO_WRITE_GRANTED, info.Info_INFO_WRITE_REVOKED:
+		log.Println(payload.Info.GetMessage())
+		c.mu.Lock()
+		c.canWrite = payload.Info.GetInfoType() == info.Info_INFO_WRITE_GRANTED
+		c.mu.Unlock()
+		return nil
+
+	case info.Info_INFO_STREAM_PAUSED:
+		c.mu.Lock()
+		c.showBanner(payload.Info.GetMessage())
+		c.mu.Unlock()
+		return nil
+
+	case info.Info_INFO_STREAM_RESUMED:
+		// the keyframe right behind this draws over the banner
+		return nil
+
+	case info.Info_INFO_PROGRAM_EXITED:
+		log.Println(payload.Info.GetMessage())
+		c.mu.Lock()
+		c.exitStatus = int(payload.Info.GetExitStatus())
+		c.mu.Unlock()
+		return nil
+
+	case info.Info_INFO_AWAITING_APPROVAL:
+		log.Println(payload.Info.GetMessage())
 		return nil
 
 	case info.Info_INFO_SHUTDOWN:
 		c.handleServerShutdown(ctx)
 		// c.exitChan <- struct{}{}
+		// os.Exit skips every defer so the terminal has to be restored here
+		c.RestoreTerminal()
 		log.Println(payload.Info.GetMessage())
-		os.Exit(0)
+		// we leave the same way the session's program did
+		c.mu.Lock()
+		status := c.exitStatus
+		c.mu.Unlock()
+		os.Exit(status)
 		return nil
 
 		// case info.Info_INFO_REQ_ACK:
@@ -154,24 +385,364 @@ func (c *Client) handleServerShutdown(ctx context.Context) error {
 	return nil
 }
 
-func (c *Client) handleTermPayload(payload base.Payload_TermContent) error {
+// handleResizePayload keeps track of the host's terminal size and warns when the
+// session won't fit on our screen since full screen programs will look mangled
+func (c *Client) handleResizePayload(payload base.Payload_Resize) error {
+	c.mu.Lock()
+	c.hostRows = payload.Resize.GetRows()
+	c.hostCols = payload.Resize.GetCols()
+	c.mu.Unlock()
+
+	if warning := c.sizeWarning(); warning != "" {
+		log.Println(warning)
+	}
+
+	return nil
+}
+
+// sizeWarning returns a warning if our terminal is smaller than the host's or an
+// empty string if the session fits
+func (c *Client) sizeWarning() string {
+	c.mu.Lock()
+	hostRows, hostCols := int(c.hostRows), int(c.hostCols)
+	c.mu.Unlock()
+
+	if hostRows == 0 || hostCols == 0 || c.localSize == nil {
+		return ""
+	}
+
+	rows, cols, err := c.localSize()
+	if err != nil {
+		// not running in a terminal so there's nothing to compare against
+		return ""
+	}
+
+	if rows < hostRows || cols < hostCols {
+		return fmt.Sprintf("your terminal (%dx%d) is smaller than the host's (%dx%d); some output may look wrong",
+			cols, rows, hostCols, hostRows)
+	}
+
+	return ""
+}
+
+// stdoutSize returns the rows and columns of the terminal we're printing to
+func stdoutSize() (int, int, error) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	return rows, cols, err
+}
+
+func (c *Client) handleTermPayload(ctx context.Context, payload base.Payload_TermContent) error {
 	termContent := payload.TermContent
+	seq := termContent.GetSequence()
 
 	sameLen := len(termContent.GetData()) == int(termContent.GetMessageLength())
 	crcMatch := crc32.ChecksumIEEE(termContent.GetData()) == termContent.GetCrc32()
 
-	if !sameLen {
-		return fmt.Errorf("message data length differ")
+	if !sameLen || !crcMatch {
+		// the frame got mangled somewhere so ask for a fresh copy instead of
+		// printing garbage to the screen. keyframes aren't kept around so asking
+		// for one from before the stream started gets us a new one
+		from := seq
+		if termContent.GetKeyframe() {
+			from = 0
+		}
+		if err := c.requestResend(ctx, from, seq); err != nil {
+			return err
+		}
+		if !sameLen {
+			return fmt.Errorf("message data length differ")
+		}
+		return utils.ErrCrcMismatch
+	}
+
+	c.mu.Lock()
+	current := c.acceptWindow(termContent.GetWindow(), termContent.GetKeyframe())
+	c.mu.Unlock()
+	if !current {
+		return nil
+	}
+
+	if termContent.GetKeyframe() {
+		c.mu.Lock()
+		c.applyKeyframe(seq, termContent.GetData())
+		c.mu.Unlock()
+		return nil
 	}
-	if !crcMatch {
-		return fmt.Errorf("crc doesn't match")
+
+	// a coalesced frame covers every sequence number from its first one
+	first := termContent.GetFirstSequence()
+	if first == 0 {
+		first = seq
 	}
 
-	fmt.Print(string(termContent.GetData()))
+	c.mu.Lock()
+	from, to, gap := c.queueFrame(first, seq, termContent.GetData())
+	c.mu.Unlock()
+
+	if gap {
+		return c.requestResend(ctx, from, to)
+	}
 
 	return nil
 }
 
+// queueFrame puts the frame covering first to last in line to be printed and
+// prints everything that's now in order. if there's a hole before this frame it
+// returns the range that still needs to be requested from the server.
+// must be called with c.mu held
+func (c *Client) queueFrame(first, last uint64, data []byte) (uint64, uint64, bool) {
+	if c.nextSeq == 0 {
+		// first frame we've seen so we sync up to wherever the stream is
+		c.nextSeq = first
+	}
+
+	if first < c.nextSeq {
+		// already printed this one (or part of it); most likely a duplicate resend
+		return 0, 0, false
+	}
+
+	c.pending[first] = pendingFrame{last: last, data: data}
+	c.flushPending()
+
+	if first <= c.nextSeq {
+		return 0, 0, false
+	}
+
+	if len(c.pending) > maxPendingFrames {
+		// the server isn't filling the hole fast enough so we give up on it
+		// rather than hold on to frames forever
+		c.skipTo(c.lowestPending())
+		return 0, 0, false
+	}
+
+	// only ask for frames we haven't already asked for or already have
+	from, to := max(c.nextSeq, c.requestedTo+1), first-1
+	for from <= to && c.isPending(from) {
+		from += 1
+	}
+	for to >= from && c.isPending(to) {
+		to -= 1
+	}
+	if from > to {
+		return 0, 0, false
+	}
+	c.requestedTo = to
+
+	return from, to, true
+}
+
+// isPending reports whether seq is covered by a frame waiting to be printed.
+// must be called with c.mu held
+func (c *Client) isPending(seq uint64) bool {
+	for first, f := range c.pending {
+		if seq >= first && seq <= f.last {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flushPending prints all the frames that follow on from the last printed one.
+// must be called with c.mu held
+func (c *Client) flushPending() {
+	for {
+		f, ok := c.pending[c.nextSeq]
+		if !ok {
+			return
+		}
+
+		c.print(f.data)
+		delete(c.pending, c.nextSeq)
+		c.nextSeq = f.last + 1
+	}
+}
+
+// applyKeyframe redraws the screen with a keyframe that includes everything up to
+// and including seq so any frames before it we were waiting on don't matter
+// anymore. must be called with c.mu held
+func (c *Client) applyKeyframe(seq uint64, data []byte) {
+	for first := range c.pending {
+		if first <= seq {
+			delete(c.pending, first)
+		}
+	}
+
+	c.print(data)
+	c.nextSeq = seq + 1
+	c.requestedTo = max(c.requestedTo, seq)
+	c.flushPending()
+}
+
+// skipTo gives up on every frame before seq and prints whatever is now in
+// order. must be called with c.mu held
+func (c *Client) skipTo(seq uint64) {
+	if seq <= c.nextSeq {
+		return
+	}
+
+	for first := range c.pending {
+		if first < seq {
+			delete(c.pending, first)
+		}
+	}
+	c.nextSeq = seq
+	c.flushPending()
+}
+
+func (c *Client) lowestPending() uint64 {
+	var lowest uint64
+	for first := range c.pending {
+		if lowest == 0 || first < lowest {
+			lowest = first
+		}
+	}
+
+	return lowest
+}
+
+// requestResend asks the server to retransmit the frames between from and to
+func (c *Client) requestResend(ctx context.Context, from, to uint64) error {
+	payload, err := base.EncodePayload(common.Header_HEADER_RESEND_REQ, base.GenerateResendReq(from, to))
+	if err != nil {
+		return err
+	}
+
+	resendCtx, cancel := context.WithTimeout(ctx, resendReqTimeout)
+	defer cancel()
+
+	return utils.WriteFull(resendCtx, c.serverConn, c.tracker, payload)
+}
+
+// forwardInput switches the terminal to raw mode once we're in the session and
+// sends what's typed to it. the session only passes it on to the pty if the host
+// gave us write access so there's no point sending it otherwise. ~. at the start
+// of a line detaches from the session
+func (c *Client) forwardInput(ctx context.Context) {
+	defer c.restoreOnPanic()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-c.joinedChan:
+	}
+
+	if err := c.enterRawMode(); err != nil {
+		log.Println("couldn't switch the terminal to raw mode:", err)
+	}
+	log.Println("type ~. at the start of a line to leave the session or ~h to see what scrolled off")
+
+	filter := newEscapeFilter()
+	filter.onCommand = func(key byte) bool {
+		return c.windowKey(ctx, key) || c.scrollbackKey(ctx, key)
+	}
+	buf := make([]byte, 1024)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		data, detach := filter.filter(buf[:n])
+		if detach {
+			log.Println("detaching from the session")
+			select {
+			case c.exitChan <- struct{}{}:
+			default:
+			}
+			return
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		err = c.SendInput(ctx, data)
+		if err != nil && !errors.Is(err, utils.ErrReadOnlyClient) && !errors.Is(err, utils.ErrReconnecting) &&
+			!errors.Is(err, utils.ErrNotSupported) {
+			// the connection may be about to be replaced so keep going
+			log.Println("couldn't send input:", err)
+		}
+	}
+}
+
+// SendInput sends keystrokes to the session's pty. it fails if the host hasn't
+// given us write access
+func (c *Client) SendInput(ctx context.Context, data []byte) error {
+	c.mu.Lock()
+	canWrite, canType := c.canWrite, c.caps.Has(auth.Capability_CAPABILITY_INPUT)
+	c.mu.Unlock()
+
+	if !canType {
+		return utils.ErrNotSupported
+	}
+	if !canWrite {
+		return utils.ErrReadOnlyClient
+	}
+	if c.handshaking() {
+		// whatever is typed while we're getting back into the session is lost
+		// rather than mixed in with the handshake
+		return utils.ErrReconnecting
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_CLIENT_INPUT, base.GenerateClientInput(data))
+	if err != nil {
+		return err
+	}
+
+	inputCtx, cancel := context.WithTimeout(ctx, inputWriteTimeout)
+	defer cancel()
+
+	return utils.WriteFull(inputCtx, c.serverConn, c.tracker, payload)
+}
+
+// canResume reports whether we can try to get back into the session if the
+// connection drops
+func (c *Client) canResume() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.isApproved && c.resume.GetId() != ""
+}
+
+// reconnect dials the session again backing off between tries until it gets
+// through or we run out of time. getting our place back happens in the handshake
+// once the reader is going again
+func (c *Client) reconnect(ctx context.Context) error {
+	c.serverConn.Close()
+
+	giveUp := time.Now().Add(reconnectTimeout)
+	for try := 0; time.Now().Before(giveUp); try++ {
+		wait := utils.Backoff(try, reconnectBaseBackoff, reconnectMaxBackoff)
+		log.Printf("reconnecting in %s...", wait.Round(time.Millisecond*100))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.exitChan:
+			return utils.ErrClientEarlyExit
+		case <-time.After(wait):
+		}
+
+		c.mu.Lock()
+		c.keys = nil
+		c.secured = false
+		c.resuming = true
+		c.resumeAsked = false
+		c.lastHeartbeat = time.Now()
+		c.mu.Unlock()
+
+		if err := c.ConnectToSession(c.host); err != nil {
+			log.Println("couldn't reconnect:", err)
+			continue
+		}
+		return nil
+	}
+
+	return utils.ErrReconnectFailed
+}
+
 func (c *Client) startCleanup() {
 	ctx, cancel := context.WithTimeout(context.Background(), cleanupTime)
 
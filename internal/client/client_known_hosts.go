@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bufio"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// knownHosts pins the certificate fingerprint of every server we've connected to
+// over tls. the file has one "host fingerprint" pair per line
+type knownHosts struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newKnownHosts(path string) (*knownHosts, error) {
+	if path == "" {
+		dir, err := utils.ConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "known_hosts")
+	}
+
+	return &knownHosts{path: path}, nil
+}
+
+// lookup returns the fingerprint pinned for host if there is one
+func (k *knownHosts) lookup(host string) (string, bool, error) {
+	f, err := os.Open(k.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == host {
+			return fields[1], true, nil
+		}
+	}
+
+	return "", false, scanner.Err()
+}
+
+// pin records the fingerprint for host
+func (k *knownHosts) pin(host, fingerprint string) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(k.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s %s\n", host, fingerprint)
+	return err
+}
+
+// verify trusts the certificate the first time we see host and refuses anything
+// that doesn't match it from then on. it's meant to be used as the tls config's
+// VerifyPeerCertificate since the certificates are self signed
+func (k *knownHosts) verify(host string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return utils.ErrNoServerCert
+		}
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		got := utils.Fingerprint(rawCerts[0])
+		pinned, ok, err := k.lookup(host)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			log.Printf("pinning fingerprint %s for %s; check it matches what the host sees", got, host)
+			return k.pin(host, got)
+		}
+
+		if pinned != got {
+			return fmt.Errorf("%w: expected %s got %s; if the host changed its certificate remove %s from %s",
+				utils.ErrFingerprintChanged, pinned, got, host, k.path)
+		}
+
+		return nil
+	}
+}
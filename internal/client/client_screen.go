@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/screen"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// colours with this bit set are rgb rather than indexed
+const rgbColor = 1 << 24
+
+// screenCell is a single character on our copy of the session's screen. ch is
+// empty for a blank cell and wide is set for the right half of a wide character
+type screenCell struct {
+	ch    string
+	wide  bool
+	flags uint32
+	fg    uint32
+	bg    uint32
+}
+
+// screenModel is our copy of the session's screen when we're sent diffs of it
+// instead of the raw output
+type screenModel struct {
+	rows, cols    int
+	lines         [][]screenCell
+	cursorRow     int
+	cursorCol     int
+	cursorVisible bool
+}
+
+// UseScreenDiffs asks the session for diffs of its screen instead of its raw
+// output. they take a lot less bandwidth on slow links but anything that never
+// makes it to the screen like the terminal's own scrollback is lost
+func (c *Client) UseScreenDiffs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wants |= base.NewCapabilities(auth.Capability_CAPABILITY_SCREEN_DIFF)
+}
+
+// handleScreenDiff brings our copy of the session's screen and the terminal up
+// to date with sd. diffs build on the ones before them so they have to be
+// applied in the order they arrive
+func (c *Client) handleScreenDiff(sd *screen.ScreenDiff) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a new window starts off with a full screen
+	if !c.acceptWindow(sd.GetWindow(), sd.GetFull()) {
+		return nil
+	}
+	if c.screen == nil {
+		c.screen = &screenModel{}
+	}
+
+	_, err := c.out.Write(c.screen.apply(sd))
+	return err
+}
+
+func (m *screenModel) reset(rows, cols int) {
+	m.rows, m.cols = rows, cols
+	m.lines = make([][]screenCell, rows)
+	for i := range m.lines {
+		m.lines[i] = make([]screenCell, cols)
+	}
+}
+
+// apply changes the screen by sd and returns what brings a terminal showing the
+// screen as it was up to date
+func (m *screenModel) apply(sd *screen.ScreenDiff) []byte {
+	var buf bytes.Buffer
+
+	rows, cols := int(sd.GetRows()), int(sd.GetCols())
+	full := sd.GetFull() || rows != m.rows || cols != m.cols
+	if full {
+		m.reset(rows, cols)
+	} else if n := min(int(sd.GetScroll()), m.rows); n > 0 {
+		m.lines = append(m.lines[n:], make([][]screenCell, n)...)
+		for i := m.rows - n; i < m.rows; i++ {
+			m.lines[i] = make([]screenCell, m.cols)
+		}
+		// only the part of the terminal the session's screen takes up scrolls
+		fmt.Fprintf(&buf, "\x1b[1;%dr\x1b[%d;1H%s\x1b[r", m.rows, m.rows, strings.Repeat("\n", n))
+	}
+
+	for _, run := range sd.GetRuns() {
+		m.applyRun(run)
+	}
+
+	if full {
+		m.render(&buf)
+	} else {
+		for _, run := range sd.GetRuns() {
+			if int(run.GetRow()) >= m.rows {
+				continue
+			}
+			fmt.Fprintf(&buf, "\x1b[%d;%dH%s%s", run.GetRow()+1, run.GetCol()+1,
+				sgr(run.GetFlags(), run.GetFg(), run.GetBg()), run.GetText())
+			if run.GetErase() {
+				buf.WriteString("\x1b[0m\x1b[K")
+			}
+		}
+		buf.WriteString("\x1b[0m")
+	}
+
+	m.cursorRow, m.cursorCol = int(sd.GetCursorRow()), int(sd.GetCursorCol())
+	m.cursorVisible = sd.GetCursorVisible()
+	m.writeCursor(&buf)
+	return buf.Bytes()
+}
+
+// applyRun puts the cells of run on the screen
+func (m *screenModel) applyRun(run *screen.Run) {
+	row, col := int(run.GetRow()), int(run.GetCol())
+	if row >= m.rows {
+		return
+	}
+	line := m.lines[row]
+
+	for _, r := range run.GetText() {
+		w := runewidth.RuneWidth(r)
+		if w == 0 && col > 0 {
+			// combining characters go with the one before them
+			prev := col - 1
+			if line[prev].wide && prev > 0 {
+				prev -= 1
+			}
+			line[prev].ch += string(r)
+			continue
+		}
+		if col+w > m.cols {
+			break
+		}
+
+		line[col] = screenCell{ch: string(r), flags: run.GetFlags(), fg: run.GetFg(), bg: run.GetBg()}
+		if w == 2 {
+			line[col+1] = screenCell{wide: true, flags: run.GetFlags(), fg: run.GetFg(), bg: run.GetBg()}
+		}
+		col += w
+	}
+
+	if run.GetErase() {
+		for ; col < m.cols; col++ {
+			line[col] = screenCell{}
+		}
+	}
+}
+
+// render draws the whole screen on a cleared terminal
+func (m *screenModel) render(buf *bytes.Buffer) {
+	buf.WriteString("\x1b[0m\x1b[H\x1b[2J")
+
+	var flags, fg, bg uint32
+	for row, line := range m.lines {
+		last := len(line) - 1
+		for last >= 0 && line[last] == (screenCell{}) {
+			last -= 1
+		}
+		if last < 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\x1b[%d;1H", row+1)
+		for _, cell := range line[:last+1] {
+			if cell.wide {
+				continue
+			}
+			if cell.flags != flags || cell.fg != fg || cell.bg != bg {
+				flags, fg, bg = cell.flags, cell.fg, cell.bg
+				buf.WriteString(sgr(flags, fg, bg))
+			}
+			if cell.ch == "" {
+				buf.WriteByte(' ')
+				continue
+			}
+			buf.WriteString(cell.ch)
+		}
+	}
+	buf.WriteString("\x1b[0m")
+}
+
+func (m *screenModel) writeCursor(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "\x1b[%d;%dH", m.cursorRow+1, m.cursorCol+1)
+	if m.cursorVisible {
+		buf.WriteString("\x1b[?25h")
+	} else {
+		buf.WriteString("\x1b[?25l")
+	}
+}
+
+// sgr returns the escape sequence that sets exactly the attributes of a run
+func sgr(flags, fg, bg uint32) string {
+	params := []string{"0"}
+	for i, param := range []string{"1", "2", "3", "4", "5", "7", "8", "9"} {
+		if flags&(1<<i) != 0 {
+			params = append(params, param)
+		}
+	}
+	params = append(params, colorParams(fg, 30, 90, "38")...)
+	params = append(params, colorParams(bg, 40, 100, "48")...)
+
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func colorParams(c uint32, base, brightBase int, extended string) []string {
+	switch {
+	case c == 0:
+		return nil
+	case c&rgbColor != 0:
+		return []string{
+			extended, "2",
+			strconv.Itoa(int(c >> 16 & 0xff)),
+			strconv.Itoa(int(c >> 8 & 0xff)),
+			strconv.Itoa(int(c & 0xff)),
+		}
+	case c-1 < 8:
+		return []string{strconv.Itoa(base + int(c-1))}
+	case c-1 < 16:
+		return []string{strconv.Itoa(brightBase + int(c-1) - 8)}
+	}
+	return []string{extended, "5", strconv.Itoa(int(c - 1))}
+}
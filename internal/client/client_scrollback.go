@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/utils"
+
+	"golang.org/x/term"
+)
+
+const (
+	// ~h prints what scrolled off the session's screen
+	historyChar = 'h'
+	// how many lines ~h asks for
+	historyLines = 500
+)
+
+// RequestScrollback asks the session for the lines numbered from to to that
+// scrolled off the screen of the window we're watching or the newest last lines
+// if last is set. redraw asks for a fresh screen after them
+func (c *Client) RequestScrollback(ctx context.Context, from, to uint64, last uint32, redraw bool) error {
+	if c.handshaking() {
+		return utils.ErrReconnecting
+	}
+	if !c.supports(auth.Capability_CAPABILITY_SCROLLBACK) {
+		return utils.ErrNotSupported
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_SCROLLBACK_REQ,
+		base.GenerateScrollbackReq(from, to, last, redraw))
+	if err != nil {
+		return err
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, inputWriteTimeout)
+	defer cancel()
+
+	return utils.WriteFull(reqCtx, c.serverConn, c.tracker, payload)
+}
+
+// scrollbackKey handles ~h. it reports whether key was it
+func (c *Client) scrollbackKey(ctx context.Context, key byte) bool {
+	if key != historyChar {
+		return false
+	}
+
+	// a transcript just carries on after them so there's nothing to redraw
+	if err := c.RequestScrollback(ctx, 0, 0, historyLines, c.transcript == nil); err != nil {
+		log.Println("couldn't ask for the scrollback:", err)
+	}
+	return true
+}
+
+// handleScrollback prints the lines the session sent us below everything on
+// the screen and pushes them into the terminal's own scrollback where they can
+// be scrolled back to. the keyframe that follows them redraws the screen
+func (c *Client) handleScrollback(payload base.Payload_Scrollback) error {
+	lines := payload.Scrollback.GetLines()
+	first := payload.Scrollback.GetFirst()
+
+	if c.transcript != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(lines) == 0 {
+			c.transcript.Note("[nothing has scrolled off the screen yet]")
+			return nil
+		}
+		c.transcript.Note(fmt.Sprintf("[lines %d-%d of %d that scrolled off]",
+			first, first+uint64(len(lines))-1, payload.Scrollback.GetTotal()))
+		c.printLines(lines...)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if c.altScreen {
+		// the alternate screen has no scrollback of its own
+		buf.WriteString("\x1b[?1049l")
+	}
+	buf.WriteString("\x1b[0m\x1b[r\x1b[999;1H\r\n")
+	if len(lines) == 0 {
+		buf.WriteString("\x1b[7m nothing has scrolled off the screen yet \x1b[0m\r\n")
+	} else {
+		fmt.Fprintf(&buf, "\x1b[7m lines %d-%d of %d that scrolled off \x1b[0m\r\n",
+			first, first+uint64(len(lines))-1, payload.Scrollback.GetTotal())
+	}
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteString("\r\n")
+	}
+
+	// scroll them all off the screen so redrawing it doesn't wipe them out
+	rows := 24
+	if _, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && height > 0 {
+		rows = height
+	}
+	buf.Write(bytes.Repeat([]byte("\r\n"), rows))
+	if c.altScreen {
+		buf.WriteString("\x1b[?1049h")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.out.Write(buf.Bytes())
+	return err
+}
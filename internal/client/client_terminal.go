@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	escapeChar = '~'
+	detachChar = '.'
+)
+
+// escapeState follows the keystrokes for the ssh style ~. escape which detaches
+// from the session. the ~ only counts at the start of a line
+type escapeState int
+
+const (
+	escapeLineStart escapeState = iota + 1
+	escapeMidLine
+	escapeSawTilde
+)
+
+// escapeFilter pulls the escape sequence out of what's typed so it never reaches
+// the session
+type escapeFilter struct {
+	state escapeState
+	// gets the key after ~ when it's not one of ours and reports whether it
+	// used it
+	onCommand func(key byte) bool
+}
+
+func newEscapeFilter() *escapeFilter {
+	return &escapeFilter{state: escapeLineStart}
+}
+
+// filter returns what should be sent on to the session and whether the user
+// asked to detach. ~~ at the start of a line sends a single ~
+func (f *escapeFilter) filter(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch f.state {
+		case escapeSawTilde:
+			switch b {
+			case detachChar:
+				return out, true
+			case escapeChar:
+				out = append(out, escapeChar)
+				f.state = escapeMidLine
+				continue
+			}
+			if f.onCommand != nil && f.onCommand(b) {
+				f.state = escapeMidLine
+				continue
+			}
+			// not an escape after all so the ~ goes through with this key
+			out = append(out, escapeChar)
+		case escapeLineStart:
+			if b == escapeChar {
+				f.state = escapeSawTilde
+				continue
+			}
+		}
+
+		out = append(out, b)
+		if b == '\r' || b == '\n' {
+			f.state = escapeLineStart
+		} else {
+			f.state = escapeMidLine
+		}
+	}
+
+	return out, false
+}
+
+// enterRawMode hands the whole terminal over to the session so keystrokes go
+// straight through and full screen programs draw properly. it does nothing if
+// stdin isn't a terminal or we're only printing a transcript
+func (c *Client) enterRawMode() error {
+	c.termMu.Lock()
+	defer c.termMu.Unlock()
+
+	fd := int(os.Stdin.Fd())
+	if c.termState != nil || c.transcript != nil || !term.IsTerminal(fd) {
+		return nil
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	c.termState = state
+
+	// raw mode doesn't turn \n into \r\n anymore so our own messages need it
+	log.SetOutput(crlfWriter{os.Stderr})
+
+	if c.altScreen {
+		os.Stdout.WriteString("\x1b[?1049h\x1b[H")
+	}
+
+	return nil
+}
+
+// RestoreTerminal puts the terminal back the way it was before we took it over.
+// it's safe to call more than once and from anywhere we might be leaving from
+func (c *Client) RestoreTerminal() {
+	c.termMu.Lock()
+	defer c.termMu.Unlock()
+
+	if c.termState == nil {
+		return
+	}
+
+	// undo whatever the session left behind; attributes, hidden cursor, mouse
+	// reporting, bracketed paste and the scroll region
+	os.Stdout.WriteString("\x1b[0m\x1b[r\x1b[?25h\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?2004l")
+	if c.altScreen {
+		os.Stdout.WriteString("\x1b[?1049l")
+	} else {
+		os.Stdout.WriteString("\r\n")
+	}
+
+	term.Restore(int(os.Stdin.Fd()), c.termState)
+	c.termState = nil
+	log.SetOutput(os.Stderr)
+}
+
+// restoreOnPanic makes sure a panic doesn't leave the terminal in raw mode. it
+// has to be deferred at the top of every goroutine the client starts
+func (c *Client) restoreOnPanic() {
+	if r := recover(); r != nil {
+		c.RestoreTerminal()
+		panic(r)
+	}
+}
+
+// showBanner writes msg in reverse video across the top line of the screen
+// leaving the cursor where it was. must be called with c.mu held
+func (c *Client) showBanner(msg string) {
+	if c.transcript != nil {
+		c.transcript.Note("[" + msg + "]")
+		return
+	}
+	c.out.Write([]byte("\x1b7\x1b[H\x1b[7m " + msg + " \x1b[0m\x1b[K\x1b8"))
+}
+
+// UseAltScreen draws the session in the terminal's alternate screen so whatever
+// was on screen before comes back after leaving
+func (c *Client) UseAltScreen(use bool) {
+	c.altScreen = use
+}
+
+// crlfWriter turns \n into \r\n for writing to a terminal in raw mode
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -1,9 +1,25 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"willofdaedalus/superluminal/internal/backend"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/payload/screen"
+	"willofdaedalus/superluminal/internal/payload/scrollback"
+	"willofdaedalus/superluminal/internal/payload/term"
+	"willofdaedalus/superluminal/internal/utils"
 )
 
 const (
@@ -20,18 +36,628 @@ func TestListenForMessage(t *testing.T) {
 
 	errChan := make(chan error, 1)
 	c := New(name)
-	if err := c.ConnectToSession(context.Background(), "localhost", "42024"); err != nil {
+	if err := c.ConnectToSession("localhost:42024"); err != nil {
 		t.Fatal("failed to connect to a session")
 	}
 
 	go c.ListenForMessages(errChan)
 
-	for {
-		select {
-		case retErr := <-errChan:
-			if retErr == nil {
-				t.Fatalf("expected an error from Listen got %v", err)
+	retErr := <-errChan
+	if retErr == nil {
+		t.Fatalf("expected an error from Listen got %v", err)
+	}
+}
+
+func TestQueueFrame(t *testing.T) {
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+
+	if _, _, gap := c.queueFrame(5, 5, []byte("a")); gap {
+		t.Fatal("the first frame shouldn't be treated as a gap")
+	}
+
+	from, to, gap := c.queueFrame(8, 8, []byte("d"))
+	if !gap || from != 6 || to != 7 {
+		t.Fatalf("expected a gap of 6-7 got %d-%d (%v)", from, to, gap)
+	}
+
+	// the same hole shouldn't be requested twice
+	if _, _, gap := c.queueFrame(9, 9, []byte("e")); gap {
+		t.Fatal("expected the hole to only be requested once")
+	}
+
+	c.queueFrame(7, 7, []byte("c"))
+	c.queueFrame(6, 6, []byte("b"))
+	// duplicates are dropped
+	c.queueFrame(6, 6, []byte("b"))
+
+	if out.String() != "abcde" {
+		t.Fatalf("expected frames in order got %q", out.String())
+	}
+}
+
+func TestSkipTo(t *testing.T) {
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+
+	c.queueFrame(1, 1, []byte("a"))
+	c.queueFrame(4, 4, []byte("d"))
+	c.queueFrame(5, 5, []byte("e"))
+
+	c.skipTo(4)
+	if out.String() != "ade" {
+		t.Fatalf("expected skipped frames to be dropped got %q", out.String())
+	}
+	if c.nextSeq != 6 {
+		t.Fatalf("expected next sequence 6 got %d", c.nextSeq)
+	}
+}
+
+func TestQueueCoalescedFrame(t *testing.T) {
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+
+	c.queueFrame(1, 1, []byte("a"))
+	// frames 2 to 4 were merged into one by the server
+	if _, _, gap := c.queueFrame(2, 4, []byte("bcd")); gap {
+		t.Fatal("a coalesced frame that follows on shouldn't be a gap")
+	}
+	c.queueFrame(5, 5, []byte("e"))
+
+	if out.String() != "abcde" {
+		t.Fatalf("expected frames in order got %q", out.String())
+	}
+}
+
+func TestApplyKeyframe(t *testing.T) {
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+
+	c.queueFrame(1, 1, []byte("a"))
+	c.queueFrame(3, 3, []byte("c"))
+	c.queueFrame(6, 6, []byte("f"))
+
+	// the keyframe covers everything up to 5 so 3 is stale and 6 follows on
+	c.applyKeyframe(5, []byte("[screen]"))
+
+	if out.String() != "a[screen]f" {
+		t.Fatalf("unexpected output after keyframe %q", out.String())
+	}
+	if c.nextSeq != 7 {
+		t.Fatalf("expected next sequence 7 got %d", c.nextSeq)
+	}
+}
+
+func TestSizeWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     [2]uint32
+		local    [2]int
+		wantWarn bool
+	}{
+		{"same size", [2]uint32{24, 80}, [2]int{24, 80}, false},
+		{"bigger than host", [2]uint32{24, 80}, [2]int{50, 200}, false},
+		{"fewer rows", [2]uint32{40, 80}, [2]int{24, 80}, true},
+		{"fewer columns", [2]uint32{24, 120}, [2]int{24, 80}, true},
+		{"host size unknown", [2]uint32{0, 0}, [2]int{24, 80}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(name)
+			c.localSize = func() (int, int, error) {
+				return tt.local[0], tt.local[1], nil
+			}
+			c.handleResizePayload(*base.GenerateResize(tt.host[0], tt.host[1]))
+
+			if got := c.sizeWarning() != ""; got != tt.wantWarn {
+				t.Fatalf("expected warning %v got %v", tt.wantWarn, got)
+			}
+		})
+	}
+}
+
+func TestKnownHostsPinning(t *testing.T) {
+	dir := t.TempDir()
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+
+	serve := func(name string) net.Listener {
+		cert, err := utils.LoadOrCreateCert(filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+		return l
+	}
+
+	first := serve("first")
+	defer first.Close()
+	addr := first.Addr().String()
+
+	// the first connection pins the fingerprint and the next ones check it
+	for i := 0; i < 2; i++ {
+		c := New(name)
+		if err := c.UseTLS(knownHostsPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ConnectToSession(addr); err != nil {
+			t.Fatalf("connection %d failed: %v", i, err)
+		}
+		c.serverConn.Close()
+	}
+
+	kh := &knownHosts{path: knownHostsPath}
+	pinned, ok, err := kh.lookup(addr)
+	if err != nil || !ok || pinned == "" {
+		t.Fatalf("expected a pinned fingerprint for %s got %q %v", addr, pinned, err)
+	}
+
+	// pretend the second server is the first one with a new certificate
+	second := serve("second")
+	defer second.Close()
+	if err := kh.pin(second.Addr().String(), pinned); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(name)
+	if err := c.UseTLS(knownHostsPath); err != nil {
+		t.Fatal(err)
+	}
+	err = c.ConnectToSession(second.Addr().String())
+	if !errors.Is(err, utils.ErrFingerprintChanged) {
+		t.Fatalf("expected a fingerprint mismatch got %v", err)
+	}
+}
+
+func TestSendInput(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	c := New(name)
+	c.serverConn = conn
+	c.secured = true
+
+	ctx := context.Background()
+	if err := c.SendInput(ctx, []byte("ls\r")); !errors.Is(err, utils.ErrReadOnlyClient) {
+		t.Fatalf("expected viewers not to send input got %v", err)
+	}
+
+	c.handleInfoPayload(ctx, *base.GenerateInfo(info.Info_INFO_WRITE_GRANTED, "granted"))
+
+	go c.SendInput(ctx, []byte("ls\r"))
+	data, err := utils.ReadFull(ctx, server, utils.NewSyncTracker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload.GetInput().GetData()) != "ls\r" {
+		t.Fatalf("unexpected input sent %q", payload.GetInput().GetData())
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []string
+		want       string
+		wantDetach bool
+	}{
+		{"plain typing", []string{"ls -la\r"}, "ls -la\r", false},
+		{"detach at start", []string{"~."}, "", true},
+		{"detach after enter", []string{"echo hi\r~."}, "echo hi\r", true},
+		{"detach split across reads", []string{"~", "."}, "", true},
+		{"tilde mid line", []string{"cd ~.", "\r"}, "cd ~.\r", false},
+		{"double tilde", []string{"~~."}, "~.", false},
+		{"tilde then other key", []string{"~/bin\r"}, "~/bin\r", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEscapeFilter()
+			var got []byte
+			detached := false
+			for _, in := range tt.input {
+				out, detach := f.filter([]byte(in))
+				got = append(got, out...)
+				if detach {
+					detached = true
+					break
+				}
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("expected %q got %q", tt.want, got)
 			}
+			if detached != tt.wantDetach {
+				t.Fatalf("expected detach %v got %v", tt.wantDetach, detached)
+			}
+		})
+	}
+}
+
+func TestAnswerResumeRequest(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	c := New(name)
+	c.serverConn = conn
+	c.resuming = true
+	c.resume = &info.ResumeToken{Id: "resume-id", Secret: "resume secret"}
+
+	ctx := context.Background()
+	tracker := utils.NewSyncTracker()
+	request := func(secret string) (*utils.Pake, *base.Payload) {
+		pake, err := utils.NewPake(utils.PakeServer, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go c.handleAuthPayload(ctx, *base.GenerateAuthReq(pake.Message()))
+		data, err := utils.ReadFull(ctx, server, tracker)
+		if err != nil {
+			t.Fatal(err)
 		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pake, payload
+	}
+
+	// the first request is answered with the resume id instead of a passphrase
+	_, payload := request("some passphrase")
+	if id := payload.GetAuth().GetResponse().GetResumeId(); id != "resume-id" {
+		t.Fatalf("expected to ask to resume with resume-id got %q", id)
+	}
+
+	// and the one after that is keyed on the resume secret
+	pake, payload := request("resume secret")
+	resp := payload.GetAuth().GetResponse()
+	negotiated, _ := base.NegotiateWithClient(resp)
+	keys, err := pake.Finish(resp.GetPakeMessage(), negotiated.Transcript())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.ConfirmationMatches(resp.GetConfirmation(), keys.ClientConfirm) {
+		t.Fatal("expected the exchange to use the resume secret")
+	}
+}
+
+func TestAuthConfirmDowngrade(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	serverPake, err := utils.NewPake(utils.PakeServer, "one two three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := base.GenerateAuthReq(serverPake.Message()).Auth.GetRequest()
+	negotiated, _ := base.NegotiateWithServer(req, base.DefaultCapabilities)
+
+	clientPake, err := utils.NewPake(utils.PakeClient, "one two three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := clientPake.Finish(serverPake.Message(), negotiated.Transcript())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(name)
+	c.serverConn = conn
+	c.keys, c.negotiated = keys, negotiated
+
+	// the confirmation is right but the capabilities were changed on the way
+	stripped := base.NewCapabilities(auth.Capability_CAPABILITY_INPUT)
+	confirm := base.GenerateAuthConfirm(keys.ServerConfirm, negotiated.Version, stripped).Auth.GetConfirm()
+	if err := c.handleAuthConfirm(confirm); !errors.Is(err, utils.ErrServerFailedAuth) {
+		t.Fatalf("expected changed capabilities to fail authentication got %v", err)
+	}
+
+	confirm = base.GenerateAuthConfirm(keys.ServerConfirm, negotiated.Version, negotiated.Caps).Auth.GetConfirm()
+	if err := c.handleAuthConfirm(confirm); err != nil {
+		t.Fatal(err)
+	}
+	if c.caps != negotiated.Caps {
+		t.Fatalf("expected %v got %v", negotiated.Caps.List(), c.caps.List())
+	}
+}
+
+func TestAnswerAuthRequestVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max uint32
+	}{
+		{"too new", base.MaxProtocolVersion + 1, base.MaxProtocolVersion + 1},
+		// nothing would be bound into the key exchange
+		{"no offer", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, conn := net.Pipe()
+			defer server.Close()
+			defer conn.Close()
+
+			c := New(name)
+			c.serverConn = conn
+
+			req := base.GenerateAuthReq([]byte("pake"))
+			req.Auth.GetRequest().MinVersion = tt.min
+			req.Auth.GetRequest().MaxVersion = tt.max
+
+			// the passphrase prompt would block reading stdin so answering at
+			// all means we didn't ask for it
+			go c.handleAuthPayload(context.Background(), *req)
+			data, err := utils.ReadFull(context.Background(), server, utils.NewSyncTracker())
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, err := base.DecodePayload(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := payload.GetAuth().GetResponse()
+			if len(resp.GetPakeMessage()) != 0 {
+				t.Fatal("expected no key exchange with a session we can't talk to")
+			}
+			if resp.GetMinVersion() != base.MinProtocolVersion || resp.GetMaxVersion() != base.MaxProtocolVersion {
+				t.Fatalf("expected our versions got %d-%d", resp.GetMinVersion(), resp.GetMaxVersion())
+			}
+		})
+	}
+}
+
+func TestInflate(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	c := New(name)
+	c.serverConn = conn
+	ctx := context.Background()
+
+	deflated := func(d *utils.Deflater, seq uint64, data string) []byte {
+		t.Helper()
+		out, reset, err := d.Deflate([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		termPayload := base.GenerateTermContent("", seq, []byte(data))
+		termPayload.TermContent.Data = out
+		termPayload.TermContent.Compression = term.Compression_COMPRESSION_DEFLATE
+		termPayload.TermContent.CompressionReset = reset
+		payload, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+	expectData := func(read []byte, want string) {
+		t.Helper()
+		payload, err := base.DecodePayload(read)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := payload.GetTermContent()
+		if content.GetCompression() != term.Compression_COMPRESSION_NONE || string(content.GetData()) != want {
+			t.Fatalf("expected inflated %q got %q", want, content.GetData())
+		}
+	}
+
+	d := utils.NewDeflater()
+	expectData(c.readInOrder(ctx, deflated(d, 1, "$ ls\r\n")), "$ ls\r\n")
+	expectData(c.readInOrder(ctx, deflated(d, 2, "$ ls\r\n")), "$ ls\r\n")
+
+	// a frame from a dictionary we never saw the start of can't be inflated so
+	// we ask for a keyframe and drop everything until the session starts over
+	other := utils.NewDeflater()
+	other.Deflate([]byte("something we missed"))
+	go func() {
+		if read := c.readInOrder(ctx, deflated(other, 3, "something we missed")); read != nil {
+			t.Error("expected a frame we can't inflate to be dropped")
+		}
+	}()
+	data, err := utils.ReadFull(ctx, server, utils.NewSyncTracker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.GetResend().GetFrom() != 0 || payload.GetResend().GetTo() != 3 {
+		t.Fatalf("expected a keyframe request got %v", payload.GetResend())
+	}
+
+	if read := c.readInOrder(ctx, deflated(d, 4, "$ ls\r\n")); read != nil {
+		t.Fatal("expected frames to be dropped until the dictionary starts over")
+	}
+	d.Reset()
+	expectData(c.readInOrder(ctx, deflated(d, 5, "$ ls\r\n")), "$ ls\r\n")
+}
+
+func TestReadBurst(t *testing.T) {
+	server, conn := net.Pipe()
+	defer conn.Close()
+
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+	c.serverConn = conn
+	c.secured = true
+
+	// anything the client sends back would be asking for frames it lost
+	sent := make(chan *base.Payload, 1)
+	go func() {
+		data, err := utils.ReadFull(context.Background(), server, utils.NewSyncTracker())
+		if err != nil {
+			return
+		}
+		payload, _ := base.DecodePayload(data)
+		sent <- payload
+	}()
+
+	errChan := make(chan error)
+	go func() {
+		for range errChan {
+		}
+	}()
+	defer close(errChan)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go c.readLoop(context.Background(), &wg, make(chan error, 1), errChan)
+
+	// many more frames than the reader keeps queued sent as fast as they'll go
+	var want strings.Builder
+	for seq := uint64(1); seq <= readQueueSize*4; seq++ {
+		data := fmt.Sprintf("frame %d\r\n", seq)
+		want.WriteString(data)
+		termPayload := base.GenerateTermContent("", seq, []byte(data))
+		payload, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.WriteFull(context.Background(), server, utils.NewSyncTracker(), payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.Close()
+	wg.Wait()
+
+	select {
+	case payload := <-sent:
+		t.Fatalf("expected every frame to arrive in order got %v", payload)
+	default:
+	}
+	if out.String() != want.String() {
+		t.Fatalf("expected every frame in order got %q", out.String())
+	}
+}
+
+func TestScreenDiffs(t *testing.T) {
+	c := New(name)
+	var out bytes.Buffer
+	c.out = &out
+
+	lineText := func(row int) string {
+		var text strings.Builder
+		for _, cell := range c.screen.lines[row] {
+			if cell.wide {
+				continue
+			}
+			if cell.ch == "" {
+				text.WriteByte(' ')
+				continue
+			}
+			text.WriteString(cell.ch)
+		}
+		return strings.TrimRight(text.String(), " ")
+	}
+
+	err := c.handleScreenDiff(&screen.ScreenDiff{
+		Rows: 3, Cols: 10, Full: true, CursorRow: 1, CursorCol: 4, CursorVisible: true,
+		Runs: []*screen.Run{
+			{Row: 0, Col: 0, Text: "$ ls"},
+			{Row: 1, Col: 0, Text: "日本", Fg: 2, Flags: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineText(0) != "$ ls" || lineText(1) != "日本" {
+		t.Fatalf("unexpected screen %q %q", lineText(0), lineText(1))
+	}
+	if cell := c.screen.lines[1][2]; cell.ch != "本" || cell.fg != 2 || cell.flags != 1 {
+		t.Fatalf("expected the wide characters to take up two cells each got %+v", cell)
+	}
+	if !strings.Contains(out.String(), "\x1b[2J") || !strings.Contains(out.String(), "\x1b[0;1;31m日本") {
+		t.Fatalf("expected a full redraw got %q", out.String())
+	}
+
+	// the screen scrolls up a line and the row that was at the top is cleared
+	out.Reset()
+	err = c.handleScreenDiff(&screen.ScreenDiff{
+		Rows: 3, Cols: 10, Scroll: 1, CursorRow: 2, CursorVisible: true,
+		Runs: []*screen.Run{
+			{Row: 0, Col: 2, Erase: true},
+			{Row: 2, Col: 0, Text: "$ "},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineText(0) != "日" || lineText(1) != "" || lineText(2) != "$" {
+		t.Fatalf("unexpected screen %q %q %q", lineText(0), lineText(1), lineText(2))
+	}
+	if strings.Contains(out.String(), "\x1b[2J") || !strings.Contains(out.String(), "\x1b[1;3r\x1b[3;1H\n\x1b[r") {
+		t.Fatalf("expected the terminal to be scrolled rather than redrawn got %q", out.String())
+	}
+
+	// diffs from a window we're not watching are left alone unless they're full
+	out.Reset()
+	if err := c.handleScreenDiff(&screen.ScreenDiff{Window: 2, Rows: 3, Cols: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected a diff from another window to be ignored got %q", out.String())
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	var out bytes.Buffer
+	c := New(name)
+	c.out = &out
+	c.UseScreenDiffs()
+	c.UseTranscript()
+
+	if c.wants.Has(auth.Capability_CAPABILITY_SCREEN_DIFF) {
+		t.Fatal("expected a transcript not to ask for screen diffs")
+	}
+
+	c.queueFrame(1, 1, []byte("\x1b]0;title\x07\x1b[?2004h$ "))
+	if out.String() != "$ " {
+		t.Fatalf("expected the prompt to be printed got %q", out.String())
+	}
+	c.queueFrame(2, 2, []byte("ls --color\r\n\x1b[01;34mdir\x1b[0m  file\r\n$ "))
+	c.showBanner("the host paused the stream")
+	c.applyKeyframe(5, []byte("\x1b[?1049l\x1b[0m\x1b[H\x1b[2J\x1b[1;1H$ ls\x1b[2;1Hdir  file\x1b[3;1H$ \x1b[3;3H"))
+
+	err := c.handleScrollback(base.Payload_Scrollback{Scrollback: &scrollback.Scrollback{
+		First: 1, Total: 1, Lines: [][]byte{[]byte("\x1b[1mold\x1b[0m line")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "$ ls --color\ndir  file\n$ \n[the host paused the stream]\n$ ls\ndir  file\n$ \n" +
+		"[lines 1-1 of 1 that scrolled off]\nold line\n"
+	if out.String() != want {
+		t.Fatalf("unexpected transcript\ngot  %q\nwant %q", out.String(), want)
 	}
 }
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"strings"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/pipeline"
+)
+
+// UseTranscript prints the session as plain lines of text with every escape
+// sequence stripped out instead of handing it to the terminal as it is. it's
+// for screen readers, piping into files and terminals that can't draw much.
+// the terminal is left in its normal mode so input is sent a line at a time
+// and full screen programs are only noted as starting and ending
+func (c *Client) UseTranscript() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transcript = pipeline.NewTranscript(c.out)
+	// there's no screen of our own to apply diffs to
+	c.wants &^= base.NewCapabilities(auth.Capability_CAPABILITY_SCREEN_DIFF)
+}
+
+// print writes terminal output from the session either as it is or to the
+// transcript. must be called with c.mu held
+func (c *Client) print(data []byte) {
+	if c.transcript == nil {
+		c.out.Write(data)
+		return
+	}
+
+	c.transcript.Write(data)
+	// whatever's on the line so far could be a prompt waiting on us
+	c.transcript.Flush()
+}
+
+// printLines writes lines of terminal output on lines of their own in the
+// transcript. must be called with c.mu held
+func (c *Client) printLines(lines ...[]byte) {
+	for _, line := range lines {
+		text := strings.TrimSuffix(string(pipeline.PlainText(line)), "\n")
+		c.transcript.Note(text)
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/window"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// handleWindowList keeps track of the session's windows and lets the user know
+// when the one we're watching changes
+func (c *Client) handleWindowList(payload base.Payload_WindowList) error {
+	list := payload.WindowList
+
+	c.mu.Lock()
+	changed := list.GetWatching() != c.watching || len(list.GetWindows()) != len(c.windows)
+	c.windows = list.GetWindows()
+	c.watching = list.GetWatching()
+	c.mu.Unlock()
+
+	if changed && len(list.GetWindows()) > 1 {
+		log.Println(describeWindows(list))
+	}
+
+	return nil
+}
+
+// describeWindows sums up the session's windows marking the one we're watching
+func describeWindows(list *window.WindowList) string {
+	names := make([]string, 0, len(list.GetWindows()))
+	for i, w := range list.GetWindows() {
+		name := fmt.Sprintf("%d %s", i+1, w.GetName())
+		if w.GetId() == list.GetWatching() {
+			name = "[" + name + "]"
+		}
+		names = append(names, name)
+	}
+
+	return fmt.Sprintf("windows: %s (~1-~9 picks one, ~0 follows the host)", strings.Join(names, " "))
+}
+
+// SelectWindow asks the session to show us window id or whichever window the
+// host is looking at if follow is set
+func (c *Client) SelectWindow(ctx context.Context, id uint32, follow bool) error {
+	if c.handshaking() {
+		return utils.ErrReconnecting
+	}
+	if !c.supports(auth.Capability_CAPABILITY_WINDOWS) {
+		return utils.ErrNotSupported
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_WINDOW_SELECT, base.GenerateWindowSelect(id, follow))
+	if err != nil {
+		return err
+	}
+
+	selectCtx, cancel := context.WithTimeout(ctx, inputWriteTimeout)
+	defer cancel()
+
+	return utils.WriteFull(selectCtx, c.serverConn, c.tracker, payload)
+}
+
+// windowKey handles the ~ escapes for windows; ~0 follows the host and ~1 to ~9
+// watch the window in that position. it reports whether key was one of them
+func (c *Client) windowKey(ctx context.Context, key byte) bool {
+	if key < '0' || key > '9' {
+		return false
+	}
+
+	var err error
+	if key == '0' {
+		err = c.SelectWindow(ctx, 0, true)
+	} else {
+		c.mu.Lock()
+		pos := int(key - '1')
+		var id uint32
+		if pos < len(c.windows) {
+			id = c.windows[pos].GetId()
+		}
+		c.mu.Unlock()
+
+		if id == 0 {
+			log.Printf("there's no window %c", key)
+			return true
+		}
+		err = c.SelectWindow(ctx, id, false)
+	}
+	if err != nil {
+		log.Println("couldn't switch windows:", err)
+	}
+
+	return true
+}
+
+// acceptWindow reports whether a frame for win should be shown. a keyframe from
+// another window means the session moved us over to it so everything we were
+// keeping track of for the old one is thrown away. must be called with c.mu held
+func (c *Client) acceptWindow(win uint32, keyframe bool) bool {
+	if win == c.window {
+		return true
+	}
+	if !keyframe {
+		// left over from the window we were watching before
+		return false
+	}
+
+	c.window = win
+	c.nextSeq = 0
+	c.requestedTo = 0
+	c.pending = make(map[uint64]pendingFrame)
+	return true
+}
@@ -16,7 +16,13 @@ import (
 	error1 "willofdaedalus/superluminal/internal/payload/error"
 	heartbeat "willofdaedalus/superluminal/internal/payload/heartbeat"
 	info "willofdaedalus/superluminal/internal/payload/info"
+	input "willofdaedalus/superluminal/internal/payload/input"
+	resend "willofdaedalus/superluminal/internal/payload/resend"
+	resize "willofdaedalus/superluminal/internal/payload/resize"
+	screen "willofdaedalus/superluminal/internal/payload/screen"
+	scrollback "willofdaedalus/superluminal/internal/payload/scrollback"
 	term "willofdaedalus/superluminal/internal/payload/term"
+	window "willofdaedalus/superluminal/internal/payload/window"
 )
 
 const (
@@ -41,6 +47,14 @@ type Payload struct {
 	//	*Payload_Heartbeat
 	//	*Payload_Error
 	//	*Payload_Info
+	//	*Payload_Resend
+	//	*Payload_Resize
+	//	*Payload_Input
+	//	*Payload_WindowList
+	//	*Payload_WindowSelect
+	//	*Payload_ScrollbackRequest
+	//	*Payload_Scrollback
+	//	*Payload_ScreenDiff
 	Content isPayload_Content `protobuf_oneof:"content"`
 }
 
@@ -137,6 +151,62 @@ func (x *Payload) GetInfo() *info.Info {
 	return nil
 }
 
+func (x *Payload) GetResend() *resend.ResendRequest {
+	if x, ok := x.GetContent().(*Payload_Resend); ok {
+		return x.Resend
+	}
+	return nil
+}
+
+func (x *Payload) GetResize() *resize.Resize {
+	if x, ok := x.GetContent().(*Payload_Resize); ok {
+		return x.Resize
+	}
+	return nil
+}
+
+func (x *Payload) GetInput() *input.ClientInput {
+	if x, ok := x.GetContent().(*Payload_Input); ok {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *Payload) GetWindowList() *window.WindowList {
+	if x, ok := x.GetContent().(*Payload_WindowList); ok {
+		return x.WindowList
+	}
+	return nil
+}
+
+func (x *Payload) GetWindowSelect() *window.WindowSelect {
+	if x, ok := x.GetContent().(*Payload_WindowSelect); ok {
+		return x.WindowSelect
+	}
+	return nil
+}
+
+func (x *Payload) GetScrollbackRequest() *scrollback.ScrollbackRequest {
+	if x, ok := x.GetContent().(*Payload_ScrollbackRequest); ok {
+		return x.ScrollbackRequest
+	}
+	return nil
+}
+
+func (x *Payload) GetScrollback() *scrollback.Scrollback {
+	if x, ok := x.GetContent().(*Payload_Scrollback); ok {
+		return x.Scrollback
+	}
+	return nil
+}
+
+func (x *Payload) GetScreenDiff() *screen.ScreenDiff {
+	if x, ok := x.GetContent().(*Payload_ScreenDiff); ok {
+		return x.ScreenDiff
+	}
+	return nil
+}
+
 type isPayload_Content interface {
 	isPayload_Content()
 }
@@ -161,6 +231,38 @@ type Payload_Info struct {
 	Info *info.Info `protobuf:"bytes,8,opt,name=info,proto3,oneof"`
 }
 
+type Payload_Resend struct {
+	Resend *resend.ResendRequest `protobuf:"bytes,9,opt,name=resend,proto3,oneof"`
+}
+
+type Payload_Resize struct {
+	Resize *resize.Resize `protobuf:"bytes,10,opt,name=resize,proto3,oneof"`
+}
+
+type Payload_Input struct {
+	Input *input.ClientInput `protobuf:"bytes,11,opt,name=input,proto3,oneof"`
+}
+
+type Payload_WindowList struct {
+	WindowList *window.WindowList `protobuf:"bytes,12,opt,name=window_list,json=windowList,proto3,oneof"`
+}
+
+type Payload_WindowSelect struct {
+	WindowSelect *window.WindowSelect `protobuf:"bytes,13,opt,name=window_select,json=windowSelect,proto3,oneof"`
+}
+
+type Payload_ScrollbackRequest struct {
+	ScrollbackRequest *scrollback.ScrollbackRequest `protobuf:"bytes,14,opt,name=scrollback_request,json=scrollbackRequest,proto3,oneof"`
+}
+
+type Payload_Scrollback struct {
+	Scrollback *scrollback.Scrollback `protobuf:"bytes,15,opt,name=scrollback,proto3,oneof"`
+}
+
+type Payload_ScreenDiff struct {
+	ScreenDiff *screen.ScreenDiff `protobuf:"bytes,16,opt,name=screen_diff,json=screenDiff,proto3,oneof"`
+}
+
 func (*Payload_TermContent) isPayload_Content() {}
 
 func (*Payload_Auth) isPayload_Content() {}
@@ -171,6 +273,22 @@ func (*Payload_Error) isPayload_Content() {}
 
 func (*Payload_Info) isPayload_Content() {}
 
+func (*Payload_Resend) isPayload_Content() {}
+
+func (*Payload_Resize) isPayload_Content() {}
+
+func (*Payload_Input) isPayload_Content() {}
+
+func (*Payload_WindowList) isPayload_Content() {}
+
+func (*Payload_WindowSelect) isPayload_Content() {}
+
+func (*Payload_ScrollbackRequest) isPayload_Content() {}
+
+func (*Payload_Scrollback) isPayload_Content() {}
+
+func (*Payload_ScreenDiff) isPayload_Content() {}
+
 var File_base_proto protoreflect.FileDescriptor
 
 var file_base_proto_rawDesc = []byte{
@@ -180,30 +298,60 @@ var file_base_proto_rawDesc = []byte{
 	0x6f, 0x74, 0x6f, 0x1a, 0x0f, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x2e, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x12, 0x74, 0x65, 0x72, 0x6d, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65,
 	0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0a, 0x69, 0x6e, 0x66, 0x6f, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x22, 0xbb, 0x02, 0x0a, 0x07, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
-	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x05, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1f, 0x0a, 0x06, 0x68, 0x65,
-	0x61, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x07, 0x2e, 0x48, 0x65, 0x61,
-	0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x74,
-	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
-	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x35, 0x0a, 0x0c, 0x74, 0x65, 0x72,
-	0x6d, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x10, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
-	0x74, 0x48, 0x00, 0x52, 0x0b, 0x74, 0x65, 0x72, 0x6d, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
-	0x12, 0x25, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f,
-	0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48,
-	0x00, 0x52, 0x04, 0x61, 0x75, 0x74, 0x68, 0x12, 0x2a, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74,
-	0x62, 0x65, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x48, 0x65, 0x61,
-	0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x48, 0x00, 0x52, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62,
-	0x65, 0x61, 0x74, 0x12, 0x25, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x48, 0x00, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x1b, 0x0a, 0x04, 0x69, 0x6e,
-	0x66, 0x6f, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x49, 0x6e, 0x66, 0x6f, 0x48,
-	0x00, 0x52, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x42, 0x09, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65,
-	0x6e, 0x74, 0x42, 0x33, 0x5a, 0x31, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61, 0x65, 0x64,
-	0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61,
-	0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f,
-	0x61, 0x64, 0x2f, 0x62, 0x61, 0x73, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0c, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x64, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x1a, 0x0c, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x1a, 0x0b, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0c, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x10, 0x73, 0x63, 0x72,
+	0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0c, 0x73,
+	0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb8, 0x05, 0x0a, 0x07,
+	0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x1f, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x07, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x12, 0x35, 0x0a, 0x0c, 0x74, 0x65, 0x72, 0x6d, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6c, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x48, 0x00, 0x52, 0x0b, 0x74, 0x65, 0x72, 0x6d,
+	0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x25, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x04, 0x61, 0x75, 0x74, 0x68, 0x12, 0x2a,
+	0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0a, 0x2e, 0x48, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x48, 0x00, 0x52,
+	0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x25, 0x0a, 0x05, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x12, 0x1b, 0x0a, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x05, 0x2e, 0x49, 0x6e, 0x66, 0x6f, 0x48, 0x00, 0x52, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x12, 0x28,
+	0x0a, 0x06, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e,
+	0x2e, 0x52, 0x65, 0x73, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00,
+	0x52, 0x06, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x64, 0x12, 0x21, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x52, 0x65, 0x73, 0x69, 0x7a,
+	0x65, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x24, 0x0a, 0x05, 0x69,
+	0x6e, 0x70, 0x75, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x48, 0x00, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75,
+	0x74, 0x12, 0x2e, 0x0a, 0x0b, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x6c, 0x69, 0x73, 0x74,
+	0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4c,
+	0x69, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0a, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4c, 0x69, 0x73,
+	0x74, 0x12, 0x34, 0x0a, 0x0d, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x73, 0x65, 0x6c, 0x65,
+	0x63, 0x74, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x53, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x48, 0x00, 0x52, 0x0c, 0x77, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x53, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x12, 0x43, 0x0a, 0x12, 0x73, 0x63, 0x72, 0x6f, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x0e, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x53, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x11, 0x73, 0x63, 0x72, 0x6f, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x0a,
+	0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0b, 0x2e, 0x53, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x48, 0x00, 0x52,
+	0x0a, 0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x2e, 0x0a, 0x0b, 0x73,
+	0x63, 0x72, 0x65, 0x65, 0x6e, 0x5f, 0x64, 0x69, 0x66, 0x66, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0b, 0x2e, 0x53, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x44, 0x69, 0x66, 0x66, 0x48, 0x00, 0x52,
+	0x0a, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x44, 0x69, 0x66, 0x66, 0x42, 0x09, 0x0a, 0x07, 0x63,
+	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x42, 0x33, 0x5a, 0x31, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66,
+	0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75,
+	0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x62, 0x61, 0x73, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -220,26 +368,42 @@ func file_base_proto_rawDescGZIP() []byte {
 
 var file_base_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_base_proto_goTypes = []any{
-	(*Payload)(nil),              // 0: Payload
-	(common.Header)(0),           // 1: Header
-	(*term.TerminalContent)(nil), // 2: TerminalContent
-	(*auth.Authentication)(nil),  // 3: Authentication
-	(*heartbeat.Heartbeat)(nil),  // 4: Heartbeat
-	(*error1.ErrorMessage)(nil),  // 5: ErrorMessage
-	(*info.Info)(nil),            // 6: Info
+	(*Payload)(nil),                      // 0: Payload
+	(common.Header)(0),                   // 1: Header
+	(*term.TerminalContent)(nil),         // 2: TerminalContent
+	(*auth.Authentication)(nil),          // 3: Authentication
+	(*heartbeat.Heartbeat)(nil),          // 4: Heartbeat
+	(*error1.ErrorMessage)(nil),          // 5: ErrorMessage
+	(*info.Info)(nil),                    // 6: Info
+	(*resend.ResendRequest)(nil),         // 7: ResendRequest
+	(*resize.Resize)(nil),                // 8: Resize
+	(*input.ClientInput)(nil),            // 9: ClientInput
+	(*window.WindowList)(nil),            // 10: WindowList
+	(*window.WindowSelect)(nil),          // 11: WindowSelect
+	(*scrollback.ScrollbackRequest)(nil), // 12: ScrollbackRequest
+	(*scrollback.Scrollback)(nil),        // 13: Scrollback
+	(*screen.ScreenDiff)(nil),            // 14: ScreenDiff
 }
 var file_base_proto_depIdxs = []int32{
-	1, // 0: Payload.header:type_name -> Header
-	2, // 1: Payload.term_content:type_name -> TerminalContent
-	3, // 2: Payload.auth:type_name -> Authentication
-	4, // 3: Payload.heartbeat:type_name -> Heartbeat
-	5, // 4: Payload.error:type_name -> ErrorMessage
-	6, // 5: Payload.info:type_name -> Info
-	6, // [6:6] is the sub-list for method output_type
-	6, // [6:6] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	1,  // 0: Payload.header:type_name -> Header
+	2,  // 1: Payload.term_content:type_name -> TerminalContent
+	3,  // 2: Payload.auth:type_name -> Authentication
+	4,  // 3: Payload.heartbeat:type_name -> Heartbeat
+	5,  // 4: Payload.error:type_name -> ErrorMessage
+	6,  // 5: Payload.info:type_name -> Info
+	7,  // 6: Payload.resend:type_name -> ResendRequest
+	8,  // 7: Payload.resize:type_name -> Resize
+	9,  // 8: Payload.input:type_name -> ClientInput
+	10, // 9: Payload.window_list:type_name -> WindowList
+	11, // 10: Payload.window_select:type_name -> WindowSelect
+	12, // 11: Payload.scrollback_request:type_name -> ScrollbackRequest
+	13, // 12: Payload.scrollback:type_name -> Scrollback
+	14, // 13: Payload.screen_diff:type_name -> ScreenDiff
+	14, // [14:14] is the sub-list for method output_type
+	14, // [14:14] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_base_proto_init() }
@@ -253,6 +417,14 @@ func file_base_proto_init() {
 		(*Payload_Heartbeat)(nil),
 		(*Payload_Error)(nil),
 		(*Payload_Info)(nil),
+		(*Payload_Resend)(nil),
+		(*Payload_Resize)(nil),
+		(*Payload_Input)(nil),
+		(*Payload_WindowList)(nil),
+		(*Payload_WindowSelect)(nil),
+		(*Payload_ScrollbackRequest)(nil),
+		(*Payload_Scrollback)(nil),
+		(*Payload_ScreenDiff)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -9,7 +9,12 @@ import (
 	err1 "willofdaedalus/superluminal/internal/payload/error"
 	"willofdaedalus/superluminal/internal/payload/heartbeat"
 	"willofdaedalus/superluminal/internal/payload/info"
+	"willofdaedalus/superluminal/internal/payload/input"
+	"willofdaedalus/superluminal/internal/payload/resend"
+	"willofdaedalus/superluminal/internal/payload/resize"
+	"willofdaedalus/superluminal/internal/payload/scrollback"
 	"willofdaedalus/superluminal/internal/payload/term"
+	"willofdaedalus/superluminal/internal/payload/window"
 	"willofdaedalus/superluminal/internal/utils"
 
 	"github.com/google/uuid"
@@ -25,6 +30,14 @@ const (
 	PayloadHeartbeat
 	PayloadError
 	PayloadInfo
+	PayloadResend
+	PayloadResize
+	PayloadInput
+	PayloadWindowList
+	PayloadWindowSelect
+	PayloadScrollbackReq
+	PayloadScrollback
+	PayloadScreenDiff
 )
 
 // EncodePayload creates a payload with the provided arguments and using proto, marshalls
@@ -47,13 +60,49 @@ func EncodePayload(header common.Header, content isPayload_Content) ([]byte, err
 		if GetPayloadType(content) != PayloadTermContent {
 			return nil, utils.ErrPayloadHeaderMismatch
 		}
+	case common.Header_HEADER_HEARTBEAT:
+		if GetPayloadType(content) != PayloadHeartbeat {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_RESEND_REQ:
+		if GetPayloadType(content) != PayloadResend {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_RESIZE:
+		if GetPayloadType(content) != PayloadResize {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_CLIENT_INPUT:
+		if GetPayloadType(content) != PayloadInput {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_WINDOW_LIST:
+		if GetPayloadType(content) != PayloadWindowList {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_WINDOW_SELECT:
+		if GetPayloadType(content) != PayloadWindowSelect {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_SCROLLBACK_REQ:
+		if GetPayloadType(content) != PayloadScrollbackReq {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_SCROLLBACK:
+		if GetPayloadType(content) != PayloadScrollback {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
+	case common.Header_HEADER_SCREEN_DIFF:
+		if GetPayloadType(content) != PayloadScreenDiff {
+			return nil, utils.ErrPayloadHeaderMismatch
+		}
 
 	default:
 		return nil, utils.ErrPayloadHeaderMismatch
 	}
 
 	payload := Payload{
-		Version:   1,
+		Version:   PayloadVersion,
 		Header:    header,
 		Timestamp: uint64(time.Now().Unix()),
 		Content:   content,
@@ -79,6 +128,22 @@ func GetPayloadType(payload isPayload_Content) PayloadType {
 		return PayloadHeartbeat
 	case *Payload_Error:
 		return PayloadError
+	case *Payload_Resend:
+		return PayloadResend
+	case *Payload_Resize:
+		return PayloadResize
+	case *Payload_Input:
+		return PayloadInput
+	case *Payload_WindowList:
+		return PayloadWindowList
+	case *Payload_WindowSelect:
+		return PayloadWindowSelect
+	case *Payload_ScrollbackRequest:
+		return PayloadScrollbackReq
+	case *Payload_Scrollback:
+		return PayloadScrollback
+	case *Payload_ScreenDiff:
+		return PayloadScreenDiff
 	default:
 		return PayloadUnknown
 	}
@@ -95,6 +160,33 @@ func GenerateInfo(infoType info.Info_InfoType, message string) *Payload_Info {
 	}
 }
 
+// GenerateAuthSuccess lets the client know it's in the session along with the token it
+// can use to get back in if its connection drops
+func GenerateAuthSuccess(message, resumeID, resumeSecret string) *Payload_Info {
+	return &Payload_Info{
+		Info: &info.Info{
+			InfoType: info.Info_INFO_AUTH_SUCCESS,
+			Message:  message,
+			Resume: &info.ResumeToken{
+				Id:     resumeID,
+				Secret: resumeSecret,
+			},
+		},
+	}
+}
+
+// GenerateExitInfo lets the client know the program the session was sharing has
+// ended and how
+func GenerateExitInfo(message string, status int32) *Payload_Info {
+	return &Payload_Info{
+		Info: &info.Info{
+			InfoType:   info.Info_INFO_PROGRAM_EXITED,
+			Message:    message,
+			ExitStatus: status,
+		},
+	}
+}
+
 // DecodePayload takes the slice of bytes which was received through the wire, unmarshalls
 // it with proto into a new Payload variable and returns the Payload and an error.
 // Using the Payload, we can then view the contents of the Payload including the HeaderType,
@@ -116,6 +208,10 @@ func DecodePayload(data []byte) (*Payload, error) {
 	if err != nil {
 		return nil, err
 	}
+	if payload.GetVersion() > PayloadVersion {
+		return nil, fmt.Errorf("%w: got version %d but only know up to %d",
+			utils.ErrUnsupportedVersion, payload.GetVersion(), PayloadVersion)
+	}
 
 	return &payload, nil
 }
@@ -132,45 +228,197 @@ func GenerateError(errType err1.ErrorMessage_ErrorCode, errMsg []byte, deets []b
 	}
 }
 
-// GenerateAuthResp generates an auth response payload comprised of the client's name and passphrase
-// and is sent over the wire to the session to act as a simple barrier for authentication
-func GenerateAuthResp(name, pass string) *Payload_Auth {
+// GenerateAuthResp generates an auth response payload comprised of the client's name and its half
+// of the key exchange along with proof that it derived the same key as the session. The passphrase
+// itself never leaves the client. The protocol versions and capabilities of this build go along
+func GenerateAuthResp(name string, pakeMsg, confirmation []byte) *Payload_Auth {
+	return &Payload_Auth{
+		Auth: &auth.Authentication{
+			Auth: auth.Authentication_AUTH_TYPE_RESPONSE,
+			AuthType: &auth.Authentication_Response{
+				Response: &auth.AuthResponse{
+					Username:     name,
+					PakeMessage:  pakeMsg,
+					Confirmation: confirmation,
+					MinVersion:   MinProtocolVersion,
+					MaxVersion:   MaxProtocolVersion,
+					Capabilities: DefaultCapabilities.List(),
+				},
+			},
+		},
+	}
+}
+
+// GenerateVersionResp answers an auth request from a session that doesn't speak
+// any protocol version we do with just the versions we speak so the session can
+// turn us away saying why
+func GenerateVersionResp(name string) *Payload_Auth {
 	return &Payload_Auth{
 		Auth: &auth.Authentication{
 			Auth: auth.Authentication_AUTH_TYPE_RESPONSE,
 			AuthType: &auth.Authentication_Response{
 				Response: &auth.AuthResponse{
 					Username:   name,
-					Passphrase: pass,
+					MinVersion: MinProtocolVersion,
+					MaxVersion: MaxProtocolVersion,
 				},
 			},
 		},
 	}
 }
 
-func GenerateAuthReq() *Payload_Auth {
-	// authentication request doesn't need any information for now as all the client needs to know
-	// from the succeeding payload header is that it's an auth request
-	// this is different from an auth response which actually sends a password and a name to the
-	// session
+// GenerateResumeReq asks the session for the client's old place back instead of going
+// through the passphrase. The session answers with a new request keyed on the resume
+// secret it handed out when the client first joined
+func GenerateResumeReq(name, resumeID string) *Payload_Auth {
+	return &Payload_Auth{
+		Auth: &auth.Authentication{
+			Auth: auth.Authentication_AUTH_TYPE_RESPONSE,
+			AuthType: &auth.Authentication_Response{
+				Response: &auth.AuthResponse{
+					Username:     name,
+					ResumeId:     resumeID,
+					MinVersion:   MinProtocolVersion,
+					MaxVersion:   MaxProtocolVersion,
+					Capabilities: DefaultCapabilities.List(),
+				},
+			},
+		},
+	}
+}
+
+// GenerateAuthReq starts a key exchange with the client by sending it the session's half
+// of the exchange along with the protocol versions and capabilities of this build. The client
+// combines it with the passphrase to answer
+func GenerateAuthReq(pakeMsg []byte) *Payload_Auth {
 	return &Payload_Auth{
 		Auth: &auth.Authentication{
 			Auth: auth.Authentication_AUTH_TYPE_REQUEST,
-			// AuthType: auth.Authentication_Request{},
+			AuthType: &auth.Authentication_Request{
+				Request: &auth.AuthRequest{
+					PakeMessage:  pakeMsg,
+					MinVersion:   MinProtocolVersion,
+					MaxVersion:   MaxProtocolVersion,
+					Capabilities: SupportedCapabilities.List(),
+				},
+			},
+		},
+	}
+}
+
+// GenerateAuthConfirm proves to the client that the session knows the passphrase too and tells
+// it the protocol version and capabilities the two of them agreed on. Everything sent after it is
+// encrypted with the keys from the exchange
+func GenerateAuthConfirm(confirmation []byte, version uint32, caps Capabilities) *Payload_Auth {
+	return &Payload_Auth{
+		Auth: &auth.Authentication{
+			Auth: auth.Authentication_AUTH_TYPE_CONFIRM,
+			AuthType: &auth.Authentication_Confirm{
+				Confirm: &auth.AuthConfirm{
+					Confirmation: confirmation,
+					Version:      version,
+					Capabilities: caps.List(),
+				},
+			},
 		},
 	}
 }
 
 // GenerateTermContent generates a new Payload of type term content which is passed to the Encoder
 // to transform into bytes to be sent over the wire. Upon receiving the content, it is then appended
-// to the last sent content
-func GenerateTermContent(msgId string, dataLen uint32, data []byte) Payload_TermContent {
+// to the last sent content. The sequence number lets clients spot frames they've missed
+func GenerateTermContent(msgId string, seq uint64, data []byte) Payload_TermContent {
+	if msgId == "" {
+		msgId = uuid.NewString()
+	}
+
 	return Payload_TermContent{
 		TermContent: &term.TerminalContent{
-			MessageId:     uuid.NewString(),
-			MessageLength: dataLen,
+			MessageId:     msgId,
+			MessageLength: uint32(len(data)),
 			Data:          data,
 			Crc32:         crc32.ChecksumIEEE(data),
+			Sequence:      seq,
+		},
+	}
+}
+
+// GenerateResendReq asks the other side to retransmit the terminal frames between
+// from and to inclusive
+func GenerateResendReq(from, to uint64) *Payload_Resend {
+	return &Payload_Resend{
+		Resend: &resend.ResendRequest{
+			From: from,
+			To:   to,
+		},
+	}
+}
+
+// GenerateResize tells clients the size of the host's terminal so they can tell
+// whether the session fits on their screen
+func GenerateResize(rows, cols uint32) *Payload_Resize {
+	return &Payload_Resize{
+		Resize: &resize.Resize{
+			Rows: rows,
+			Cols: cols,
+		},
+	}
+}
+
+// GenerateClientInput wraps keystrokes from a client on their way to the pty
+func GenerateClientInput(data []byte) *Payload_Input {
+	return &Payload_Input{
+		Input: &input.ClientInput{
+			Data: data,
+		},
+	}
+}
+
+// GenerateWindowList tells a client what windows the session has, which one the
+// host is looking at and which one the client is watching
+func GenerateWindowList(windows []*window.Window, active, watching uint32) *Payload_WindowList {
+	return &Payload_WindowList{
+		WindowList: &window.WindowList{
+			Windows:  windows,
+			Active:   active,
+			Watching: watching,
+		},
+	}
+}
+
+// GenerateWindowSelect asks the session to show a client window id or whichever
+// window the host is looking at if follow is set
+func GenerateWindowSelect(id uint32, follow bool) *Payload_WindowSelect {
+	return &Payload_WindowSelect{
+		WindowSelect: &window.WindowSelect{
+			Id:     id,
+			Follow: follow,
+		},
+	}
+}
+
+// GenerateScrollbackReq asks the session for the lines numbered from to to that
+// scrolled off the screen or the newest last lines if last is set. redraw asks
+// for a keyframe after them
+func GenerateScrollbackReq(from, to uint64, last uint32, redraw bool) *Payload_ScrollbackRequest {
+	return &Payload_ScrollbackRequest{
+		ScrollbackRequest: &scrollback.ScrollbackRequest{
+			From:   from,
+			To:     to,
+			Last:   last,
+			Redraw: redraw,
+		},
+	}
+}
+
+// GenerateScrollback answers a scrollback request with the lines starting at
+// line first and how many lines have scrolled off the screen so far
+func GenerateScrollback(first, total uint64, lines [][]byte) *Payload_Scrollback {
+	return &Payload_Scrollback{
+		Scrollback: &scrollback.Scrollback{
+			First: first,
+			Lines: lines,
+			Total: total,
 		},
 	}
 }
@@ -106,8 +106,9 @@ func TestGenerateError(t *testing.T) {
 
 func TestGenerateAuthResp(t *testing.T) {
 	type args struct {
-		name string
-		pass string
+		name         string
+		pakeMsg      []byte
+		confirmation []byte
 	}
 	tests := []struct {
 		name string
@@ -118,7 +119,7 @@ func TestGenerateAuthResp(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := GenerateAuthResp(tt.args.name, tt.args.pass); !reflect.DeepEqual(got, tt.want) {
+			if got := GenerateAuthResp(tt.args.name, tt.args.pakeMsg, tt.args.confirmation); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GenerateAuthResp() = %v, want %v", got, tt.want)
 			}
 		})
@@ -127,14 +128,15 @@ func TestGenerateAuthResp(t *testing.T) {
 
 func TestGenerateAuthReq(t *testing.T) {
 	tests := []struct {
-		name string
-		want *Payload_Auth
+		name    string
+		pakeMsg []byte
+		want    *Payload_Auth
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := GenerateAuthReq(); !reflect.DeepEqual(got, tt.want) {
+			if got := GenerateAuthReq(tt.pakeMsg); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GenerateAuthReq() = %v, want %v", got, tt.want)
 			}
 		})
@@ -143,9 +145,9 @@ func TestGenerateAuthReq(t *testing.T) {
 
 func TestGenerateTermContent(t *testing.T) {
 	type args struct {
-		msgId  string
-		msgLen int32
-		data   []byte
+		msgId string
+		seq   uint64
+		data  []byte
 	}
 	tests := []struct {
 		name string
@@ -156,7 +158,7 @@ func TestGenerateTermContent(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := GenerateTermContent(tt.args.msgId, tt.args.msgLen, tt.args.data); !reflect.DeepEqual(got, tt.want) {
+			if got := GenerateTermContent(tt.args.msgId, tt.args.seq, tt.args.data); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GenerateTermContent() = %v, want %v", got, tt.want)
 			}
 		})
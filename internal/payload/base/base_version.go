@@ -0,0 +1,149 @@
+package base
+
+import (
+	"encoding/binary"
+	"willofdaedalus/superluminal/internal/payload/auth"
+)
+
+const (
+	// PayloadVersion is the version of the Payload envelope itself. payloads
+	// from a newer envelope are turned away by DecodePayload instead of being
+	// half understood
+	PayloadVersion = 1
+
+	// MinProtocolVersion and MaxProtocolVersion are the protocol versions this
+	// build speaks. peers agree on the newest one they both speak while
+	// authenticating
+	MinProtocolVersion = 1
+	MaxProtocolVersion = 1
+)
+
+// Capabilities is a set of the optional things a peer can do
+type Capabilities uint64
+
+// SupportedCapabilities is everything this build can do
+var SupportedCapabilities = NewCapabilities(
+	auth.Capability_CAPABILITY_INPUT,
+	auth.Capability_CAPABILITY_RESUME,
+	auth.Capability_CAPABILITY_WINDOWS,
+	auth.Capability_CAPABILITY_SCROLLBACK,
+	auth.Capability_CAPABILITY_COMPRESSION,
+	auth.Capability_CAPABILITY_SCREEN_DIFF,
+)
+
+// OptInCapabilities change what a client is sent so clients only ask for them
+// when their user wants them rather than whenever both sides can
+var OptInCapabilities = NewCapabilities(
+	auth.Capability_CAPABILITY_SCREEN_DIFF,
+)
+
+// DefaultCapabilities is what a client asks for unless its user says otherwise
+var DefaultCapabilities = SupportedCapabilities &^ OptInCapabilities
+
+// NewCapabilities makes a set out of caps. capabilities too new for this build
+// to know about are left out
+func NewCapabilities(caps ...auth.Capability) Capabilities {
+	var set Capabilities
+	for _, c := range caps {
+		if c > 0 && c < 64 {
+			set |= 1 << c
+		}
+	}
+	return set
+}
+
+// Has reports whether c is in the set
+func (set Capabilities) Has(c auth.Capability) bool {
+	return c > 0 && c < 64 && set&(1<<c) != 0
+}
+
+// List returns the capabilities in the set for sending to a peer
+func (set Capabilities) List() []auth.Capability {
+	caps := make([]auth.Capability, 0)
+	for c := auth.Capability(1); c < 64; c++ {
+		if set.Has(c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// NegotiateVersion returns the newest protocol version both this build and a
+// peer speaking peerMin to peerMax speak and false if there isn't one. a peer
+// that didn't offer any versions shares none with us since the offers have to
+// be bound into the key exchange
+func NegotiateVersion(peerMin, peerMax uint32) (uint32, bool) {
+	version := min(peerMax, MaxProtocolVersion)
+	if version < max(peerMin, MinProtocolVersion) {
+		return 0, false
+	}
+	return version, true
+}
+
+// Negotiation is what a client and session offered each other while
+// authenticating and what they agreed on from it
+type Negotiation struct {
+	ClientMin, ClientMax uint32
+	ClientCaps           Capabilities
+	ServerMin, ServerMax uint32
+	ServerCaps           Capabilities
+	Version              uint32
+	Caps                 Capabilities
+}
+
+// NegotiateWithClient works out what the session agrees on with a client that
+// answered its auth request with resp and false if they share no version
+func NegotiateWithClient(resp *auth.AuthResponse) (Negotiation, bool) {
+	n := Negotiation{
+		ClientMin:  resp.GetMinVersion(),
+		ClientMax:  resp.GetMaxVersion(),
+		ClientCaps: NewCapabilities(resp.GetCapabilities()...),
+		ServerMin:  MinProtocolVersion,
+		ServerMax:  MaxProtocolVersion,
+		ServerCaps: SupportedCapabilities,
+	}
+
+	version, ok := NegotiateVersion(n.ClientMin, n.ClientMax)
+	if !ok {
+		return n, false
+	}
+	n.Version = version
+	n.Caps = n.ClientCaps & n.ServerCaps
+	return n, true
+}
+
+// NegotiateWithServer works out what a client asking for wants agrees on with a
+// session that sent it req and false if they share no version
+func NegotiateWithServer(req *auth.AuthRequest, wants Capabilities) (Negotiation, bool) {
+	n := Negotiation{
+		ClientMin:  MinProtocolVersion,
+		ClientMax:  MaxProtocolVersion,
+		ClientCaps: wants,
+		ServerMin:  req.GetMinVersion(),
+		ServerMax:  req.GetMaxVersion(),
+		ServerCaps: NewCapabilities(req.GetCapabilities()...),
+	}
+
+	version, ok := NegotiateVersion(n.ServerMin, n.ServerMax)
+	if !ok {
+		return n, false
+	}
+	n.Version = version
+	n.Caps = n.ServerCaps & wants
+	return n, true
+}
+
+// Transcript returns the negotiation for binding into the key exchange so the
+// confirmations fail if anyone in the middle changed an offer to talk the two
+// sides down to an older version or fewer capabilities
+func (n Negotiation) Transcript() []byte {
+	out := []byte("superluminal negotiation")
+	for _, v := range []uint64{
+		uint64(n.ClientMin), uint64(n.ClientMax), uint64(n.ClientCaps),
+		uint64(n.ServerMin), uint64(n.ServerMax), uint64(n.ServerCaps),
+		uint64(n.Version), uint64(n.Caps),
+	} {
+		out = binary.BigEndian.AppendUint64(out, v)
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package base
+
+import (
+	"errors"
+	"testing"
+	"willofdaedalus/superluminal/internal/payload/auth"
+	"willofdaedalus/superluminal/internal/utils"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max uint32
+		want     uint32
+		wantOk   bool
+	}{
+		{"same range", MinProtocolVersion, MaxProtocolVersion, MaxProtocolVersion, true},
+		{"newer peer that still speaks ours", MinProtocolVersion, MaxProtocolVersion + 3, MaxProtocolVersion, true},
+		{"peer that's moved on", MaxProtocolVersion + 1, MaxProtocolVersion + 3, 0, false},
+		{"peer that didn't offer any", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NegotiateVersion(tt.min, tt.max)
+			if got != tt.want || ok != tt.wantOk {
+				t.Fatalf("NegotiateVersion() = %d, %v want %d, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := NewCapabilities(auth.Capability_CAPABILITY_WINDOWS, auth.Capability_CAPABILITY_INPUT, 200)
+	if !caps.Has(auth.Capability_CAPABILITY_WINDOWS) || caps.Has(auth.Capability_CAPABILITY_RESUME) {
+		t.Fatalf("unexpected capabilities %v", caps.List())
+	}
+	if got := NewCapabilities(caps.List()...); got != caps {
+		t.Fatalf("expected the list to round trip got %v want %v", got.List(), caps.List())
+	}
+}
+
+func TestNegotiateWithoutOffers(t *testing.T) {
+	// without offers there's nothing to bind into the key exchange so anyone
+	// in the middle could strip them to talk both sides down
+	if _, ok := NegotiateWithClient(&auth.AuthResponse{}); ok {
+		t.Fatal("expected a client that didn't offer anything to be refused")
+	}
+	if _, ok := NegotiateWithServer(&auth.AuthRequest{}, DefaultCapabilities); ok {
+		t.Fatal("expected a session that didn't offer anything to be refused")
+	}
+
+	// an offer of no capabilities is still an offer
+	n, ok := NegotiateWithClient(&auth.AuthResponse{MinVersion: MinProtocolVersion, MaxVersion: MaxProtocolVersion})
+	if !ok || n.Caps != 0 || len(n.Transcript()) == 0 {
+		t.Fatalf("expected to agree on no capabilities got %v, %v", n, ok)
+	}
+}
+
+func TestDecodePayloadVersion(t *testing.T) {
+	data, err := proto.Marshal(&Payload{Version: PayloadVersion + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodePayload(data); !errors.Is(err, utils.ErrUnsupportedVersion) {
+		t.Fatalf("expected %v got %v", utils.ErrUnsupportedVersion, err)
+	}
+}
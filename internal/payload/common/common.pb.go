@@ -23,34 +23,55 @@ const (
 type Header int32
 
 const (
-	Header_HEADER_UNSPECIFIED   Header = 0
-	Header_HEADER_AUTH          Header = 1
-	Header_HEADER_INFO          Header = 2
-	Header_HEADER_HEARTBEAT     Header = 3
-	Header_HEADER_TERMINAL_DATA Header = 4
-	Header_HEADER_RESEND_REQ    Header = 5
-	Header_HEADER_ERROR         Header = 6
+	Header_HEADER_UNSPECIFIED    Header = 0
+	Header_HEADER_AUTH           Header = 1
+	Header_HEADER_INFO           Header = 2
+	Header_HEADER_HEARTBEAT      Header = 3
+	Header_HEADER_TERMINAL_DATA  Header = 4
+	Header_HEADER_RESEND_REQ     Header = 5
+	Header_HEADER_ERROR          Header = 6
+	Header_HEADER_RESIZE         Header = 7
+	Header_HEADER_CLIENT_INPUT   Header = 8
+	Header_HEADER_WINDOW_LIST    Header = 9
+	Header_HEADER_WINDOW_SELECT  Header = 10
+	Header_HEADER_SCROLLBACK_REQ Header = 11
+	Header_HEADER_SCROLLBACK     Header = 12
+	Header_HEADER_SCREEN_DIFF    Header = 13
 )
 
 // Enum value maps for Header.
 var (
 	Header_name = map[int32]string{
-		0: "HEADER_UNSPECIFIED",
-		1: "HEADER_AUTH",
-		2: "HEADER_INFO",
-		3: "HEADER_HEARTBEAT",
-		4: "HEADER_TERMINAL_DATA",
-		5: "HEADER_RESEND_REQ",
-		6: "HEADER_ERROR",
+		0:  "HEADER_UNSPECIFIED",
+		1:  "HEADER_AUTH",
+		2:  "HEADER_INFO",
+		3:  "HEADER_HEARTBEAT",
+		4:  "HEADER_TERMINAL_DATA",
+		5:  "HEADER_RESEND_REQ",
+		6:  "HEADER_ERROR",
+		7:  "HEADER_RESIZE",
+		8:  "HEADER_CLIENT_INPUT",
+		9:  "HEADER_WINDOW_LIST",
+		10: "HEADER_WINDOW_SELECT",
+		11: "HEADER_SCROLLBACK_REQ",
+		12: "HEADER_SCROLLBACK",
+		13: "HEADER_SCREEN_DIFF",
 	}
 	Header_value = map[string]int32{
-		"HEADER_UNSPECIFIED":   0,
-		"HEADER_AUTH":          1,
-		"HEADER_INFO":          2,
-		"HEADER_HEARTBEAT":     3,
-		"HEADER_TERMINAL_DATA": 4,
-		"HEADER_RESEND_REQ":    5,
-		"HEADER_ERROR":         6,
+		"HEADER_UNSPECIFIED":    0,
+		"HEADER_AUTH":           1,
+		"HEADER_INFO":           2,
+		"HEADER_HEARTBEAT":      3,
+		"HEADER_TERMINAL_DATA":  4,
+		"HEADER_RESEND_REQ":     5,
+		"HEADER_ERROR":          6,
+		"HEADER_RESIZE":         7,
+		"HEADER_CLIENT_INPUT":   8,
+		"HEADER_WINDOW_LIST":    9,
+		"HEADER_WINDOW_SELECT":  10,
+		"HEADER_SCROLLBACK_REQ": 11,
+		"HEADER_SCROLLBACK":     12,
+		"HEADER_SCREEN_DIFF":    13,
 	}
 )
 
@@ -84,8 +105,8 @@ func (Header) EnumDescriptor() ([]byte, []int) {
 var File_common_proto protoreflect.FileDescriptor
 
 var file_common_proto_rawDesc = []byte{
-	0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2a, 0x9b,
-	0x01, 0x0a, 0x06, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x12, 0x48, 0x45, 0x41,
+	0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2a, 0xc3,
+	0x02, 0x0a, 0x06, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x12, 0x48, 0x45, 0x41,
 	0x44, 0x45, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10,
 	0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x41, 0x55, 0x54, 0x48,
 	0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x49, 0x4e, 0x46,
@@ -94,11 +115,22 @@ var file_common_proto_rawDesc = []byte{
 	0x44, 0x45, 0x52, 0x5f, 0x54, 0x45, 0x52, 0x4d, 0x49, 0x4e, 0x41, 0x4c, 0x5f, 0x44, 0x41, 0x54,
 	0x41, 0x10, 0x04, 0x12, 0x15, 0x0a, 0x11, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x52, 0x45,
 	0x53, 0x45, 0x4e, 0x44, 0x5f, 0x52, 0x45, 0x51, 0x10, 0x05, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x45,
-	0x41, 0x44, 0x45, 0x52, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x06, 0x42, 0x35, 0x5a, 0x33,
-	0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73,
-	0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65,
-	0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x63, 0x6f, 0x6d,
-	0x6d, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x41, 0x44, 0x45, 0x52, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x06, 0x12, 0x11, 0x0a, 0x0d,
+	0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x52, 0x45, 0x53, 0x49, 0x5a, 0x45, 0x10, 0x07, 0x12,
+	0x17, 0x0a, 0x13, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54,
+	0x5f, 0x49, 0x4e, 0x50, 0x55, 0x54, 0x10, 0x08, 0x12, 0x16, 0x0a, 0x12, 0x48, 0x45, 0x41, 0x44,
+	0x45, 0x52, 0x5f, 0x57, 0x49, 0x4e, 0x44, 0x4f, 0x57, 0x5f, 0x4c, 0x49, 0x53, 0x54, 0x10, 0x09,
+	0x12, 0x18, 0x0a, 0x14, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x57, 0x49, 0x4e, 0x44, 0x4f,
+	0x57, 0x5f, 0x53, 0x45, 0x4c, 0x45, 0x43, 0x54, 0x10, 0x0a, 0x12, 0x19, 0x0a, 0x15, 0x48, 0x45,
+	0x41, 0x44, 0x45, 0x52, 0x5f, 0x53, 0x43, 0x52, 0x4f, 0x4c, 0x4c, 0x42, 0x41, 0x43, 0x4b, 0x5f,
+	0x52, 0x45, 0x51, 0x10, 0x0b, 0x12, 0x15, 0x0a, 0x11, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f,
+	0x53, 0x43, 0x52, 0x4f, 0x4c, 0x4c, 0x42, 0x41, 0x43, 0x4b, 0x10, 0x0c, 0x12, 0x16, 0x0a, 0x12,
+	0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x5f, 0x53, 0x43, 0x52, 0x45, 0x45, 0x4e, 0x5f, 0x44, 0x49,
+	0x46, 0x46, 0x10, 0x0d, 0x42, 0x35, 0x5a, 0x33, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61,
+	0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69,
+	0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -23,25 +23,48 @@ const (
 type ErrorMessage_ErrorCode int32
 
 const (
-	ErrorMessage_ERROR_UNSPECIFIED  ErrorMessage_ErrorCode = 0
-	ErrorMessage_ERROR_AUTH_FAILED  ErrorMessage_ErrorCode = 1
-	ErrorMessage_ERROR_CRC_MISMATCH ErrorMessage_ErrorCode = 2
-	ErrorMessage_ERROR_SERVER_FULL  ErrorMessage_ErrorCode = 3
+	ErrorMessage_ERROR_UNSPECIFIED      ErrorMessage_ErrorCode = 0
+	ErrorMessage_ERROR_AUTH_FAILED      ErrorMessage_ErrorCode = 1
+	ErrorMessage_ERROR_CRC_MISMATCH     ErrorMessage_ErrorCode = 2
+	ErrorMessage_ERROR_SERVER_FULL      ErrorMessage_ErrorCode = 3
+	ErrorMessage_ERROR_APPROVAL_TIMEOUT ErrorMessage_ErrorCode = 5
+	ErrorMessage_ERROR_RESUME_FAILED    ErrorMessage_ErrorCode = 6
+	// the host removed the client; detail has the reason
+	ErrorMessage_ERROR_KICKED ErrorMessage_ErrorCode = 7
+	ErrorMessage_ERROR_BANNED ErrorMessage_ErrorCode = 8
+	// the client and session don't speak a common protocol version;
+	// detail says which versions each of them speak
+	ErrorMessage_ERROR_VERSION_MISMATCH ErrorMessage_ErrorCode = 9
+	// numbered after everything else so older builds that took 4 to mean
+	// resend unavailable don't misread it
+	ErrorMessage_ERROR_APPROVAL_DENIED ErrorMessage_ErrorCode = 10
 )
 
 // Enum value maps for ErrorMessage_ErrorCode.
 var (
 	ErrorMessage_ErrorCode_name = map[int32]string{
-		0: "ERROR_UNSPECIFIED",
-		1: "ERROR_AUTH_FAILED",
-		2: "ERROR_CRC_MISMATCH",
-		3: "ERROR_SERVER_FULL",
+		0:  "ERROR_UNSPECIFIED",
+		1:  "ERROR_AUTH_FAILED",
+		2:  "ERROR_CRC_MISMATCH",
+		3:  "ERROR_SERVER_FULL",
+		5:  "ERROR_APPROVAL_TIMEOUT",
+		6:  "ERROR_RESUME_FAILED",
+		7:  "ERROR_KICKED",
+		8:  "ERROR_BANNED",
+		9:  "ERROR_VERSION_MISMATCH",
+		10: "ERROR_APPROVAL_DENIED",
 	}
 	ErrorMessage_ErrorCode_value = map[string]int32{
-		"ERROR_UNSPECIFIED":  0,
-		"ERROR_AUTH_FAILED":  1,
-		"ERROR_CRC_MISMATCH": 2,
-		"ERROR_SERVER_FULL":  3,
+		"ERROR_UNSPECIFIED":      0,
+		"ERROR_AUTH_FAILED":      1,
+		"ERROR_CRC_MISMATCH":     2,
+		"ERROR_SERVER_FULL":      3,
+		"ERROR_APPROVAL_TIMEOUT": 5,
+		"ERROR_RESUME_FAILED":    6,
+		"ERROR_KICKED":           7,
+		"ERROR_BANNED":           8,
+		"ERROR_VERSION_MISMATCH": 9,
+		"ERROR_APPROVAL_DENIED":  10,
 	}
 )
 
@@ -136,25 +159,36 @@ func (x *ErrorMessage) GetDetail() []byte {
 var File_error_proto protoreflect.FileDescriptor
 
 var file_error_proto_rawDesc = []byte{
-	0x0a, 0x0b, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xd7, 0x01,
+	0x0a, 0x0b, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x88, 0x03,
 	0x0a, 0x0c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2b,
 	0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x45,
 	0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x72, 0x72, 0x6f,
 	0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d,
 	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x6d, 0x65,
 	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x22, 0x68, 0x0a,
-	0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52,
-	0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10,
-	0x00, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x41, 0x55, 0x54, 0x48, 0x5f,
-	0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12, 0x45, 0x52, 0x52, 0x4f,
-	0x52, 0x5f, 0x43, 0x52, 0x43, 0x5f, 0x4d, 0x49, 0x53, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x02,
-	0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x45, 0x52,
-	0x5f, 0x46, 0x55, 0x4c, 0x4c, 0x10, 0x03, 0x42, 0x34, 0x5a, 0x32, 0x77, 0x69, 0x6c, 0x6c, 0x6f,
-	0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c,
-	0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
-	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x22, 0x98, 0x02,
+	0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x15, 0x0a, 0x11, 0x45,
+	0x52, 0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
+	0x10, 0x00, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x41, 0x55, 0x54, 0x48,
+	0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x43, 0x52, 0x43, 0x5f, 0x4d, 0x49, 0x53, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10,
+	0x02, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x45,
+	0x52, 0x5f, 0x46, 0x55, 0x4c, 0x4c, 0x10, 0x03, 0x12, 0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52, 0x4f,
+	0x52, 0x5f, 0x41, 0x50, 0x50, 0x52, 0x4f, 0x56, 0x41, 0x4c, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x4f,
+	0x55, 0x54, 0x10, 0x05, 0x12, 0x17, 0x0a, 0x13, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x52, 0x45,
+	0x53, 0x55, 0x4d, 0x45, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x06, 0x12, 0x10, 0x0a,
+	0x0c, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x4b, 0x49, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x07, 0x12,
+	0x10, 0x0a, 0x0c, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x42, 0x41, 0x4e, 0x4e, 0x45, 0x44, 0x10,
+	0x08, 0x12, 0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x56, 0x45, 0x52, 0x53, 0x49,
+	0x4f, 0x4e, 0x5f, 0x4d, 0x49, 0x53, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x09, 0x12, 0x19, 0x0a,
+	0x15, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x41, 0x50, 0x50, 0x52, 0x4f, 0x56, 0x41, 0x4c, 0x5f,
+	0x44, 0x45, 0x4e, 0x49, 0x45, 0x44, 0x10, 0x0a, 0x22, 0x04, 0x08, 0x04, 0x10, 0x04, 0x2a, 0x18,
+	0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x52, 0x45, 0x53, 0x45, 0x4e, 0x44, 0x5f, 0x55, 0x4e, 0x41,
+	0x56, 0x41, 0x49, 0x4c, 0x41, 0x42, 0x4c, 0x45, 0x42, 0x34, 0x5a, 0x32, 0x77, 0x69, 0x6c, 0x6c,
+	0x6f, 0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72,
+	0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c,
+	0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -23,10 +23,19 @@ const (
 type Info_InfoType int32
 
 const (
-	Info_INFO_UNSPECIFIED  Info_InfoType = 0
-	Info_INFO_AUTH_SUCCESS Info_InfoType = 1
-	Info_INFO_SHUTDOWN     Info_InfoType = 2
-	Info_INFO_REQ_ACK      Info_InfoType = 3
+	Info_INFO_UNSPECIFIED       Info_InfoType = 0
+	Info_INFO_AUTH_SUCCESS      Info_InfoType = 1
+	Info_INFO_SHUTDOWN          Info_InfoType = 2
+	Info_INFO_REQ_ACK           Info_InfoType = 3
+	Info_INFO_AWAITING_APPROVAL Info_InfoType = 4
+	Info_INFO_WRITE_GRANTED     Info_InfoType = 5
+	Info_INFO_WRITE_REVOKED     Info_InfoType = 6
+	// the host stopped the stream for a while; a fresh screen follows
+	// INFO_STREAM_RESUMED
+	Info_INFO_STREAM_PAUSED  Info_InfoType = 7
+	Info_INFO_STREAM_RESUMED Info_InfoType = 8
+	// the program the session was sharing ended; INFO_SHUTDOWN follows
+	Info_INFO_PROGRAM_EXITED Info_InfoType = 9
 )
 
 // Enum value maps for Info_InfoType.
@@ -36,12 +45,24 @@ var (
 		1: "INFO_AUTH_SUCCESS",
 		2: "INFO_SHUTDOWN",
 		3: "INFO_REQ_ACK",
+		4: "INFO_AWAITING_APPROVAL",
+		5: "INFO_WRITE_GRANTED",
+		6: "INFO_WRITE_REVOKED",
+		7: "INFO_STREAM_PAUSED",
+		8: "INFO_STREAM_RESUMED",
+		9: "INFO_PROGRAM_EXITED",
 	}
 	Info_InfoType_value = map[string]int32{
-		"INFO_UNSPECIFIED":  0,
-		"INFO_AUTH_SUCCESS": 1,
-		"INFO_SHUTDOWN":     2,
-		"INFO_REQ_ACK":      3,
+		"INFO_UNSPECIFIED":       0,
+		"INFO_AUTH_SUCCESS":      1,
+		"INFO_SHUTDOWN":          2,
+		"INFO_REQ_ACK":           3,
+		"INFO_AWAITING_APPROVAL": 4,
+		"INFO_WRITE_GRANTED":     5,
+		"INFO_WRITE_REVOKED":     6,
+		"INFO_STREAM_PAUSED":     7,
+		"INFO_STREAM_RESUMED":    8,
+		"INFO_PROGRAM_EXITED":    9,
 	}
 )
 
@@ -79,6 +100,11 @@ type Info struct {
 
 	InfoType Info_InfoType `protobuf:"varint,1,opt,name=infoType,proto3,enum=Info_InfoType" json:"infoType,omitempty"`
 	Message  string        `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// sent with INFO_AUTH_SUCCESS so the client can take back its place if
+	// the connection drops without going through the passphrase again
+	Resume *ResumeToken `protobuf:"bytes,3,opt,name=resume,proto3" json:"resume,omitempty"`
+	// how the shared program exited; sent with INFO_PROGRAM_EXITED
+	ExitStatus int32 `protobuf:"varint,4,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
 }
 
 func (x *Info) Reset() {
@@ -125,25 +151,110 @@ func (x *Info) GetMessage() string {
 	return ""
 }
 
+func (x *Info) GetResume() *ResumeToken {
+	if x != nil {
+		return x.Resume
+	}
+	return nil
+}
+
+func (x *Info) GetExitStatus() int32 {
+	if x != nil {
+		return x.ExitStatus
+	}
+	return 0
+}
+
+type ResumeToken struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// used in place of the passphrase for the key exchange when resuming
+	Secret string `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+}
+
+func (x *ResumeToken) Reset() {
+	*x = ResumeToken{}
+	mi := &file_info_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeToken) ProtoMessage() {}
+
+func (x *ResumeToken) ProtoReflect() protoreflect.Message {
+	mi := &file_info_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeToken.ProtoReflect.Descriptor instead.
+func (*ResumeToken) Descriptor() ([]byte, []int) {
+	return file_info_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ResumeToken) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ResumeToken) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
 var File_info_proto protoreflect.FileDescriptor
 
 var file_info_proto_rawDesc = []byte{
-	0x0a, 0x0a, 0x69, 0x6e, 0x66, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xaa, 0x01, 0x0a,
+	0x0a, 0x0a, 0x69, 0x6e, 0x66, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x88, 0x03, 0x0a,
 	0x04, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x2a, 0x0a, 0x08, 0x69, 0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70,
 	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x49, 0x6e, 0x66, 0x6f, 0x2e, 0x49,
 	0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70, 0x65, 0x52, 0x08, 0x69, 0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70,
 	0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x5c, 0x0a, 0x08, 0x49,
-	0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x49, 0x4e, 0x46, 0x4f, 0x5f,
-	0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x15, 0x0a,
-	0x11, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x41, 0x55, 0x54, 0x48, 0x5f, 0x53, 0x55, 0x43, 0x43, 0x45,
-	0x53, 0x53, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x53, 0x48, 0x55,
-	0x54, 0x44, 0x4f, 0x57, 0x4e, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x49, 0x4e, 0x46, 0x4f, 0x5f,
-	0x52, 0x45, 0x51, 0x5f, 0x41, 0x43, 0x4b, 0x10, 0x03, 0x42, 0x33, 0x5a, 0x31, 0x77, 0x69, 0x6c,
-	0x6c, 0x6f, 0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65,
-	0x72, 0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61,
-	0x6c, 0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x69, 0x6e, 0x66, 0x6f, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x52, 0x65,
+	0x73, 0x75, 0x6d, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6d,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x65, 0x78, 0x69, 0x74, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x22, 0xf2, 0x01, 0x0a, 0x08, 0x49, 0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x14, 0x0a, 0x10, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
+	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x15, 0x0a, 0x11, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x41, 0x55,
+	0x54, 0x48, 0x5f, 0x53, 0x55, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d,
+	0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x53, 0x48, 0x55, 0x54, 0x44, 0x4f, 0x57, 0x4e, 0x10, 0x02, 0x12,
+	0x10, 0x0a, 0x0c, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x52, 0x45, 0x51, 0x5f, 0x41, 0x43, 0x4b, 0x10,
+	0x03, 0x12, 0x1a, 0x0a, 0x16, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x41, 0x57, 0x41, 0x49, 0x54, 0x49,
+	0x4e, 0x47, 0x5f, 0x41, 0x50, 0x50, 0x52, 0x4f, 0x56, 0x41, 0x4c, 0x10, 0x04, 0x12, 0x16, 0x0a,
+	0x12, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x57, 0x52, 0x49, 0x54, 0x45, 0x5f, 0x47, 0x52, 0x41, 0x4e,
+	0x54, 0x45, 0x44, 0x10, 0x05, 0x12, 0x16, 0x0a, 0x12, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x57, 0x52,
+	0x49, 0x54, 0x45, 0x5f, 0x52, 0x45, 0x56, 0x4f, 0x4b, 0x45, 0x44, 0x10, 0x06, 0x12, 0x16, 0x0a,
+	0x12, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x53, 0x54, 0x52, 0x45, 0x41, 0x4d, 0x5f, 0x50, 0x41, 0x55,
+	0x53, 0x45, 0x44, 0x10, 0x07, 0x12, 0x17, 0x0a, 0x13, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x53, 0x54,
+	0x52, 0x45, 0x41, 0x4d, 0x5f, 0x52, 0x45, 0x53, 0x55, 0x4d, 0x45, 0x44, 0x10, 0x08, 0x12, 0x17,
+	0x0a, 0x13, 0x49, 0x4e, 0x46, 0x4f, 0x5f, 0x50, 0x52, 0x4f, 0x47, 0x52, 0x41, 0x4d, 0x5f, 0x45,
+	0x58, 0x49, 0x54, 0x45, 0x44, 0x10, 0x09, 0x22, 0x35, 0x0a, 0x0b, 0x52, 0x65, 0x73, 0x75, 0x6d,
+	0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x42, 0x33,
+	0x5a, 0x31, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73,
+	0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x69,
+	0x6e, 0x66, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -159,18 +270,20 @@ func file_info_proto_rawDescGZIP() []byte {
 }
 
 var file_info_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_info_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_info_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_info_proto_goTypes = []any{
-	(Info_InfoType)(0), // 0: Info.InfoType
-	(*Info)(nil),       // 1: Info
+	(Info_InfoType)(0),  // 0: Info.InfoType
+	(*Info)(nil),        // 1: Info
+	(*ResumeToken)(nil), // 2: ResumeToken
 }
 var file_info_proto_depIdxs = []int32{
 	0, // 0: Info.infoType:type_name -> Info.InfoType
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	2, // 1: Info.resume:type_name -> ResumeToken
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_info_proto_init() }
@@ -184,7 +297,7 @@ func file_info_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_info_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   1,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
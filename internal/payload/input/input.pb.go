@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.29.0--rc2
+// source: input.proto
+
+package input
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// keystrokes from a client with write access on their way to the pty
+type ClientInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *ClientInput) Reset() {
+	*x = ClientInput{}
+	mi := &file_input_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientInput) ProtoMessage() {}
+
+func (x *ClientInput) ProtoReflect() protoreflect.Message {
+	mi := &file_input_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientInput.ProtoReflect.Descriptor instead.
+func (*ClientInput) Descriptor() ([]byte, []int) {
+	return file_input_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ClientInput) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_input_proto protoreflect.FileDescriptor
+
+var file_input_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x21, 0x0a,
+	0x0b, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x42, 0x34, 0x5a, 0x32, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c,
+	0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x2f, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_input_proto_rawDescOnce sync.Once
+	file_input_proto_rawDescData = file_input_proto_rawDesc
+)
+
+func file_input_proto_rawDescGZIP() []byte {
+	file_input_proto_rawDescOnce.Do(func() {
+		file_input_proto_rawDescData = protoimpl.X.CompressGZIP(file_input_proto_rawDescData)
+	})
+	return file_input_proto_rawDescData
+}
+
+var file_input_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_input_proto_goTypes = []any{
+	(*ClientInput)(nil), // 0: ClientInput
+}
+var file_input_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_input_proto_init() }
+func file_input_proto_init() {
+	if File_input_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_input_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_input_proto_goTypes,
+		DependencyIndexes: file_input_proto_depIdxs,
+		MessageInfos:      file_input_proto_msgTypes,
+	}.Build()
+	File_input_proto = out.File
+	file_input_proto_rawDesc = nil
+	file_input_proto_goTypes = nil
+	file_input_proto_depIdxs = nil
+}
@@ -0,0 +1,320 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.29.0--rc2
+// source: screen.proto
+
+package screen
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// cells next to each other on one row that share their attributes
+type Run struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Row uint32 `protobuf:"varint,1,opt,name=row,proto3" json:"row,omitempty"`
+	Col uint32 `protobuf:"varint,2,opt,name=col,proto3" json:"col,omitempty"`
+	// the characters of the run; wide ones take up two cells
+	Text string `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	// bold, faint, italic, underline, blink, inverse, hidden and strike in
+	// that order from the lowest bit
+	Flags uint32 `protobuf:"varint,4,opt,name=flags,proto3" json:"flags,omitempty"`
+	// 0 is the terminal's own colour, 1 to 256 indexed colour n-1 and
+	// anything with bit 24 set the rgb colour in the bits below it
+	Fg uint32 `protobuf:"varint,5,opt,name=fg,proto3" json:"fg,omitempty"`
+	Bg uint32 `protobuf:"varint,6,opt,name=bg,proto3" json:"bg,omitempty"`
+	// the rest of the row after the text is blank
+	Erase bool `protobuf:"varint,7,opt,name=erase,proto3" json:"erase,omitempty"`
+}
+
+func (x *Run) Reset() {
+	*x = Run{}
+	mi := &file_screen_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Run) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Run) ProtoMessage() {}
+
+func (x *Run) ProtoReflect() protoreflect.Message {
+	mi := &file_screen_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Run.ProtoReflect.Descriptor instead.
+func (*Run) Descriptor() ([]byte, []int) {
+	return file_screen_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Run) GetRow() uint32 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *Run) GetCol() uint32 {
+	if x != nil {
+		return x.Col
+	}
+	return 0
+}
+
+func (x *Run) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Run) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *Run) GetFg() uint32 {
+	if x != nil {
+		return x.Fg
+	}
+	return 0
+}
+
+func (x *Run) GetBg() uint32 {
+	if x != nil {
+		return x.Bg
+	}
+	return 0
+}
+
+func (x *Run) GetErase() bool {
+	if x != nil {
+		return x.Erase
+	}
+	return false
+}
+
+// what changed on the screen of a window since the last diff the client was
+// sent. clients that ask for these get them instead of the raw output
+type ScreenDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Window uint32 `protobuf:"varint,1,opt,name=window,proto3" json:"window,omitempty"`
+	Rows   uint32 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+	Cols   uint32 `protobuf:"varint,3,opt,name=cols,proto3" json:"cols,omitempty"`
+	// the client should start over from a blank screen
+	Full bool `protobuf:"varint,4,opt,name=full,proto3" json:"full,omitempty"`
+	// lines the screen scrolled up by before the runs are drawn
+	Scroll        uint32 `protobuf:"varint,5,opt,name=scroll,proto3" json:"scroll,omitempty"`
+	Runs          []*Run `protobuf:"bytes,6,rep,name=runs,proto3" json:"runs,omitempty"`
+	CursorRow     uint32 `protobuf:"varint,7,opt,name=cursor_row,json=cursorRow,proto3" json:"cursor_row,omitempty"`
+	CursorCol     uint32 `protobuf:"varint,8,opt,name=cursor_col,json=cursorCol,proto3" json:"cursor_col,omitempty"`
+	CursorVisible bool   `protobuf:"varint,9,opt,name=cursor_visible,json=cursorVisible,proto3" json:"cursor_visible,omitempty"`
+}
+
+func (x *ScreenDiff) Reset() {
+	*x = ScreenDiff{}
+	mi := &file_screen_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScreenDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScreenDiff) ProtoMessage() {}
+
+func (x *ScreenDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_screen_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScreenDiff.ProtoReflect.Descriptor instead.
+func (*ScreenDiff) Descriptor() ([]byte, []int) {
+	return file_screen_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ScreenDiff) GetWindow() uint32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *ScreenDiff) GetScroll() uint32 {
+	if x != nil {
+		return x.Scroll
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetRuns() []*Run {
+	if x != nil {
+		return x.Runs
+	}
+	return nil
+}
+
+func (x *ScreenDiff) GetCursorRow() uint32 {
+	if x != nil {
+		return x.CursorRow
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetCursorCol() uint32 {
+	if x != nil {
+		return x.CursorCol
+	}
+	return 0
+}
+
+func (x *ScreenDiff) GetCursorVisible() bool {
+	if x != nil {
+		return x.CursorVisible
+	}
+	return false
+}
+
+var File_screen_proto protoreflect.FileDescriptor
+
+var file_screen_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x89,
+	0x01, 0x0a, 0x03, 0x52, 0x75, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x6f, 0x77, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x03, 0x72, 0x6f, 0x77, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x6f, 0x6c, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x63, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65,
+	0x78, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x66, 0x6c, 0x61, 0x67, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x66,
+	0x6c, 0x61, 0x67, 0x73, 0x12, 0x0e, 0x0a, 0x02, 0x66, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x02, 0x66, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x62, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x02, 0x62, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x61, 0x73, 0x65, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x05, 0x65, 0x72, 0x61, 0x73, 0x65, 0x22, 0xf7, 0x01, 0x0a, 0x0a, 0x53,
+	0x63, 0x72, 0x65, 0x65, 0x6e, 0x44, 0x69, 0x66, 0x66, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x04, 0x72, 0x6f, 0x77, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x75, 0x6c,
+	0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x66, 0x75, 0x6c, 0x6c, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x73,
+	0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x12, 0x18, 0x0a, 0x04, 0x72, 0x75, 0x6e, 0x73, 0x18, 0x06, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x04, 0x2e, 0x52, 0x75, 0x6e, 0x52, 0x04, 0x72, 0x75, 0x6e, 0x73, 0x12,
+	0x1d, 0x0a, 0x0a, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x5f, 0x72, 0x6f, 0x77, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x52, 0x6f, 0x77, 0x12, 0x1d,
+	0x0a, 0x0a, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x6c, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x09, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x43, 0x6f, 0x6c, 0x12, 0x25, 0x0a,
+	0x0e, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x5f, 0x76, 0x69, 0x73, 0x69, 0x62, 0x6c, 0x65, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x56, 0x69, 0x73,
+	0x69, 0x62, 0x6c, 0x65, 0x42, 0x35, 0x5a, 0x33, 0x77, 0x69, 0x6c, 0x6c, 0x6f, 0x66, 0x64, 0x61,
+	0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c, 0x75, 0x6d, 0x69,
+	0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_screen_proto_rawDescOnce sync.Once
+	file_screen_proto_rawDescData = file_screen_proto_rawDesc
+)
+
+func file_screen_proto_rawDescGZIP() []byte {
+	file_screen_proto_rawDescOnce.Do(func() {
+		file_screen_proto_rawDescData = protoimpl.X.CompressGZIP(file_screen_proto_rawDescData)
+	})
+	return file_screen_proto_rawDescData
+}
+
+var file_screen_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_screen_proto_goTypes = []any{
+	(*Run)(nil),        // 0: Run
+	(*ScreenDiff)(nil), // 1: ScreenDiff
+}
+var file_screen_proto_depIdxs = []int32{
+	0, // 0: ScreenDiff.runs:type_name -> Run
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_screen_proto_init() }
+func file_screen_proto_init() {
+	if File_screen_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_screen_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_screen_proto_goTypes,
+		DependencyIndexes: file_screen_proto_depIdxs,
+		MessageInfos:      file_screen_proto_msgTypes,
+	}.Build()
+	File_screen_proto = out.File
+	file_screen_proto_rawDesc = nil
+	file_screen_proto_goTypes = nil
+	file_screen_proto_depIdxs = nil
+}
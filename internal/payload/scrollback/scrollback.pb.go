@@ -0,0 +1,233 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.29.0--rc2
+// source: scrollback.proto
+
+package scrollback
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// a client asking for lines that have scrolled off the top of the screen of
+// the window it's watching. lines are numbered from 1 in the order they
+// scrolled off
+type ScrollbackRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From uint64 `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	// 0 means up to the newest line
+	To uint64 `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	// set instead of from and to for the newest lines
+	Last uint32 `protobuf:"varint,3,opt,name=last,proto3" json:"last,omitempty"`
+	// send a keyframe after the lines so the client can print them and get
+	// its screen back
+	Redraw bool `protobuf:"varint,4,opt,name=redraw,proto3" json:"redraw,omitempty"`
+}
+
+func (x *ScrollbackRequest) Reset() {
+	*x = ScrollbackRequest{}
+	mi := &file_scrollback_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrollbackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrollbackRequest) ProtoMessage() {}
+
+func (x *ScrollbackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_scrollback_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrollbackRequest.ProtoReflect.Descriptor instead.
+func (*ScrollbackRequest) Descriptor() ([]byte, []int) {
+	return file_scrollback_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ScrollbackRequest) GetFrom() uint64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *ScrollbackRequest) GetTo() uint64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *ScrollbackRequest) GetLast() uint32 {
+	if x != nil {
+		return x.Last
+	}
+	return 0
+}
+
+func (x *ScrollbackRequest) GetRedraw() bool {
+	if x != nil {
+		return x.Redraw
+	}
+	return false
+}
+
+type Scrollback struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the number of the first line in lines
+	First uint64 `protobuf:"varint,1,opt,name=first,proto3" json:"first,omitempty"`
+	// each line is drawn with escape sequences and has no line ending
+	Lines [][]byte `protobuf:"bytes,2,rep,name=lines,proto3" json:"lines,omitempty"`
+	// how many lines have scrolled off so far; the oldest ones may be gone
+	Total uint64 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+}
+
+func (x *Scrollback) Reset() {
+	*x = Scrollback{}
+	mi := &file_scrollback_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Scrollback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Scrollback) ProtoMessage() {}
+
+func (x *Scrollback) ProtoReflect() protoreflect.Message {
+	mi := &file_scrollback_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Scrollback.ProtoReflect.Descriptor instead.
+func (*Scrollback) Descriptor() ([]byte, []int) {
+	return file_scrollback_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Scrollback) GetFirst() uint64 {
+	if x != nil {
+		return x.First
+	}
+	return 0
+}
+
+func (x *Scrollback) GetLines() [][]byte {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *Scrollback) GetTotal() uint64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+var File_scrollback_proto protoreflect.FileDescriptor
+
+var file_scrollback_proto_rawDesc = []byte{
+	0x0a, 0x10, 0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0x63, 0x0a, 0x11, 0x53, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x0e, 0x0a, 0x02, 0x74,
+	0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x74, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x6c,
+	0x61, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x6c, 0x61, 0x73, 0x74, 0x12,
+	0x16, 0x0a, 0x06, 0x72, 0x65, 0x64, 0x72, 0x61, 0x77, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x72, 0x65, 0x64, 0x72, 0x61, 0x77, 0x22, 0x4e, 0x0a, 0x0a, 0x53, 0x63, 0x72, 0x6f, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x72, 0x73, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x66, 0x69, 0x72, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6c,
+	0x69, 0x6e, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x65,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x39, 0x5a, 0x37, 0x77, 0x69, 0x6c, 0x6c, 0x6f,
+	0x66, 0x64, 0x61, 0x65, 0x64, 0x61, 0x6c, 0x75, 0x73, 0x2f, 0x73, 0x75, 0x70, 0x65, 0x72, 0x6c,
+	0x75, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
+	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x2f, 0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61,
+	0x63, 0x6b, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_scrollback_proto_rawDescOnce sync.Once
+	file_scrollback_proto_rawDescData = file_scrollback_proto_rawDesc
+)
+
+func file_scrollback_proto_rawDescGZIP() []byte {
+	file_scrollback_proto_rawDescOnce.Do(func() {
+		file_scrollback_proto_rawDescData = protoimpl.X.CompressGZIP(file_scrollback_proto_rawDescData)
+	})
+	return file_scrollback_proto_rawDescData
+}
+
+var file_scrollback_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_scrollback_proto_goTypes = []any{
+	(*ScrollbackRequest)(nil), // 0: ScrollbackRequest
+	(*Scrollback)(nil),        // 1: Scrollback
+}
+var file_scrollback_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_scrollback_proto_init() }
+func file_scrollback_proto_init() {
+	if File_scrollback_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_scrollback_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_scrollback_proto_goTypes,
+		DependencyIndexes: file_scrollback_proto_depIdxs,
+		MessageInfos:      file_scrollback_proto_msgTypes,
+	}.Build()
+	File_scrollback_proto = out.File
+	file_scrollback_proto_rawDesc = nil
+	file_scrollback_proto_goTypes = nil
+	file_scrollback_proto_depIdxs = nil
+}
@@ -7,37 +7,99 @@ import (
 	"net"
 	"os"
 	"sync"
+	"time"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
 	"willofdaedalus/superluminal/internal/utils"
 
-	"github.com/google/uuid"
+	"github.com/creack/pty"
+)
+
+const (
+	// how many of the most recent frames are kept around for retransmission
+	maxRingFrames = 512
+	// how often Drain checks whether the consumers have caught up
+	drainInterval = time.Millisecond * 10
 )
 
 type Pipeline struct {
-	pty           *os.File
-	logFile       *os.File
+	src           Source
+	recorder      *recorder
 	mainClient    *os.File
-	consumers     map[net.Conn]struct{}
+	consumers     map[net.Conn]*consumer
 	consumerCount uint8
 	lastMsg       int
-	mu            sync.Mutex
-	stopChan      chan struct{}
+	seq           uint64
+	ring          *frameRing
+	screen        *vterm
+	policy        OverflowPolicy
+	queueSize     int
+	// output still reaches the screen and recorder but not consumers
+	paused bool
+	// the session window the pipeline is streaming and whether the host sees
+	// it; only one window is shown on the host's screen at a time
+	window uint32
+	hidden bool
+	// set when the source decides its own size instead of following the host's
+	fixedSize bool
+	// how the program behind the source exited once it has
+	exitStatus int
+	exited     bool
+	// output held back to go out as one frame with what comes right after it
+	// and how often each consumer can be sent a frame
+	batch         []byte
+	batchWindow   time.Duration
+	batchSize     int
+	batchTimer    *time.Timer
+	batchPending  bool
+	lastFrame     time.Time
+	frameInterval time.Duration
+	// rewrites the output before it reaches anyone but the host
+	filter     Filter
+	flushTimer *time.Timer
+	mu         sync.Mutex
+	stopChan   chan struct{}
 }
 
 // creates a new pipeline to bridge the pty and the rest of the world
 func NewPipeline(maxConns uint8) (*Pipeline, error) {
-	pty, err := createSession()
+	return NewCommandPipeline(maxConns, Command{})
+}
+
+// NewCommandPipeline creates a pipeline that shares cmd instead of the user's shell
+func NewCommandPipeline(maxConns uint8, cmd Command) (*Pipeline, error) {
+	ptmx, proc, err := createSession(cmd)
 	if err != nil {
 		return nil, err
 	}
-	file, _ := os.OpenFile("./log.output", os.O_CREATE|os.O_WRONLY, 0644)
+	rows, cols, err := pty.Getsize(ptmx)
+	if err != nil || rows == 0 || cols == 0 {
+		// the host's size couldn't be inherited so give programs something sane
+		rows, cols = defaultRows, defaultCols
+		pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	}
+
+	return NewSourcePipeline(maxConns, ptySource{ptmx, proc}, rows, cols), nil
+}
+
+// NewSourcePipeline creates a pipeline that streams src to its consumers
+// starting off with a rows x cols screen
+func NewSourcePipeline(maxConns uint8, src Source, rows, cols int) *Pipeline {
 	return &Pipeline{
-		pty:       pty,
-		consumers: make(map[net.Conn]struct{}, maxConns),
+		src:       src,
+		consumers: make(map[net.Conn]*consumer, maxConns),
 		stopChan:  make(chan struct{}),
-		logFile:   file,
-	}, nil
+		ring:      newFrameRing(maxRingFrames),
+		screen:    newVTerm(rows, cols),
+		policy:    Snapshot,
+		queueSize: defaultQueueSize,
+		// busy programs write in lots of small pieces that are better off
+		// going out together
+		batchWindow:   DefaultBatchWindow,
+		batchSize:     DefaultBatchSize,
+		frameInterval: time.Second / DefaultFrameRate,
+	}
 }
 
 // starts broadcasting pty output to all connected consumers
@@ -53,100 +115,471 @@ func (p *Pipeline) Start(done chan<- struct{}) {
 				// read from pty
 				buf := p.ReadFrom()
 				if buf == nil {
+					p.flushBatch()
+					p.waitForExit()
 					done <- struct{}{}
 					return
 				}
 
 				// this is for the client facing side so that they "see" what's happening
-				p.writeDataToScreen(buf)
-
-				// broadcast to all consumers
-				p.mu.Lock()
-
-				bufCopy := make([]byte, len(buf))
-				copy(bufCopy, buf)
-				termPayload := base.GenerateTermContent(uuid.NewString(), uint32(len(buf)), buf)
-				payload, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
-				if err != nil {
-					log.Println("failed to encode the terminal payload in pipeline.Start")
-					log.Println(err)
-					continue
-					// not quite sure what do with the error yet
+				if p.shownLocally() {
+					p.writeDataToScreen(buf)
 				}
-
-				for conn := range p.consumers {
-					// non-blocking write to prevent slow consumers from blocking
-					select {
-					case <-p.stopChan:
-						p.mu.Unlock()
-						return
-					default:
-						writeErr := utils.WriteFull(context.TODO(), conn, nil, payload)
-						if writeErr != nil {
-							fmt.Printf("Error writing to consumer: %v\n", writeErr)
-							// consider removing the failing consumer
-							delete(p.consumers, conn)
-						}
-					}
-				}
-				p.mu.Unlock()
+				p.broadcast(buf)
 			}
 		}
 	}()
 }
 
+// broadcast queues the data as the next frame for every consumer. queueing never
+// blocks so a slow consumer can't hold up the pty or anyone else
+func (p *Pipeline) broadcast(buf []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if buf = p.filterLocked(buf); len(buf) == 0 {
+		return
+	}
+	p.broadcastLocked(buf)
+}
+
+// broadcastLocked is broadcast for output that's already been filtered.
+// must be called with p.mu held
+func (p *Pipeline) broadcastLocked(buf []byte) {
+	p.batchLocked(buf)
+}
+
+// emitLocked sends buf out as the next frame. must be called with p.mu held
+func (p *Pipeline) emitLocked(buf []byte) {
+	if p.paused {
+		// consumers get the screen as it is once the stream resumes
+		p.screen.Write(buf)
+		p.recordLocked(func(r *recorder) error { return r.output(buf) })
+		return
+	}
+
+	f, err := encodeFrame(p.window, p.seq+1, p.seq+1, buf)
+	if err != nil {
+		log.Println("failed to encode the terminal payload in pipeline.Start")
+		log.Println(err)
+		return
+		// not quite sure what do with the error yet
+	}
+
+	p.seq += 1
+	p.ring.push(f)
+	p.screen.Write(buf)
+	p.recordLocked(func(r *recorder) error { return r.output(buf) })
+
+	for conn, c := range p.consumers {
+		if !c.enqueue(f) {
+			log.Printf("consumer %s fell too far behind; disconnecting", conn.RemoteAddr())
+			p.removeConsumerLocked(conn)
+		}
+	}
+}
+
+// waitForExit holds on to the exit status of the program behind the source once
+// it has finished
+func (p *Pipeline) waitForExit() {
+	if p.src == nil {
+		return
+	}
+
+	status, err := p.src.Wait()
+	if err != nil {
+		log.Println("couldn't get the exit status:", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exitStatus = status
+	p.exited = true
+}
+
+// ExitStatus returns the exit status of the program the pipeline is sharing and
+// whether it has exited
+func (p *Pipeline) ExitStatus() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exitStatus, p.exited
+}
+
 func (p *Pipeline) writeDataToScreen(data []byte) {
 	fmt.Printf("%s", string(data))
-	p.logFile.Write(data)
+}
+
+// Frames returns the encoded frames between from and to inclusive that are still
+// available for retransmission along with the oldest sequence number the pipeline
+// can still resend. If from is older than that, the frames before it are gone
+func (p *Pipeline) Frames(from, to uint64) ([][]byte, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	oldest, ok := p.ring.oldest()
+	if !ok {
+		// nothing has been sent yet so the next frame is the oldest we'll have
+		return nil, p.seq + 1
+	}
+
+	return p.ring.between(from, to), oldest
+}
+
+// ConsumerOptions are how a consumer wants to be sent the stream
+type ConsumerOptions struct {
+	// deflate terminal frames
+	Compress bool
+	// send diffs of the screen instead of the raw output
+	ScreenDiffs bool
 }
 
 // Add a new client to the pipeline
 func (p *Pipeline) Subscribe(conn net.Conn) {
+	p.SubscribeWith(conn, ConsumerOptions{})
+}
+
+// SubscribeWith adds a new client to the pipeline that wants the stream sent
+// the way opts says
+func (p *Pipeline) SubscribeWith(conn net.Conn, opts ConsumerOptions) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.consumers[conn] = struct{}{}
+
+	if p.consumers == nil {
+		p.consumers = make(map[net.Conn]*consumer)
+	}
+	if _, ok := p.consumers[conn]; ok {
+		return
+	}
+
+	c := newConsumer(conn, p.policy, p.queueSize)
+	c.interval = p.frameInterval
+	c.keyframe = p.keyframeLocked
+	if opts.Compress {
+		c.deflater = utils.NewDeflater()
+	}
+	if opts.ScreenDiffs {
+		c.differ = &screenDiffer{}
+		c.diff = func() ([]byte, error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return p.screenDiffLocked(c.differ)
+		}
+	}
+	p.consumers[conn] = c
 	p.consumerCount += 1
+
+	// the size and keyframe go out before anything else so the client starts
+	// off with the same screen as everyone else
+	if rf, err := p.resizeFrameLocked(); err == nil {
+		c.enqueue(rf)
+	} else {
+		log.Println("failed to build resize message:", err)
+	}
+	if p.paused {
+		// the screen isn't for anyone else's eyes right now so the keyframe
+		// waits until the stream resumes
+		if f, err := infoFrame(info.Info_INFO_STREAM_PAUSED, "the host paused the stream"); err == nil {
+			c.enqueue(f)
+		}
+	} else if kf, err := p.keyframeLocked(); err == nil {
+		c.enqueue(kf)
+	} else {
+		log.Println("failed to build keyframe:", err)
+	}
+
+	go c.writeLoop(func(err error) {
+		log.Printf("error writing to consumer %s: %v", conn.RemoteAddr(), err)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// make sure we're not removing a newer consumer on the same conn
+		if p.consumers[conn] == c {
+			p.removeConsumerLocked(conn)
+		}
+	})
+}
+
+// SendKeyframe queues a redraw of the whole screen for the consumer on conn. Anything
+// the consumer missed before it no longer matters once it has the keyframe
+func (p *Pipeline) SendKeyframe(conn net.Conn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.consumers[conn]
+	if !ok {
+		return fmt.Errorf("connection isn't subscribed to the pipeline")
+	}
+	if p.paused {
+		// every consumer gets one when the stream resumes
+		return nil
+	}
+
+	kf, err := p.keyframeLocked()
+	if err != nil {
+		return err
+	}
+
+	if !c.enqueue(kf) {
+		p.removeConsumerLocked(conn)
+		return utils.ErrConsumerTooSlow
+	}
+
+	return nil
+}
+
+// keyframeLocked encodes the current screen as a keyframe carrying the sequence
+// number of the last frame it includes. must be called with p.mu held
+func (p *Pipeline) keyframeLocked() (frame, error) {
+	if p.screen == nil {
+		return frame{}, fmt.Errorf("pipeline has no screen to snapshot")
+	}
+
+	termPayload := base.GenerateTermContent("", p.seq, p.screen.keyframe())
+	termPayload.TermContent.Keyframe = true
+	termPayload.TermContent.Window = p.window
+	payload, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
+	if err != nil {
+		return frame{}, err
+	}
+
+	// keyframes are left out of the data so they're never dropped or merged
+	return frame{window: p.window, seq: p.seq, payload: payload, content: termPayload.TermContent}, nil
+}
+
+// Resize changes the size of the pty and lets every consumer know about it so
+// they can check whether the session still fits on their screen. Sources that
+// decide their own size like recordings and mirrored terminals ignore it
+func (p *Pipeline) Resize(rows, cols int) error {
+	p.mu.Lock()
+	fixed := p.fixedSize
+	p.mu.Unlock()
+	if fixed {
+		return nil
+	}
+
+	return p.resize(rows, cols)
+}
+
+// resize is Resize for when the source itself changed size
+func (p *Pipeline) resize(rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", cols, rows)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.src != nil {
+		if err := p.src.SetSize(rows, cols); err != nil {
+			return err
+		}
+	}
+	if p.screen != nil {
+		p.screen.resize(rows, cols)
+	}
+	p.recordLocked(func(r *recorder) error { return r.resize(rows, cols) })
+
+	rf, err := p.resizeFrameLocked()
+	if err != nil {
+		return err
+	}
+
+	for conn, c := range p.consumers {
+		if !c.enqueue(rf) {
+			log.Printf("consumer %s fell too far behind; disconnecting", conn.RemoteAddr())
+			p.removeConsumerLocked(conn)
+		}
+	}
+
+	return nil
+}
+
+// Size returns the rows and columns of the pty
+func (p *Pipeline) Size() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.screen == nil {
+		return defaultRows, defaultCols
+	}
+	return p.screen.rows, p.screen.cols
+}
+
+// resizeFrameLocked encodes the current size of the pty.
+// must be called with p.mu held
+func (p *Pipeline) resizeFrameLocked() (frame, error) {
+	rows, cols := defaultRows, defaultCols
+	if p.screen != nil {
+		rows, cols = p.screen.rows, p.screen.cols
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_RESIZE, base.GenerateResize(uint32(rows), uint32(cols)))
+	if err != nil {
+		return frame{}, err
+	}
+
+	return frame{payload: payload}, nil
 }
 
 // Remove a client from the pipeline
 func (p *Pipeline) Unsubscribe(conn net.Conn) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+
+	c, ok := p.consumers[conn]
+	if !ok {
+		return
+	}
+
+	c.stop()
 	delete(p.consumers, conn)
 	if p.consumerCount > 0 {
 		p.consumerCount -= 1
 	}
 }
 
+// removeConsumerLocked drops a consumer that can't be written to anymore and
+// closes its connection so whoever is reading from it finds out.
+// must be called with p.mu held
+func (p *Pipeline) removeConsumerLocked(conn net.Conn) {
+	c, ok := p.consumers[conn]
+	if !ok {
+		return
+	}
+
+	c.stop()
+	conn.Close()
+	delete(p.consumers, conn)
+	if p.consumerCount > 0 {
+		p.consumerCount -= 1
+	}
+}
+
+// Send queues an already encoded payload for a single consumer behind whatever
+// is already waiting to be written to it
+func (p *Pipeline) Send(conn net.Conn, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.consumers[conn]
+	if !ok {
+		return fmt.Errorf("connection isn't subscribed to the pipeline")
+	}
+
+	if !c.enqueue(frame{payload: payload}) {
+		p.removeConsumerLocked(conn)
+		return utils.ErrConsumerTooSlow
+	}
+
+	return nil
+}
+
+// Drain waits until everything queued for every consumer has been written to
+// its connection so nothing is lost when the pipeline is closed after it
+func (p *Pipeline) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+		pending := false
+		for _, c := range p.consumers {
+			if !c.written() {
+				pending = true
+				break
+			}
+		}
+		p.mu.Unlock()
+		if !pending {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ConsumerStats returns how the queue of the consumer on conn is doing
+func (p *Pipeline) ConsumerStats(conn net.Conn) (ConsumerStats, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.consumers[conn]
+	if !ok {
+		return ConsumerStats{}, false
+	}
+
+	return c.stats(), true
+}
+
+// SetOverflowPolicy sets what happens to consumers that fall behind. It only
+// applies to consumers that subscribe after it's called
+func (p *Pipeline) SetOverflowPolicy(policy OverflowPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
+// SetQueueSize sets how many frames can be waiting for a consumer before the
+// overflow policy kicks in. It only applies to consumers that subscribe after
+// it's called
+func (p *Pipeline) SetQueueSize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queueSize = size
+}
+
 // Closes the pipeline and stops broadcasting
 func (p *Pipeline) Close() {
 	p.mu.Lock()
 	// signal the broadcasting goroutine to stop
 	close(p.stopChan)
-	p.logFile.Close()
+	if p.flushTimer != nil {
+		p.flushTimer.Stop()
+	}
+	if p.batchTimer != nil {
+		p.batchTimer.Stop()
+	}
+	if p.recorder != nil {
+		p.recorder.close()
+		p.recorder = nil
+	}
 
-	for k := range p.consumers {
+	for k, c := range p.consumers {
+		c.stop()
 		delete(p.consumers, k)
 		k.Close()
 	}
 	p.mu.Unlock()
 
-	p.pty.Close()
+	if p.src != nil {
+		p.src.Close()
+	}
 }
 
 // Writes whatever is passed to the PTY
 // Used to pass commands to the PTY
 func (p *Pipeline) WriteTo(stuff []byte) {
-	if len(stuff) == 0 || stuff == nil {
+	if len(stuff) == 0 || stuff == nil || p.src == nil {
 		return
 	}
-	p.pty.Write(stuff)
+	p.src.Write(stuff)
+
+	p.mu.Lock()
+	p.recordLocked(func(r *recorder) error { return r.keys(stuff) })
+	p.mu.Unlock()
 }
 
 // reads whatever is in the pty and returns it
 func (p *Pipeline) ReadFrom() []byte {
+	if p.src == nil {
+		return nil
+	}
+
 	buf := make([]byte, 10240)
-	n, err := p.pty.Read(buf)
+	n, err := p.src.Read(buf)
 	if err != nil {
 		return nil
 	}
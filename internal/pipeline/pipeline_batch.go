@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"time"
+)
+
+const (
+	// DefaultBatchWindow is how long output is held back to go out with
+	// whatever comes right after it
+	DefaultBatchWindow = time.Millisecond * 16
+	// DefaultBatchSize is how much output is held back at most before it
+	// goes out regardless of the window
+	DefaultBatchSize = 32 * 1024
+	// DefaultFrameRate is how many terminal frames a second a consumer gets
+	DefaultFrameRate = 30
+)
+
+// SetBatching makes output that comes within window of the last frame wait for
+// the rest of the window, or until there's maxBytes of it, to go out as a single
+// frame. Output after a quiet spell goes out straight away so typing doesn't lag.
+// A window of 0 sends every read from the source as its own frame
+func (p *Pipeline) SetBatching(window time.Duration, maxBytes int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if window <= 0 {
+		p.flushBatchLocked()
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultBatchSize
+	}
+	p.batchWindow = window
+	p.batchSize = maxBytes
+}
+
+// SetFrameRate caps how many terminal frames a second each consumer is sent;
+// whatever comes in between is merged into the next one. 0 doesn't cap it. It
+// only applies to consumers that subscribe after it's called
+func (p *Pipeline) SetFrameRate(fps int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.frameInterval = 0
+	if fps > 0 {
+		p.frameInterval = time.Second / time.Duration(fps)
+	}
+}
+
+// batchLocked holds on to buf until the batch window is over or the batch is
+// full. must be called with p.mu held
+func (p *Pipeline) batchLocked(buf []byte) {
+	if p.batchWindow <= 0 {
+		p.emitLocked(buf)
+		return
+	}
+
+	p.batch = append(p.batch, buf...)
+	since := time.Since(p.lastFrame)
+	if len(p.batch) >= p.batchSize || (!p.batchPending && since >= p.batchWindow) {
+		p.flushBatchLocked()
+		return
+	}
+
+	if !p.batchPending {
+		p.batchPending = true
+		if p.batchTimer == nil {
+			p.batchTimer = time.AfterFunc(p.batchWindow-since, p.flushBatch)
+		} else {
+			p.batchTimer.Reset(p.batchWindow - since)
+		}
+	}
+}
+
+// flushBatch sends out the batch once its window is over
+func (p *Pipeline) flushBatch() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushBatchLocked()
+}
+
+// flushBatchLocked sends out whatever's in the batch. must be called with p.mu
+// held
+func (p *Pipeline) flushBatchLocked() {
+	if p.batchPending {
+		p.batchTimer.Stop()
+		p.batchPending = false
+	}
+	p.lastFrame = time.Now()
+	if len(p.batch) == 0 {
+		return
+	}
+
+	buf := p.batch
+	p.batch = nil
+	p.emitLocked(buf)
+}
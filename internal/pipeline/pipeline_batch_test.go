@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+)
+
+func TestBatching(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+	p.SetBatching(time.Millisecond*50, 16)
+	defer p.SetBatching(0, 0)
+
+	frameData := func(seq uint64) string {
+		t.Helper()
+		frames := p.ring.between(seq, seq)
+		if len(frames) != 1 {
+			t.Fatalf("expected frame %d to be out", seq)
+		}
+		payload, err := base.DecodePayload(frames[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(payload.GetTermContent().GetData())
+	}
+	seq := func() uint64 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.seq
+	}
+
+	// output after a quiet spell goes straight out
+	p.broadcast([]byte("a"))
+	if seq() != 1 || frameData(1) != "a" {
+		t.Fatalf("expected the first output to go out straight away got %d frames", seq())
+	}
+
+	// what follows right after waits for the rest of the window
+	p.broadcast([]byte("b"))
+	p.broadcast([]byte("c"))
+	if seq() != 1 {
+		t.Fatal("expected output inside the window to be held back")
+	}
+	time.Sleep(time.Millisecond * 150)
+	if seq() != 2 || frameData(2) != "bc" {
+		t.Fatalf("expected the held back output as one frame got %d frames", seq())
+	}
+
+	// a full batch doesn't wait for the window
+	p.broadcast([]byte("d"))
+	p.broadcast([]byte("e"))
+	p.broadcast([]byte(strings.Repeat("f", 16)))
+	if seq() != 4 || frameData(4) != "e"+strings.Repeat("f", 16) {
+		t.Fatalf("expected a full batch to go out straight away got %d frames", seq())
+	}
+}
@@ -0,0 +1,474 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/term"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+// OverflowPolicy decides what happens to a consumer whose queue is full
+// because it can't keep up with the pty
+type OverflowPolicy int
+
+const (
+	// drop the oldest queued frame to make room for the new one
+	DropOldest OverflowPolicy = iota + 1
+	// merge everything that's queued into a single frame
+	Coalesce
+	// give up on the consumer and close its connection
+	Disconnect
+	// swap everything that's queued for a snapshot of the screen
+	Snapshot
+)
+
+const (
+	defaultQueueSize     = 256
+	consumerWriteTimeout = time.Second * 10
+)
+
+func (o OverflowPolicy) String() string {
+	switch o {
+	case DropOldest:
+		return "drop"
+	case Coalesce:
+		return "coalesce"
+	case Disconnect:
+		return "disconnect"
+	case Snapshot:
+		return "snapshot"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseOverflowPolicy turns the name of a policy as the user would type it into
+// an OverflowPolicy
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch name {
+	case "drop", "drop-oldest":
+		return DropOldest, nil
+	case "coalesce":
+		return Coalesce, nil
+	case "disconnect":
+		return Disconnect, nil
+	case "snapshot":
+		return Snapshot, nil
+	}
+
+	return 0, fmt.Errorf("unknown overflow policy %q", name)
+}
+
+// ConsumerStats is a snapshot of how a consumer's queue is doing
+type ConsumerStats struct {
+	Queued    int
+	Dropped   uint64
+	Coalesced uint64
+	Snapshots uint64
+}
+
+// consumer owns the queue of frames waiting to be written to a single
+// connection. every consumer has its own writer goroutine so that a slow
+// connection only ever holds itself up
+type consumer struct {
+	conn      net.Conn
+	policy    OverflowPolicy
+	limit     int
+	queue     []frame
+	dropped   uint64
+	coalesced uint64
+	closed    bool
+	mu        sync.Mutex
+	notify    chan struct{}
+	done      chan struct{}
+	// set while the writer goroutine has frames it took off the queue
+	writing bool
+	// set when the connection takes its terminal frames deflated. only the
+	// writer goroutine touches it so frames are deflated in the order they go out
+	deflater *utils.Deflater
+	// the least time between terminal frames, how to get a snapshot of the
+	// screen when the consumer falls behind and how often it had to
+	interval  time.Duration
+	keyframe  func() (frame, error)
+	snapshots uint64
+	// set when the consumer takes diffs of the screen instead of terminal
+	// frames; dirty is set when the screen may have changed since the last one
+	differ *screenDiffer
+	diff   func() ([]byte, error)
+	dirty  bool
+}
+
+func newConsumer(conn net.Conn, policy OverflowPolicy, limit int) *consumer {
+	if limit <= 0 {
+		limit = defaultQueueSize
+	}
+
+	return &consumer{
+		conn:   conn,
+		policy: policy,
+		limit:  limit,
+		queue:  make([]frame, 0, limit),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// enqueue adds the frame to the consumer's queue applying the overflow policy
+// if the queue is full. it returns false if the consumer should be disconnected
+func (c *consumer) enqueue(f frame) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return true
+	}
+
+	if c.differ != nil && f.content != nil {
+		// the next diff takes care of it. keyframes are sent when the client's
+		// screen can't be trusted so the diff redraws everything
+		if f.content.GetKeyframe() {
+			c.differ.reset()
+		}
+		c.dirty = true
+		c.signal()
+		return true
+	}
+
+	if len(c.queue) >= c.limit {
+		switch c.policy {
+		case Disconnect:
+			return false
+		case Coalesce:
+			if c.coalesceLocked(f) {
+				c.signal()
+				return true
+			}
+			// nothing to merge with so fall back to dropping
+			c.dropOldestLocked()
+		case Snapshot:
+			if c.snapshotLocked(f) {
+				c.signal()
+				return true
+			}
+			c.dropOldestLocked()
+		default:
+			c.dropOldestLocked()
+		}
+	}
+
+	c.queue = append(c.queue, f)
+	c.signal()
+	return true
+}
+
+// dropOldestLocked drops the oldest terminal frame in the queue. control frames
+// such as resend errors are left alone since there's only ever a handful of them
+func (c *consumer) dropOldestLocked() {
+	for i, f := range c.queue {
+		if f.data == nil {
+			continue
+		}
+
+		c.queue = append(c.queue[:i], c.queue[i+1:]...)
+		c.dropped += 1
+		return
+	}
+
+	// the queue is all control frames so the oldest one has to go
+	c.queue = c.queue[1:]
+	c.dropped += 1
+}
+
+// coalesceLocked merges every queued terminal frame and the new one into a
+// single frame that covers all their sequence numbers
+func (c *consumer) coalesceLocked(f frame) bool {
+	if f.data == nil {
+		return false
+	}
+
+	merged := make([]byte, 0)
+	kept := make([]frame, 0, len(c.queue))
+	first := f.firstSeq
+	count := 0
+	for _, queued := range c.queue {
+		if queued.data == nil {
+			kept = append(kept, queued)
+			continue
+		}
+
+		if count == 0 {
+			first = queued.firstSeq
+		}
+		merged = append(merged, queued.data...)
+		count += 1
+	}
+
+	if count == 0 {
+		return false
+	}
+	merged = append(merged, f.data...)
+
+	mergedFrame, err := encodeFrame(f.window, first, f.seq, merged)
+	if err != nil {
+		log.Println("failed to coalesce frames:", err)
+		return false
+	}
+
+	c.queue = append(kept, mergedFrame)
+	c.coalesced += uint64(count)
+	return true
+}
+
+// snapshotLocked replaces every queued terminal frame and the new one with a
+// keyframe of the screen. the screen already has the new frame on it so the
+// keyframe covers everything the consumer was waiting for
+func (c *consumer) snapshotLocked(f frame) bool {
+	if f.data == nil || c.keyframe == nil {
+		return false
+	}
+
+	kf, err := c.keyframe()
+	if err != nil {
+		log.Println("failed to snapshot the screen:", err)
+		return false
+	}
+
+	kept := make([]frame, 0, len(c.queue))
+	for _, queued := range c.queue {
+		// older keyframes are no use once there's a newer one
+		if queued.content == nil {
+			kept = append(kept, queued)
+		}
+	}
+
+	c.queue = append(kept, kf)
+	c.snapshots += 1
+	return true
+}
+
+func (c *consumer) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain takes everything that's currently queued merging terminal frames that
+// follow on from each other when merge is set
+func (c *consumer) drain(merge bool) []frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	frames := c.queue
+	c.queue = make([]frame, 0, c.limit)
+	c.writing = len(frames) > 0
+	if !merge {
+		return frames
+	}
+
+	merged := make([]frame, 0, len(frames))
+	for _, f := range frames {
+		if n := len(merged); n > 0 && f.data != nil {
+			last := merged[n-1]
+			if last.data != nil && last.window == f.window && last.seq+1 == f.firstSeq {
+				data := append(append(make([]byte, 0, len(last.data)+len(f.data)), last.data...), f.data...)
+				mf, err := encodeFrame(f.window, last.firstSeq, f.seq, data)
+				if err == nil {
+					merged[n-1] = mf
+					c.coalesced += 1
+					continue
+				}
+				log.Println("failed to merge frames:", err)
+			}
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+func (c *consumer) stats() ConsumerStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ConsumerStats{
+		Queued:    len(c.queue),
+		Dropped:   c.dropped,
+		Coalesced: c.coalesced,
+		Snapshots: c.snapshots,
+	}
+}
+
+// takeDirty reports whether the consumer needs a new screen diff
+func (c *consumer) takeDirty() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dirty := c.dirty
+	c.dirty = false
+	return dirty
+}
+
+// written reports whether everything queued for the consumer has been written
+// to its connection or there's nothing left that will write it
+func (c *consumer) written() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed || len(c.queue) == 0 && !c.writing && !c.dirty
+}
+
+// doneWriting notes that the writer goroutine wrote everything it took
+func (c *consumer) doneWriting() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writing = false
+}
+
+// stop ends the writer goroutine without touching the connection
+func (c *consumer) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+}
+
+// writeLoop writes everything queued for the consumer to its connection until
+// the consumer is stopped or a write fails in which case onErr is called. the
+// consumer is never sent terminal frames closer together than its interval;
+// whatever comes in while it waits is merged into the next one
+func (c *consumer) writeLoop(onErr func(error)) {
+	var next time.Time
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.notify:
+		}
+
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-c.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		for _, f := range c.drain(c.interval > 0) {
+			if f.content != nil && c.interval > 0 {
+				next = time.Now().Add(c.interval)
+			}
+
+			payload, err := c.wirePayload(f)
+			if err != nil {
+				onErr(err)
+				return
+			}
+			if !c.write(payload, onErr) {
+				return
+			}
+		}
+		c.doneWriting()
+
+		if c.differ != nil && c.takeDirty() {
+			next = time.Now().Add(max(c.interval, minDiffInterval))
+			payload, err := c.diff()
+			if err != nil {
+				onErr(err)
+				return
+			}
+			if payload != nil && !c.write(payload, onErr) {
+				return
+			}
+		}
+	}
+}
+
+// write writes payload to the consumer's connection. it returns false if the
+// writer goroutine should stop
+func (c *consumer) write(payload []byte, onErr func(error)) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), consumerWriteTimeout)
+	err := utils.WriteFull(ctx, c.conn, nil, payload)
+	cancel()
+	if err != nil {
+		onErr(err)
+		return false
+	}
+
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// wirePayload returns what goes over the wire for f. terminal frames are
+// deflated for consumers that take them that way; a keyframe starts the
+// dictionary over so a client that lost track of it can get back in step
+// by asking for one
+func (c *consumer) wirePayload(f frame) ([]byte, error) {
+	if c.deflater == nil || f.content == nil {
+		return f.payload, nil
+	}
+
+	if f.content.GetKeyframe() {
+		c.deflater.Reset()
+	}
+	data, reset, err := c.deflater.Deflate(f.content.GetData())
+	if err != nil {
+		return nil, err
+	}
+
+	// the frame is shared with every other consumer so it's copied rather
+	// than changed
+	termPayload := base.Payload_TermContent{
+		TermContent: &term.TerminalContent{
+			MessageId:        f.content.GetMessageId(),
+			MessageLength:    f.content.GetMessageLength(),
+			Data:             data,
+			Crc32:            f.content.GetCrc32(),
+			Sequence:         f.content.GetSequence(),
+			FirstSequence:    f.content.GetFirstSequence(),
+			Keyframe:         f.content.GetKeyframe(),
+			Window:           f.content.GetWindow(),
+			Compression:      term.Compression_COMPRESSION_DEFLATE,
+			CompressionReset: reset,
+		},
+	}
+	return base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
+}
+
+// encodeFrame builds a terminal frame for window covering the sequence numbers
+// first to last
+func encodeFrame(window uint32, first, last uint64, data []byte) (frame, error) {
+	termPayload := base.GenerateTermContent("", last, data)
+	termPayload.TermContent.Window = window
+	if first != last {
+		termPayload.TermContent.FirstSequence = first
+	}
+
+	payload, err := base.EncodePayload(common.Header_HEADER_TERMINAL_DATA, &termPayload)
+	if err != nil {
+		return frame{}, err
+	}
+
+	return frame{
+		window:   window,
+		seq:      last,
+		firstSeq: first,
+		data:     data,
+		payload:  payload,
+		content:  termPayload.TermContent,
+	}, nil
+}
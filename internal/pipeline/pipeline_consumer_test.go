@@ -0,0 +1,342 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/term"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+func testFrame(t *testing.T, seq uint64, data string) frame {
+	t.Helper()
+	f, err := encodeFrame(0, seq, seq, []byte(data))
+	if err != nil {
+		t.Fatalf("failed to encode frame: %v", err)
+	}
+	return f
+}
+
+func TestConsumerDropOldest(t *testing.T) {
+	c := newConsumer(nil, DropOldest, 2)
+	for seq := uint64(1); seq <= 4; seq++ {
+		if !c.enqueue(testFrame(t, seq, "x")) {
+			t.Fatal("drop oldest should never disconnect")
+		}
+	}
+
+	stats := c.stats()
+	if stats.Queued != 2 || stats.Dropped != 2 {
+		t.Fatalf("expected 2 queued and 2 dropped got %+v", stats)
+	}
+	if c.queue[0].seq != 3 {
+		t.Fatalf("expected the oldest frames to be dropped got %d first", c.queue[0].seq)
+	}
+}
+
+func TestConsumerCoalesce(t *testing.T) {
+	c := newConsumer(nil, Coalesce, 2)
+	c.enqueue(testFrame(t, 1, "a"))
+	c.enqueue(testFrame(t, 2, "b"))
+	c.enqueue(testFrame(t, 3, "c"))
+
+	if len(c.queue) != 1 {
+		t.Fatalf("expected a single merged frame got %d", len(c.queue))
+	}
+
+	payload, err := base.DecodePayload(c.queue[0].payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := payload.GetTermContent()
+	if string(content.GetData()) != "abc" {
+		t.Fatalf("expected merged data abc got %q", content.GetData())
+	}
+	if content.GetFirstSequence() != 1 || content.GetSequence() != 3 {
+		t.Fatalf("expected frame to cover 1-3 got %d-%d",
+			content.GetFirstSequence(), content.GetSequence())
+	}
+	if c.stats().Coalesced != 2 {
+		t.Fatalf("expected 2 coalesced frames got %d", c.stats().Coalesced)
+	}
+}
+
+func TestConsumerDisconnect(t *testing.T) {
+	c := newConsumer(nil, Disconnect, 1)
+	if !c.enqueue(testFrame(t, 1, "a")) {
+		t.Fatal("first frame should fit in the queue")
+	}
+	if c.enqueue(testFrame(t, 2, "b")) {
+		t.Fatal("expected a full queue to ask for a disconnect")
+	}
+}
+
+func TestConsumerSnapshot(t *testing.T) {
+	p := &Pipeline{screen: newVTerm(defaultRows, defaultCols)}
+	c := newConsumer(nil, Snapshot, 2)
+	c.keyframe = p.keyframeLocked
+
+	c.enqueue(testFrame(t, 1, "a"))
+	c.enqueue(frame{payload: []byte("control")})
+	p.screen.Write([]byte("abc"))
+	p.seq = 3
+	c.enqueue(testFrame(t, 3, "c"))
+
+	if len(c.queue) != 2 || c.queue[0].content != nil {
+		t.Fatalf("expected the control frame and a keyframe got %d frames", len(c.queue))
+	}
+	kf := c.queue[1].content
+	if !kf.GetKeyframe() || kf.GetSequence() != 3 {
+		t.Fatalf("expected a keyframe covering frame 3 got %v", kf)
+	}
+	if !strings.Contains(string(kf.GetData()), "abc") {
+		t.Fatalf("keyframe is missing what was on screen: %q", kf.GetData())
+	}
+	if stats := c.stats(); stats.Snapshots != 1 || stats.Dropped != 0 {
+		t.Fatalf("expected one snapshot and nothing dropped got %+v", stats)
+	}
+}
+
+func TestConsumerDrainMerge(t *testing.T) {
+	c := newConsumer(nil, DropOldest, 8)
+	c.enqueue(testFrame(t, 1, "a"))
+	c.enqueue(testFrame(t, 2, "b"))
+	c.enqueue(frame{payload: []byte("control")})
+	c.enqueue(testFrame(t, 3, "c"))
+	c.enqueue(testFrame(t, 4, "d"))
+	// a resend of an older frame doesn't follow on so it stays on its own
+	c.enqueue(testFrame(t, 2, "b"))
+
+	frames := c.drain(true)
+	want := []string{"ab", "", "cd", "b"}
+	if len(frames) != len(want) {
+		t.Fatalf("expected %d frames got %d", len(want), len(frames))
+	}
+	for i, f := range frames {
+		if string(f.data) != want[i] {
+			t.Fatalf("frame %d: expected %q got %q", i, want[i], f.data)
+		}
+	}
+	if frames[0].firstSeq != 1 || frames[0].seq != 2 {
+		t.Fatalf("expected the merged frame to cover 1-2 got %d-%d", frames[0].firstSeq, frames[0].seq)
+	}
+}
+
+func TestConsumerFrameRate(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	c := newConsumer(server, DropOldest, 8)
+	c.interval = time.Millisecond * 200
+	go c.writeLoop(func(err error) {})
+	defer c.stop()
+
+	read := func() *term.TerminalContent {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload.GetTermContent()
+	}
+
+	c.enqueue(testFrame(t, 1, "a"))
+	if got := read(); string(got.GetData()) != "a" {
+		t.Fatalf("expected the first frame straight away got %q", got.GetData())
+	}
+
+	start := time.Now()
+	c.enqueue(testFrame(t, 2, "b"))
+	c.enqueue(testFrame(t, 3, "c"))
+	got := read()
+	if string(got.GetData()) != "bc" || got.GetFirstSequence() != 2 || got.GetSequence() != 3 {
+		t.Fatalf("expected frames 2-3 merged got %d-%d %q",
+			got.GetFirstSequence(), got.GetSequence(), got.GetData())
+	}
+	if time.Since(start) < time.Millisecond*100 {
+		t.Fatal("expected the second frame to wait for the interval")
+	}
+}
+
+func TestConsumerWriteLoop(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	c := newConsumer(server, DropOldest, 8)
+	go c.writeLoop(func(err error) {})
+	defer c.stop()
+
+	c.enqueue(testFrame(t, 1, "hello"))
+
+	data, err := utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload.GetTermContent().GetData()) != "hello" {
+		t.Fatalf("unexpected frame data %q", payload.GetTermContent().GetData())
+	}
+}
+
+func TestConsumerCompressed(t *testing.T) {
+	c := newConsumer(nil, DropOldest, 8)
+	c.deflater = utils.NewDeflater()
+	inflater := utils.NewInflater()
+
+	screen := newVTerm(24, 80)
+	screen.Write([]byte("$ echo hello\r\nhello\r\n$ "))
+	keyframe := func() frame {
+		p := &Pipeline{screen: screen, seq: 3}
+		f, err := p.keyframeLocked()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	frames := []frame{
+		testFrame(t, 1, "$ echo hello\r\n"),
+		testFrame(t, 2, "hello\r\n$ echo hello\r\n"),
+		keyframe(),
+		testFrame(t, 4, "hello\r\n"),
+	}
+	resets := []bool{true, false, true, false}
+
+	for n, f := range frames {
+		wire, err := c.wirePayload(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(wire)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := payload.GetTermContent()
+		if content.GetCompression() != term.Compression_COMPRESSION_DEFLATE {
+			t.Fatalf("frame %d: expected a deflated frame", n)
+		}
+		if content.GetCompressionReset() != resets[n] {
+			t.Fatalf("frame %d: expected reset to be %v", n, resets[n])
+		}
+		if content.GetCompressionReset() {
+			inflater.Reset()
+		}
+
+		data, err := inflater.Inflate(content.GetData(), int(content.GetMessageLength()))
+		if err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+		if string(data) != string(f.content.GetData()) {
+			t.Fatalf("frame %d: expected %q got %q", n, f.content.GetData(), data)
+		}
+		if crc32.ChecksumIEEE(data) != content.GetCrc32() {
+			t.Fatalf("frame %d: expected the crc of the inflated data", n)
+		}
+		if content.GetKeyframe() != f.content.GetKeyframe() || content.GetSequence() != f.seq {
+			t.Fatalf("frame %d: lost its sequence or keyframe flag", n)
+		}
+	}
+
+	// the frames themselves are shared with other consumers
+	if frames[0].content.GetCompression() != term.Compression_COMPRESSION_NONE {
+		t.Fatal("expected the shared frame to be left alone")
+	}
+}
+
+// compressionWorkloads is terminal output the way it comes off a pty, one
+// chunk per frame
+func compressionWorkloads() map[string][]string {
+	shell := make([]string, 0)
+	for i := 0; i < 200; i++ {
+		shell = append(shell, "\x1b[1;32mstudent@lab\x1b[0m:\x1b[1;34m~/project\x1b[0m$ ")
+		for _, c := range "ls -l" {
+			shell = append(shell, string(c))
+		}
+		listing := ""
+		for j := 0; j < 8; j++ {
+			listing += fmt.Sprintf("-rw-r--r-- 1 student student %5d Oct 18 12:%02d file%03d.go\r\n",
+				(i*31+j*17)%20000, (i+j)%60, j)
+		}
+		shell = append(shell, "\r\n"+listing)
+	}
+
+	build := make([]string, 0)
+	for i := 0; i < 500; i++ {
+		build = append(build, fmt.Sprintf("ok  \twilldaedalus/superluminal/internal/pkg%03d\t0.%03ds\r\n",
+			i%40, (i*7)%1000))
+	}
+
+	top := make([]string, 0)
+	for i := 0; i < 100; i++ {
+		var screen strings.Builder
+		screen.WriteString("\x1b[H\x1b[7m  PID USER      PR  NI    VIRT    RES  %CPU  %MEM COMMAND\x1b[K\x1b[0m\r\n")
+		for j := 0; j < 22; j++ {
+			fmt.Fprintf(&screen, "%5d student   20   0 %7d %6d \x1b[1m%5.1f\x1b[0m %5.1f proc%02d\x1b[K\r\n",
+				1000+j, 100000+(i*j)%5000, 20000+(i+j)%300, float64((i*j)%100)/10, float64(j)/10, j)
+		}
+		top = append(top, screen.String())
+	}
+
+	typing := make([]string, 0)
+	for i := 0; i < 50; i++ {
+		for _, c := range "the quick brown fox jumps over the lazy dog" {
+			typing = append(typing, string(c))
+		}
+		typing = append(typing, "\r\n")
+	}
+
+	return map[string][]string{
+		"shell":  shell,
+		"build":  build,
+		"top":    top,
+		"typing": typing,
+	}
+}
+
+// BenchmarkCompression compares what goes over the wire for a connection with
+// and without compression
+func BenchmarkCompression(b *testing.B) {
+	for name, chunks := range compressionWorkloads() {
+		frames := make([]frame, 0, len(chunks))
+		for i, chunk := range chunks {
+			f, err := encodeFrame(0, uint64(i+1), uint64(i+1), []byte(chunk))
+			if err != nil {
+				b.Fatal(err)
+			}
+			frames = append(frames, f)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			var raw, wire int
+			for i := 0; i < b.N; i++ {
+				c := newConsumer(nil, DropOldest, 0)
+				c.deflater = utils.NewDeflater()
+				raw, wire = 0, 0
+				for _, f := range frames {
+					payload, err := c.wirePayload(f)
+					if err != nil {
+						b.Fatal(err)
+					}
+					raw += len(f.payload)
+					wire += len(payload)
+				}
+			}
+			b.ReportMetric(float64(raw), "raw-bytes")
+			b.ReportMetric(float64(wire), "wire-bytes")
+			b.ReportMetric(float64(wire)/float64(raw), "ratio")
+		})
+	}
+}
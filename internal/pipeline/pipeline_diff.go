@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"slices"
+	"time"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/screen"
+)
+
+// consumers taking screen diffs never get them closer together than this. a
+// diff only ever has the latest screen so sending fewer of them loses nothing
+const minDiffInterval = time.Millisecond * 100
+
+// screenDiffer keeps track of what's on the screen of a consumer that takes
+// screen diffs so it only has to be sent what changed
+type screenDiffer struct {
+	lines         [][]cell
+	rows, cols    int
+	cursorRow     int
+	cursorCol     int
+	cursorVisible bool
+}
+
+// reset forgets what the consumer has so the next diff redraws everything
+func (d *screenDiffer) reset() {
+	d.lines = nil
+}
+
+// diff returns what changed on v's screen since the last diff and remembers
+// the screen as it is now. it returns nil if nothing did
+func (d *screenDiffer) diff(v *vterm) *screen.ScreenDiff {
+	lines := v.screen.lines
+	full := d.lines == nil || d.rows != v.rows || d.cols != v.cols
+
+	sd := &screen.ScreenDiff{
+		Rows:          uint32(v.rows),
+		Cols:          uint32(v.cols),
+		Full:          full,
+		CursorRow:     uint32(v.cur.row),
+		CursorCol:     uint32(v.cur.col),
+		CursorVisible: v.cursorVisible,
+	}
+
+	old := d.lines
+	if full {
+		old = make([][]cell, v.rows)
+		for i := range old {
+			old[i] = newLine(v.cols, cellAttr{})
+		}
+	} else if scroll := scrollOffset(old, lines); scroll > 0 {
+		// the screen scrolled so the client can shift what it has instead of
+		// being sent every line again
+		sd.Scroll = uint32(scroll)
+		old = append(old[scroll:len(old):len(old)], make([][]cell, scroll)...)
+		for i := len(old) - scroll; i < len(old); i++ {
+			old[i] = newLine(v.cols, cellAttr{})
+		}
+	}
+
+	for row, line := range lines {
+		sd.Runs = append(sd.Runs, diffLine(row, old[row], line)...)
+	}
+
+	changed := full || sd.Scroll > 0 || len(sd.Runs) > 0 ||
+		sd.CursorRow != uint32(d.cursorRow) || sd.CursorCol != uint32(d.cursorCol) ||
+		sd.CursorVisible != d.cursorVisible
+
+	d.lines = make([][]cell, len(lines))
+	for i, line := range lines {
+		d.lines[i] = slices.Clone(line)
+	}
+	d.rows, d.cols = v.rows, v.cols
+	d.cursorRow, d.cursorCol = v.cur.row, v.cur.col
+	d.cursorVisible = v.cursorVisible
+
+	if !changed {
+		return nil
+	}
+	return sd
+}
+
+// scrollOffset returns how many lines the screen went from old to lines
+// scrolling up by if that explains more of it than not scrolling at all
+func scrollOffset(old, lines [][]cell) int {
+	matches := func(n int) int {
+		count := 0
+		for i := 0; i+n < len(old); i++ {
+			if lineEnd(lines[i]) >= 0 && slices.Equal(lines[i], old[i+n]) {
+				count += 1
+			}
+		}
+		return count
+	}
+
+	best, bestMatches := 0, matches(0)
+	for n := 1; n < len(old); n++ {
+		if m := matches(n); m > bestMatches {
+			best, bestMatches = n, m
+		}
+	}
+	return best
+}
+
+// diffLine returns the runs that turn old into line
+func diffLine(row int, old, line []cell) []*screen.Run {
+	first := 0
+	for first < len(line) && old[first] == line[first] {
+		first += 1
+	}
+	if first == len(line) {
+		return nil
+	}
+	last := len(line) - 1
+	for old[last] == line[last] {
+		last -= 1
+	}
+
+	// wide characters are drawn whole
+	if first > 0 && line[first].width == 0 {
+		first -= 1
+	}
+	if last+1 < len(line) && line[last+1].width == 0 {
+		last += 1
+	}
+
+	runs := make([]*screen.Run, 0)
+	end := min(last, lineEnd(line))
+	var run *screen.Run
+	var runAttr cellAttr
+	for col := first; col <= end; col++ {
+		c := line[col]
+		if c.width == 0 {
+			continue
+		}
+		if run == nil || c.attr != runAttr {
+			run = &screen.Run{Row: uint32(row), Col: uint32(col)}
+			run.Flags, run.Fg, run.Bg = packAttr(c.attr)
+			runAttr = c.attr
+			runs = append(runs, run)
+		}
+		if c.ch == "" {
+			run.Text += " "
+		} else {
+			run.Text += c.ch
+		}
+	}
+
+	if end < last {
+		// everything from here on is blank so it's cleared rather than drawn
+		if run == nil || runAttr != (cellAttr{}) {
+			run = &screen.Run{Row: uint32(row), Col: uint32(max(end+1, first))}
+			runs = append(runs, run)
+		}
+		run.Erase = true
+	}
+
+	return runs
+}
+
+// packAttr turns attributes into how they're sent in a run
+func packAttr(attr cellAttr) (uint32, uint32, uint32) {
+	return uint32(attr.flags), packColor(attr.fg), packColor(attr.bg)
+}
+
+func packColor(c color) uint32 {
+	switch c.mode {
+	case colorIndexed:
+		return c.value + 1
+	case colorRGB:
+		return 1<<24 | c.value&0xffffff
+	}
+	return 0
+}
+
+// screenDiffLocked returns the next diff for d as a payload ready to go out or
+// nil if there's nothing to send. must be called with p.mu held
+func (p *Pipeline) screenDiffLocked(d *screenDiffer) ([]byte, error) {
+	if p.paused || p.screen == nil {
+		return nil, nil
+	}
+
+	sd := d.diff(p.screen)
+	if sd == nil {
+		return nil, nil
+	}
+	sd.Window = p.window
+	return base.EncodePayload(common.Header_HEADER_SCREEN_DIFF, &base.Payload_ScreenDiff{ScreenDiff: sd})
+}
@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/screen"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+func TestScreenDiff(t *testing.T) {
+	v := newVTerm(4, 20)
+	d := &screenDiffer{}
+
+	v.Write([]byte("hello\r\n\x1b[1;31mred\x1b[0m text"))
+	sd := d.diff(v)
+	if sd == nil || !sd.GetFull() {
+		t.Fatal("expected the first diff to be full")
+	}
+	want := []struct {
+		row, col uint32
+		text     string
+		fg       uint32
+	}{
+		{0, 0, "hello", 0},
+		{1, 0, "red", 2},
+		{1, 3, " text", 0},
+	}
+	if len(sd.GetRuns()) != len(want) {
+		t.Fatalf("expected %d runs got %v", len(want), sd.GetRuns())
+	}
+	for i, w := range want {
+		run := sd.GetRuns()[i]
+		if run.GetRow() != w.row || run.GetCol() != w.col || run.GetText() != w.text || run.GetFg() != w.fg {
+			t.Fatalf("run %d: expected %+v got %v", i, w, run)
+		}
+	}
+	if sd.GetRuns()[1].GetFlags() != uint32(attrBold) {
+		t.Fatalf("expected the red run to be bold got %v", sd.GetRuns()[1])
+	}
+	if sd.GetCursorRow() != 1 || sd.GetCursorCol() != 8 {
+		t.Fatalf("expected the cursor at 1,8 got %d,%d", sd.GetCursorRow(), sd.GetCursorCol())
+	}
+
+	if sd := d.diff(v); sd != nil {
+		t.Fatalf("expected no diff when nothing changed got %v", sd)
+	}
+
+	// only the changed cells are sent
+	v.Write([]byte("\x1b[1;2Ha"))
+	sd = d.diff(v)
+	if len(sd.GetRuns()) != 1 || sd.GetRuns()[0].GetCol() != 1 || sd.GetRuns()[0].GetText() != "a" {
+		t.Fatalf("expected a single run for the changed cell got %v", sd.GetRuns())
+	}
+
+	// a line being cleared is an erase rather than a row of spaces
+	v.Write([]byte("\x1b[2;1H\x1b[2K"))
+	sd = d.diff(v)
+	if len(sd.GetRuns()) != 1 || !sd.GetRuns()[0].GetErase() || sd.GetRuns()[0].GetText() != "" {
+		t.Fatalf("expected the cleared line to be erased got %v", sd.GetRuns())
+	}
+}
+
+func TestScreenDiffScroll(t *testing.T) {
+	v := newVTerm(5, 20)
+	d := &screenDiffer{}
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(v, "\r\nline %d", i)
+	}
+	d.diff(v)
+
+	v.Write([]byte("\r\nline 5\r\nline 6"))
+	sd := d.diff(v)
+	if sd.GetScroll() != 2 {
+		t.Fatalf("expected the screen to scroll by 2 got %d", sd.GetScroll())
+	}
+	if len(sd.GetRuns()) != 2 || sd.GetRuns()[0].GetText() != "line 5" || sd.GetRuns()[1].GetText() != "line 6" {
+		t.Fatalf("expected just the new lines got %v", sd.GetRuns())
+	}
+}
+
+func TestSubscribeScreenDiffs(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+	p.broadcast([]byte("already on screen"))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	p.SubscribeWith(server, ConsumerOptions{ScreenDiffs: true})
+	defer p.Unsubscribe(server)
+
+	read := func() *base.Payload {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+	runText := func(sd *screen.ScreenDiff) string {
+		var text strings.Builder
+		for _, run := range sd.GetRuns() {
+			text.WriteString(run.GetText())
+		}
+		return text.String()
+	}
+
+	if read().GetResize() == nil {
+		t.Fatal("expected the terminal size first")
+	}
+	sd := read().GetScreenDiff()
+	if !sd.GetFull() || runText(sd) != "already on screen" {
+		t.Fatalf("expected a full diff of the screen got %v", sd)
+	}
+
+	p.broadcast([]byte(" and more"))
+	// the space was blank already so it isn't sent
+	sd = read().GetScreenDiff()
+	if sd.GetFull() || runText(sd) != "and more" {
+		t.Fatalf("expected a diff of just the new output got %v", sd)
+	}
+
+	// asking for a keyframe gets a full diff instead
+	if err := p.SendKeyframe(server); err != nil {
+		t.Fatal(err)
+	}
+	if sd = read().GetScreenDiff(); !sd.GetFull() {
+		t.Fatalf("expected a full diff after a keyframe was asked for got %v", sd)
+	}
+}
@@ -0,0 +1,190 @@
+package pipeline
+
+import (
+	"bytes"
+	"regexp"
+	"time"
+)
+
+const (
+	// how long held back output waits for the rest of it before it goes out anyway
+	filterFlushDelay = time.Millisecond * 30
+	// the most of an unfinished line a redactor holds back
+	maxHeldLine = 1024
+	// the most of an unfinished private key block a redactor holds back; past
+	// this it's treated like any other output
+	maxHeldKey = 64 * 1024
+
+	redactedText = "[REDACTED]"
+)
+
+// Filter rewrites the pty's output before it reaches consumers, recordings and
+// keyframes. The host's own screen always gets the output as is.
+//
+// Filter may hold on to the end of data when it could be the start of something
+// that's only complete once more output arrives. Flush returns whatever is held
+// and is called once the pty has been quiet for a little while
+type Filter interface {
+	Filter(data []byte) []byte
+	Flush() []byte
+}
+
+// DefaultSecretPatterns are what a redactor masks when it isn't given anything
+// else; aws keys, bearer tokens, password and token assignments and private keys.
+// only the first group is masked for patterns that have one
+var DefaultSecretPatterns = []string{
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`,
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	`(?i)\b[a-z_]*(?:password|passwd|secret|token|api_?key)["']?\s*[=:]\s*["']?([^\s"']+)`,
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+var privateKeyStart = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+var privateKeyEnd = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+
+// Redactor is a filter that masks secrets in the output. A secret split over
+// several reads from the pty is still caught since the unfinished line at the
+// end of the output is held back until the rest of it arrives
+type Redactor struct {
+	patterns []*regexp.Regexp
+	held     []byte
+}
+
+// NewRedactor creates a redactor that masks anything matching patterns or
+// DefaultSecretPatterns if there aren't any
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultSecretPatterns
+	}
+
+	r := &Redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+func (r *Redactor) Filter(data []byte) []byte {
+	buf := append(r.held, data...)
+	if len(buf) == 0 {
+		return nil
+	}
+	cut := r.holdFrom(buf)
+
+	// a secret that runs over the cut has to go out whole or not at all
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllIndex(buf, -1) {
+			if loc[0] < cut && loc[1] >= cut && cut < len(buf) {
+				cut = loc[0]
+			}
+		}
+	}
+
+	r.held = append([]byte(nil), buf[cut:]...)
+	return r.redact(buf[:cut])
+}
+
+func (r *Redactor) Flush() []byte {
+	out := r.redact(r.held)
+	r.held = nil
+	return out
+}
+
+// holdFrom returns where the part of buf that could still turn out to be a
+// secret starts
+func (r *Redactor) holdFrom(buf []byte) int {
+	// a private key spans lines so everything from the start of one that
+	// hasn't ended yet is held
+	if locs := privateKeyStart.FindAllIndex(buf, -1); len(locs) > 0 {
+		start := locs[len(locs)-1][0]
+		if !privateKeyEnd.Match(buf[start:]) && len(buf)-start <= maxHeldKey {
+			return start
+		}
+	}
+
+	start := bytes.LastIndexByte(buf, '\n') + 1
+	if len(buf)-start > maxHeldLine {
+		return len(buf) - maxHeldLine
+	}
+	return start
+}
+
+// redact masks everything in data that matches one of the patterns
+func (r *Redactor) redact(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	out := data
+	for _, re := range r.patterns {
+		locs := re.FindAllSubmatchIndex(out, -1)
+		if len(locs) == 0 {
+			continue
+		}
+
+		masked := make([]byte, 0, len(out))
+		last := 0
+		for _, loc := range locs {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			masked = append(masked, out[last:start]...)
+			masked = append(masked, redactedText...)
+			last = end
+		}
+		out = append(masked, out[last:]...)
+	}
+
+	return out
+}
+
+// SetFilter makes the pipeline pass the pty's output through f before it goes to
+// consumers. nil turns filtering off
+func (p *Pipeline) SetFilter(f Filter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.filter != nil {
+		// whatever the old filter was holding on to still has to go out
+		if held := p.filter.Flush(); len(held) > 0 {
+			p.broadcastLocked(held)
+		}
+	}
+	p.filter = f
+}
+
+// filterLocked runs buf through the filter and makes sure anything it holds on
+// to goes out if the pty goes quiet. must be called with p.mu held
+func (p *Pipeline) filterLocked(buf []byte) []byte {
+	if p.filter == nil {
+		return buf
+	}
+
+	if p.flushTimer == nil {
+		p.flushTimer = time.AfterFunc(filterFlushDelay, p.flushFilter)
+	} else {
+		p.flushTimer.Reset(filterFlushDelay)
+	}
+
+	return p.filter.Filter(buf)
+}
+
+// flushFilter sends out whatever the filter held back waiting for more output
+func (p *Pipeline) flushFilter() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.filter == nil {
+		return
+	}
+	if held := p.filter.Flush(); len(held) > 0 {
+		p.broadcastLocked(held)
+	}
+}
@@ -5,8 +5,25 @@ import (
 	"github.com/creack/pty"
 	"os"
 	"os/exec"
+	"strings"
 )
 
+// Command is the program a session shares and what it's run with. The zero
+// value runs the user's shell in the current directory with the host's
+// environment
+type Command struct {
+	// the program and its arguments; the user's shell if empty
+	Args []string
+	// where the program starts; the current directory if empty
+	Dir string
+	// KEY=VALUE pairs added to the host's environment
+	Env []string
+	// names of variables taken out of the host's environment
+	Unset []string
+	// the TERM the program sees; the host's if empty
+	Term string
+}
+
 // gets the user's default shell; if not successful, falls back to bash
 func getUserShell() string {
 	sh := os.Getenv("SHELL")
@@ -19,20 +36,55 @@ func getUserShell() string {
 	return sh
 }
 
-// creates and returns a new pty session that reads from os.Stdin
-func createSession() (*os.File, error) {
-	sh := exec.Command("/bin/bash")
-	// sh := exec.Command(getUserShell())
+// commandEnv builds the environment for cmd on top of base
+func commandEnv(base []string, cmd Command) []string {
+	drop := make(map[string]bool, len(cmd.Unset)+len(cmd.Env)+1)
+	for _, name := range cmd.Unset {
+		drop[name] = true
+	}
+
+	extra := append([]string(nil), cmd.Env...)
+	if cmd.Term != "" {
+		extra = append(extra, "TERM="+cmd.Term)
+	}
+	// whatever is set explicitly replaces what the host had
+	for _, kv := range extra {
+		name, _, _ := strings.Cut(kv, "=")
+		drop[name] = true
+	}
+
+	env := make([]string, 0, len(base)+len(extra))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if !drop[name] {
+			env = append(env, kv)
+		}
+	}
+
+	return append(env, extra...)
+}
+
+// creates and returns a new pty session that runs cmd
+func createSession(cmd Command) (*os.File, *exec.Cmd, error) {
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{getUserShell()}
+	}
+
+	sh := exec.Command(args[0], args[1:]...)
+	sh.Dir = cmd.Dir
+	sh.Env = commandEnv(os.Environ(), cmd)
 
 	ptmx, err := pty.Start(sh)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	// stdin isn't always a terminal (when running as a service for instance) so
+	// the pty keeps its default size until we're told otherwise
 	if err = pty.InheritSize(os.Stdin, ptmx); err != nil {
 		fmt.Println("couldn't resize pty:", err)
-		return nil, err
 	}
 
-	return ptmx, nil
+	return ptmx, sh, nil
 }
@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCommandEnv(t *testing.T) {
+	base := []string{"HOME=/home/host", "TERM=screen", "AWS_PROFILE=prod", "EDITOR=vi"}
+	cmd := Command{
+		Env:   []string{"EDITOR=nano", "DEBUG=1"},
+		Unset: []string{"AWS_PROFILE"},
+		Term:  "xterm-256color",
+	}
+
+	got := commandEnv(base, cmd)
+	want := []string{"HOME=/home/host", "EDITOR=nano", "DEBUG=1", "TERM=xterm-256color"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	if got := commandEnv(base, Command{}); !slices.Equal(got, base) {
+		t.Fatalf("expected the host's environment as is got %v", got)
+	}
+}
+
+func TestCommandPipelineExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewCommandPipeline(1, Command{
+		Args: []string{"/bin/sh", "-c", `[ "$PWD" = "$1" ] && [ "$GREETING" = hi ] && exit 3`, "sh", dir},
+		Dir:  dir,
+		Env:  []string{"GREETING=hi"},
+	})
+	if err != nil {
+		t.Skip("couldn't start a pty:", err)
+	}
+	defer p.Close()
+
+	done := make(chan struct{}, 1)
+	p.Start(done)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline didn't finish when the command exited")
+	}
+
+	status, ok := p.ExitStatus()
+	if !ok {
+		t.Fatal("expected the command to have exited")
+	}
+	if status != 3 {
+		t.Fatalf("expected exit status 3 got %d", status)
+	}
+}
@@ -0,0 +1,386 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+const (
+	// how often a file being mirrored is checked for more output
+	mirrorPollInterval = time.Millisecond * 50
+	// the longest line tmux's control mode is expected to send us
+	maxControlLine = 1024 * 1024
+)
+
+// tmuxSource mirrors a pane of a tmux session that's already running through a
+// control mode client. what's typed into the session is sent to the pane as keys
+type tmuxSource struct {
+	pane   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	r      *io.PipeReader
+	w      *io.PipeWriter
+	mu     sync.Mutex
+	rows   int
+	cols   int
+	resize func(rows, cols int)
+	// what to do with tmux's answer to each command we've sent in the order
+	// they were sent
+	replies []func(lines []string)
+	// the pane's output only counts once we've got a copy of its screen
+	attached bool
+	captured bool
+	capture  []string
+}
+
+// NewTmuxPipeline creates a pipeline that mirrors the tmux pane at target which
+// can be anything tmux takes for -t like "work", "work:2" or "%3". The pipeline
+// keeps the pane's size and the session ends when the pane or tmux does
+func NewTmuxPipeline(maxConns uint8, target string) (*Pipeline, error) {
+	info, err := tmuxQuery(target, "#{pane_id} #{pane_height} #{pane_width}")
+	if err != nil {
+		return nil, err
+	}
+
+	src := &tmuxSource{}
+	if _, err := fmt.Sscanf(info, "%s %d %d", &src.pane, &src.rows, &src.cols); err != nil {
+		return nil, fmt.Errorf("unexpected pane info from tmux %q: %w", info, err)
+	}
+
+	// ignore-size stops our client from squashing the window to its own size
+	src.cmd = exec.Command("tmux", "-C", "attach-session", "-f", "ignore-size", "-t", src.pane)
+	src.stdin, err = src.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := src.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := src.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("couldn't attach to tmux: %w", err)
+	}
+
+	p := NewSourcePipeline(maxConns, src, src.rows, src.cols)
+	p.fixedSize = true
+	src.resize = func(rows, cols int) {
+		if err := p.resize(rows, cols); err != nil {
+			log.Println("couldn't follow the pane's size:", err)
+		}
+	}
+
+	src.r, src.w = io.Pipe()
+	go func() {
+		src.w.CloseWithError(src.readControl(stdout))
+	}()
+
+	return p, nil
+}
+
+// NewMirrorPipeline creates a pipeline that mirrors the terminal output being
+// written to path by something else. See NewFileSource
+func NewMirrorPipeline(maxConns uint8, path string) (*Pipeline, error) {
+	src, err := NewFileSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// whatever is writing the file is most likely on the host's screen
+	rows, cols, err := pty.Getsize(os.Stdin)
+	if err != nil || rows == 0 || cols == 0 {
+		rows, cols = defaultRows, defaultCols
+	}
+
+	return NewSourcePipeline(maxConns, src, rows, cols), nil
+}
+
+// tmuxQuery asks tmux about target using a format like #{pane_id}
+func tmuxQuery(target, format string) (string, error) {
+	out, err := exec.Command("tmux", "display-message", "-p", "-t", target, format).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("tmux: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// readControl turns what the control client tells us into the pane's output
+// until tmux goes away
+func (s *tmuxSource) readControl(stdout io.Reader) error {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxControlLine)
+
+	var reply []string
+	inReply, ours := false, false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "%begin "):
+			// the flags are 1 for commands we sent as opposed to the attach
+			inReply, ours = true, strings.HasSuffix(line, " 1")
+			reply = nil
+		case strings.HasPrefix(line, "%end "), strings.HasPrefix(line, "%error "):
+			inReply = false
+			if !ours {
+				continue
+			}
+			handle := s.nextReply()
+			if strings.HasPrefix(line, "%error ") {
+				// everything we ask for names the pane so it failing means
+				// the pane is gone
+				return io.EOF
+			}
+			if handle != nil {
+				handle(reply)
+			}
+		case inReply:
+			reply = append(reply, line)
+		case strings.HasPrefix(line, "%output "):
+			pane, data, _ := strings.Cut(strings.TrimPrefix(line, "%output "), " ")
+			if pane != s.pane || !s.captured {
+				continue
+			}
+			if _, err := s.w.Write(unescapeControl(data)); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "%session-changed "):
+			if !s.attached {
+				s.attached = true
+				s.captureScreen()
+			}
+		case strings.HasPrefix(line, "%layout-change "), strings.HasPrefix(line, "%window-close "),
+			strings.HasPrefix(line, "%unlinked-window-close "):
+			s.command(fmt.Sprintf("display-message -p -t %s '#{pane_height} #{pane_width}'", s.pane), s.sizeChanged)
+		case strings.HasPrefix(line, "%exit"):
+			return io.EOF
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// captureScreen starts the mirror off with a copy of what's already in the pane.
+// tmux answers in order so any output that comes after the copy is new
+func (s *tmuxSource) captureScreen() {
+	s.command("capture-pane -p -e -t "+s.pane, func(lines []string) {
+		s.capture = lines
+	})
+	s.command(fmt.Sprintf("display-message -p -t %s '#{cursor_y} #{cursor_x}'", s.pane), func(lines []string) {
+		var cy, cx int
+		if len(lines) > 0 {
+			fmt.Sscanf(lines[0], "%d %d", &cy, &cx)
+		}
+
+		screen := []byte("\x1b[H\x1b[2J")
+		screen = append(screen, strings.Join(s.capture, "\r\n")...)
+		screen = fmt.Appendf(screen, "\x1b[%d;%dH", cy+1, cx+1)
+		s.w.Write(screen)
+		s.capture = nil
+		s.captured = true
+	})
+}
+
+func (s *tmuxSource) sizeChanged(lines []string) {
+	var rows, cols int
+	if len(lines) == 0 {
+		return
+	}
+	if n, _ := fmt.Sscanf(lines[0], "%d %d", &rows, &cols); n != 2 {
+		return
+	}
+
+	s.mu.Lock()
+	changed := rows != s.rows || cols != s.cols
+	s.rows, s.cols = rows, cols
+	s.mu.Unlock()
+
+	if changed && s.resize != nil {
+		s.resize(rows, cols)
+	}
+}
+
+// command sends a command to tmux through the control client. reply is given
+// whatever tmux answers with
+func (s *tmuxSource) command(cmd string, reply func(lines []string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := io.WriteString(s.stdin, cmd+"\n"); err != nil {
+		return err
+	}
+	s.replies = append(s.replies, reply)
+	return nil
+}
+
+func (s *tmuxSource) nextReply() func(lines []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.replies) == 0 {
+		return nil
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply
+}
+
+// unescapeControl undoes the octal escapes control mode uses for control
+// characters and backslashes in a pane's output
+func unescapeControl(data string) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' && i+3 < len(data) && isOctal(data[i+1]) && isOctal(data[i+2]) && isOctal(data[i+3]) {
+			out = append(out, (data[i+1]-'0')<<6|(data[i+2]-'0')<<3|(data[i+3]-'0'))
+			i += 3
+			continue
+		}
+		out = append(out, data[i])
+	}
+
+	return out
+}
+
+func isOctal(b byte) bool {
+	return b >= '0' && b <= '7'
+}
+
+func (s *tmuxSource) Read(b []byte) (int, error) {
+	return s.r.Read(b)
+}
+
+// Write types into the pane
+func (s *tmuxSource) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(b))
+	for i := range b {
+		keys[i] = hex.EncodeToString(b[i : i+1])
+	}
+	if err := s.command(fmt.Sprintf("send-keys -t %s -H %s", s.pane, strings.Join(keys, " ")), nil); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close detaches from tmux leaving the pane running
+func (s *tmuxSource) Close() error {
+	s.stdin.Close()
+	return s.r.Close()
+}
+
+// the pane keeps whatever size it has in tmux
+func (s *tmuxSource) SetSize(rows, cols int) error {
+	return nil
+}
+
+// Wait lets the control client go once the pane has. tmux doesn't say how the
+// pane's program exited so it always looks like it went fine
+func (s *tmuxSource) Wait() (int, error) {
+	s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 0, err
+		}
+	}
+
+	return 0, nil
+}
+
+// fileSource follows a file or fifo that something else writes a terminal's
+// output to like script -f or tmux's pipe-pane. nothing can be typed into it
+type fileSource struct {
+	f       *os.File
+	follow  bool
+	done    chan struct{}
+	closing sync.Once
+}
+
+// NewFileSource mirrors the terminal output being written to path. A regular
+// file is followed from its end like tail -f does; a fifo ends the session
+// once whatever's writing to it closes it. What clients type is dropped
+func NewFileSource(path string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	follow := fi.Mode().IsRegular()
+	if follow {
+		// what's already in there was drawn for a screen we never saw
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return &fileSource{f: f, follow: follow, done: make(chan struct{})}, nil
+}
+
+func (s *fileSource) Read(b []byte) (int, error) {
+	for {
+		n, err := s.f.Read(b)
+		if n > 0 {
+			return n, nil
+		}
+		select {
+		case <-s.done:
+			return 0, io.EOF
+		default:
+		}
+		if !errors.Is(err, io.EOF) || !s.follow {
+			return n, err
+		}
+
+		select {
+		case <-s.done:
+			return 0, io.EOF
+		case <-time.After(mirrorPollInterval):
+		}
+	}
+}
+
+func (s *fileSource) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (s *fileSource) Close() error {
+	s.closing.Do(func() { close(s.done) })
+	return s.f.Close()
+}
+
+// the program writing the file decides its size
+func (s *fileSource) SetSize(rows, cols int) error {
+	return nil
+}
+
+func (s *fileSource) Wait() (int, error) {
+	return 0, nil
+}
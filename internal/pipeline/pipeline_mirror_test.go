@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnescapeControl(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: `hi\015\012`, want: "hi\r\n"},
+		{in: `\033[1mbold\033[0m`, want: "\x1b[1mbold\x1b[0m"},
+		{in: `back\134slash`, want: `back\slash`},
+		// anything that isn't a full escape is left alone
+		{in: `\01`, want: `\01`},
+		{in: `\9xx`, want: `\9xx`},
+	}
+
+	for _, tt := range tests {
+		if got := string(unescapeControl(tt.in)); got != tt.want {
+			t.Errorf("unescapeControl(%q) = %q want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTmuxControlOutput(t *testing.T) {
+	r, w := io.Pipe()
+	src := &tmuxSource{pane: "%1", rows: 24, cols: 80, stdin: nopWriteCloser{io.Discard}}
+	src.r, src.w = io.Pipe()
+
+	var resized [2]int
+	src.resize = func(rows, cols int) { resized = [2]int{rows, cols} }
+	go func() {
+		src.w.CloseWithError(src.readControl(r))
+	}()
+
+	go func() {
+		io.WriteString(w, "%begin 1 1 0\n%end 1 1 0\n%session-changed $0 test\n")
+		// already on the screen we're about to capture
+		io.WriteString(w, "%output %1 $ \n")
+		io.WriteString(w, "%begin 2 2 1\n$ \n%end 2 2 1\n%begin 3 3 1\n0 2\n%end 3 3 1\n")
+		io.WriteString(w, "%output %2 not ours\n")
+		io.WriteString(w, `%output %1 ls\015\012`+"\n")
+		io.WriteString(w, "%layout-change @1 abcd,50x10,0,0,1 abcd,50x10,0,0,1 *\n")
+		io.WriteString(w, "%begin 4 4 1\n10 50\n%end 4 4 1\n")
+		io.WriteString(w, "%exit\n")
+		w.Close()
+	}()
+
+	got, err := io.ReadAll(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x1b[H\x1b[2J$ \x1b[1;3Hls\r\n"; string(got) != want {
+		t.Fatalf("expected the captured screen then the pane's output got %q want %q", got, want)
+	}
+	if resized != [2]int{10, 50} {
+		t.Fatalf("expected the pane's new size to be followed got %v", resized)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "typescript")
+	if err := os.WriteFile(path, []byte("drawn before we got here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	go func() {
+		time.Sleep(mirrorPollInterval * 2)
+		f.WriteString("new output")
+	}()
+
+	buf := make([]byte, 64)
+	n, err := src.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "new output" {
+		t.Fatalf("expected only what was written after we started got %q", buf[:n])
+	}
+
+	// closing stops the read waiting for more
+	read := make(chan error, 1)
+	go func() {
+		_, err := src.Read(buf)
+		read <- err
+	}()
+	src.Close()
+	select {
+	case err := <-read:
+		if err != io.EOF {
+			t.Fatalf("expected eof after closing got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the read to stop once the source was closed")
+	}
+}
+
+func TestTmuxPipeline(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux isn't installed")
+	}
+
+	// keep the test's server away from any the user has running
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	tmux := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+			t.Fatalf("tmux %v: %v %s", args, err, out)
+		}
+	}
+	tmux("-f", "/dev/null", "new-session", "-d", "-s", "test", "-x", "60", "-y", "20", "cat")
+	defer exec.Command("tmux", "kill-server").Run()
+
+	p, err := NewTmuxPipeline(1, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if rows, cols := p.Size(); rows != 20 || cols != 60 {
+		t.Fatalf("expected the pane's size got %dx%d", cols, rows)
+	}
+
+	p.HideLocally()
+	done := make(chan struct{}, 1)
+	p.Start(done)
+	// what's typed into the session lands in the pane which cat echoes back
+	p.WriteTo([]byte("mirrored\r"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		screen := p.screen.keyframe()
+		p.mu.Unlock()
+		if bytes.Count(screen, []byte("mirrored")) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pane's output to be mirrored got %q", screen)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	tmux("kill-session", "-t", "test")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the pipeline to end with the pane")
+	}
+	if _, exited := p.ExitStatus(); !exited {
+		t.Fatal("expected the pipeline to have finished")
+	}
+}
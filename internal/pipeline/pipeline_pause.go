@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"log"
+	"willofdaedalus/superluminal/internal/payload/base"
+	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
+)
+
+// Pause stops sending the pty's output to consumers. The host still sees it and
+// the screen and any recording are kept up to date so resuming can send a fresh
+// snapshot instead of everything that happened in between. Lines that scroll
+// off while paused are left out of the scrollback
+func (p *Pipeline) Pause() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return nil
+	}
+
+	f, err := infoFrame(info.Info_INFO_STREAM_PAUSED, "the host paused the stream")
+	if err != nil {
+		return err
+	}
+
+	// what came out before the pause still goes to everyone
+	p.flushBatchLocked()
+	p.paused = true
+	p.screen.history.withhold(true)
+	p.enqueueAllLocked(f)
+	return nil
+}
+
+// Resume starts sending the pty's output to consumers again beginning with a
+// keyframe of the screen as it is now
+func (p *Pipeline) Resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return nil
+	}
+
+	f, err := infoFrame(info.Info_INFO_STREAM_RESUMED, "the host resumed the stream")
+	if err != nil {
+		return err
+	}
+	// what came out while paused is kept from everyone too
+	p.flushBatchLocked()
+	kf, err := p.keyframeLocked()
+	if err != nil {
+		return err
+	}
+
+	p.paused = false
+	p.screen.history.withhold(false)
+	p.enqueueAllLocked(f, kf)
+	return nil
+}
+
+// Paused reports whether the stream is paused
+func (p *Pipeline) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// infoFrame encodes an info message to go out in line with the terminal data
+func infoFrame(infoType info.Info_InfoType, msg string) (frame, error) {
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO, base.GenerateInfo(infoType, msg))
+	if err != nil {
+		return frame{}, err
+	}
+
+	return frame{payload: payload}, nil
+}
+
+// enqueueAllLocked queues frames for every consumer dropping the ones that
+// can't keep up. must be called with p.mu held
+func (p *Pipeline) enqueueAllLocked(frames ...frame) {
+	for conn, c := range p.consumers {
+		for _, f := range frames {
+			if !c.enqueue(f) {
+				log.Printf("consumer %s fell too far behind; disconnecting", conn.RemoteAddr())
+				p.removeConsumerLocked(conn)
+				break
+			}
+		}
+	}
+}
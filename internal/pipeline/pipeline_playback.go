@@ -0,0 +1,418 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+const (
+	// how far the seek keys jump
+	seekStep = 5 * time.Second
+	maxSpeed = 16.0
+	minSpeed = 1.0 / 16
+	// longest line in a recording we'll read
+	maxCastLine = 16 * 1024 * 1024
+)
+
+// keys that control playback
+type playerKey int
+
+const (
+	keyPause playerKey = iota + 1
+	keyForward
+	keyBack
+	keyFaster
+	keySlower
+	keyQuit
+)
+
+// Cast is an asciicast v2 recording
+type Cast struct {
+	Width  int
+	Height int
+	events []castEvent
+}
+
+type castEvent struct {
+	// seconds since the start of the recording
+	time float64
+	kind string
+	data string
+}
+
+// Duration is how long the recording runs for at normal speed
+func (c *Cast) Duration() time.Duration {
+	if len(c.events) == 0 {
+		return 0
+	}
+	return time.Duration(c.events[len(c.events)-1].time * float64(time.Second))
+}
+
+// LoadCast reads the asciicast v2 recording at path
+func LoadCast(path string) (*Cast, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadCast(file)
+}
+
+// ReadCast reads an asciicast v2 recording. Events other than output and resizes
+// are kept but skipped when playing back
+func ReadCast(r io.Reader) (*Cast, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCastLine)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: file is empty", utils.ErrBadRecording)
+	}
+
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("%w: bad header: %v", utils.ErrBadRecording, err)
+	}
+	if header.Version != asciicastVersion {
+		return nil, fmt.Errorf("%w: version %d isn't supported", utils.ErrBadRecording, header.Version)
+	}
+
+	cast := &Cast{Width: header.Width, Height: header.Height}
+	if cast.Width <= 0 || cast.Height <= 0 {
+		cast.Width, cast.Height = defaultCols, defaultRows
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line += 1
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var fields []any
+		if err := json.Unmarshal(text, &fields); err != nil || len(fields) != 3 {
+			return nil, fmt.Errorf("%w: bad event on line %d", utils.ErrBadRecording, line)
+		}
+		t, okTime := fields[0].(float64)
+		kind, okKind := fields[1].(string)
+		data, okData := fields[2].(string)
+		if !okTime || !okKind || !okData {
+			return nil, fmt.Errorf("%w: bad event on line %d", utils.ErrBadRecording, line)
+		}
+
+		// time only goes forward; anything else would stall the player
+		if n := len(cast.events); n > 0 && t < cast.events[n-1].time {
+			t = cast.events[n-1].time
+		}
+		cast.events = append(cast.events, castEvent{time: t, kind: kind, data: data})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cast, nil
+}
+
+// PlaybackOptions are how a recording is played back
+type PlaybackOptions struct {
+	// how many times faster than it was recorded; 1 if it isn't set
+	Speed float64
+	// longest the player waits between events; 0 keeps the pauses as recorded
+	IdleLimit time.Duration
+	// wait for the pause key before starting
+	Paused bool
+}
+
+// Player plays a recording back with the timing it was recorded with. Output
+// is written to out and resizes are passed to onResize
+type Player struct {
+	cast     *Cast
+	out      io.Writer
+	onResize func(rows, cols int)
+	opts     PlaybackOptions
+	keys     chan playerKey
+	done     chan struct{}
+}
+
+func NewPlayer(cast *Cast, out io.Writer, onResize func(rows, cols int), opts PlaybackOptions) *Player {
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	return &Player{
+		cast:     cast,
+		out:      out,
+		onResize: onResize,
+		opts:     opts,
+		keys:     make(chan playerKey, 16),
+		done:     make(chan struct{}),
+	}
+}
+
+// Write takes what's typed while the recording plays. Space pauses, the left
+// and right arrows (or h and l) seek, + and - change the speed and q quits
+func (pl *Player) Write(b []byte) (int, error) {
+	for _, k := range parsePlayerKeys(b) {
+		select {
+		case pl.keys <- k:
+		case <-pl.done:
+			return len(b), nil
+		}
+	}
+
+	return len(b), nil
+}
+
+// Play plays the recording until it ends, ctx is done or the quit key is pressed
+func (pl *Player) Play(ctx context.Context) error {
+	defer close(pl.done)
+
+	events := pl.cast.events
+	pos := 0
+	// where in the recording we are in seconds
+	clock := 0.0
+	speed := pl.opts.Speed
+	paused := pl.opts.Paused
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for pos < len(events) {
+		var due <-chan time.Time
+		if !paused {
+			timer.Reset(pl.delay(events[pos].time-clock, speed))
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-due:
+			if err := pl.emit(events[pos:pos+1], nil); err != nil {
+				return err
+			}
+			clock = events[pos].time
+			pos += 1
+		case k := <-pl.keys:
+			timer.Stop()
+
+			var err error
+			switch k {
+			case keyPause:
+				paused = !paused
+			case keyForward:
+				pos, clock, err = pl.seek(pos, clock, seekStep)
+			case keyBack:
+				pos, clock, err = pl.seek(pos, clock, -seekStep)
+			case keyFaster:
+				speed = min(speed*2, maxSpeed)
+			case keySlower:
+				speed = max(speed/2, minSpeed)
+			case keyQuit:
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// delay is how long to wait for an event gap seconds away
+func (pl *Player) delay(gap, speed float64) time.Duration {
+	if gap <= 0 {
+		return 0
+	}
+
+	d := time.Duration(gap * float64(time.Second))
+	if pl.opts.IdleLimit > 0 && d > pl.opts.IdleLimit {
+		d = pl.opts.IdleLimit
+	}
+	return time.Duration(float64(d) / speed)
+}
+
+// seek jumps by from clock and returns the next event to play and the new
+// clock. everything between is drawn at once
+func (pl *Player) seek(pos int, clock float64, by time.Duration) (int, float64, error) {
+	events := pl.cast.events
+	target := max(0, min(clock+by.Seconds(), pl.cast.Duration().Seconds()))
+
+	var prefix []byte
+	if target < clock {
+		// a screen can't be wound back so it's drawn again from the start
+		pos = 0
+		prefix = []byte("\x1bc")
+		if pl.onResize != nil {
+			pl.onResize(pl.cast.Height, pl.cast.Width)
+		}
+	}
+
+	end := pos
+	for end < len(events) && events[end].time <= target {
+		end += 1
+	}
+
+	if err := pl.emit(events[pos:end], prefix); err != nil {
+		return pos, clock, err
+	}
+	return end, target, nil
+}
+
+// emit writes out events after prefix, joining output events together
+func (pl *Player) emit(events []castEvent, prefix []byte) error {
+	buf := append([]byte(nil), prefix...)
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := pl.out.Write(buf)
+		buf = buf[:0]
+		return err
+	}
+
+	for _, e := range events {
+		switch e.kind {
+		case eventOutput:
+			buf = append(buf, e.data...)
+		case eventResize:
+			if err := flush(); err != nil {
+				return err
+			}
+
+			var rows, cols int
+			if _, err := fmt.Sscanf(e.data, "%dx%d", &cols, &rows); err != nil {
+				continue
+			}
+			if pl.onResize != nil {
+				pl.onResize(rows, cols)
+			}
+		}
+	}
+
+	return flush()
+}
+
+func parsePlayerKeys(b []byte) []playerKey {
+	var keys []playerKey
+	for i := 0; i < len(b); i++ {
+		// arrow keys come through as esc [ C or esc O C depending on the mode
+		// the terminal is in
+		if b[i] == 0x1b && i+2 < len(b) && (b[i+1] == '[' || b[i+1] == 'O') {
+			switch b[i+2] {
+			case 'C':
+				keys = append(keys, keyForward)
+			case 'D':
+				keys = append(keys, keyBack)
+			}
+			i += 2
+			continue
+		}
+
+		switch b[i] {
+		case ' ':
+			keys = append(keys, keyPause)
+		case 'l':
+			keys = append(keys, keyForward)
+		case 'h':
+			keys = append(keys, keyBack)
+		case '+', '=':
+			keys = append(keys, keyFaster)
+		case '-':
+			keys = append(keys, keySlower)
+		case 'q', 0x03:
+			keys = append(keys, keyQuit)
+		}
+	}
+
+	return keys
+}
+
+// playbackSource feeds a recording to the pipeline as if it was a program
+// running in a pty. what's typed into the session controls the player
+type playbackSource struct {
+	player *Player
+	r      *io.PipeReader
+	w      *io.PipeWriter
+	ctx    context.Context
+	cancel context.CancelFunc
+	start  sync.Once
+}
+
+func (s *playbackSource) Read(b []byte) (int, error) {
+	// the recording starts once someone's reading it so none of it is lost
+	s.start.Do(func() {
+		go func() {
+			s.w.CloseWithError(s.player.Play(s.ctx))
+		}()
+	})
+
+	return s.r.Read(b)
+}
+
+func (s *playbackSource) Write(b []byte) (int, error) {
+	return s.player.Write(b)
+}
+
+func (s *playbackSource) Close() error {
+	s.cancel()
+	return s.r.Close()
+}
+
+// the recording stays the size it was made at and its own resizes go straight
+// to the pipeline
+func (s *playbackSource) SetSize(rows, cols int) error {
+	return nil
+}
+
+// the recording finishing is as good as a program exiting cleanly
+func (s *playbackSource) Wait() (int, error) {
+	return 0, nil
+}
+
+// NewPlaybackPipeline creates a pipeline that streams a recording to its
+// consumers instead of a live pty. The session ends when the recording does
+func NewPlaybackPipeline(maxConns uint8, cast *Cast, opts PlaybackOptions) *Pipeline {
+	p := &Pipeline{
+		consumers: make(map[net.Conn]*consumer, maxConns),
+		stopChan:  make(chan struct{}),
+		ring:      newFrameRing(maxRingFrames),
+		screen:    newVTerm(cast.Height, cast.Width),
+		policy:    DropOldest,
+		queueSize: defaultQueueSize,
+		fixedSize: true,
+	}
+
+	r, w := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	player := NewPlayer(cast, w, func(rows, cols int) {
+		if err := p.resize(rows, cols); err != nil {
+			log.Println("couldn't resize the playback:", err)
+		}
+	}, opts)
+
+	p.src = &playbackSource{
+		player: player,
+		r:      r,
+		w:      w,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	return p
+}
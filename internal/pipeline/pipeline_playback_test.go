@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+const testCast = `{"version": 2, "width": 100, "height": 30, "timestamp": 1700000000}
+[0.1, "o", "hello "]
+[0.2, "i", "ls\r"]
+[0.3, "o", "world\r\n"]
+[0.4, "r", "120x40"]
+[60.0, "o", "after a long pause"]
+`
+
+func TestReadCast(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cast.Width != 100 || cast.Height != 30 {
+		t.Fatalf("expected 100x30 got %dx%d", cast.Width, cast.Height)
+	}
+	if len(cast.events) != 5 {
+		t.Fatalf("expected 5 events got %d", len(cast.events))
+	}
+	if cast.Duration() != time.Minute {
+		t.Fatalf("expected a minute long recording got %s", cast.Duration())
+	}
+
+	bad := []string{
+		"",
+		`{"version": 1, "width": 80, "height": 24}`,
+		`{"version": 2, "width": 80, "height": 24}` + "\n[0.1, \"o\"]",
+		"not json",
+	}
+	for _, b := range bad {
+		if _, err := ReadCast(strings.NewReader(b)); !errors.Is(err, utils.ErrBadRecording) {
+			t.Fatalf("expected bad recording error for %q got %v", b, err)
+		}
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	var sizes [][2]int
+	player := NewPlayer(cast, &out, func(rows, cols int) {
+		sizes = append(sizes, [2]int{rows, cols})
+	}, PlaybackOptions{Speed: 4, IdleLimit: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := player.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("idle limit wasn't applied; playback took %s", elapsed)
+	}
+
+	if want := "hello world\r\nafter a long pause"; out.String() != want {
+		t.Fatalf("expected %q got %q", want, out.String())
+	}
+	if len(sizes) != 1 || sizes[0] != [2]int{40, 120} {
+		t.Fatalf("expected a single resize to 120x40 got %v", sizes)
+	}
+}
+
+func TestPlayerKeys(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	player := NewPlayer(cast, &out, nil, PlaybackOptions{Paused: true})
+	done := make(chan error, 1)
+	go func() {
+		done <- player.Play(context.Background())
+	}()
+
+	// nothing plays while paused but seeking still draws what was skipped
+	player.Write([]byte("\x1b[C"))
+	player.Write([]byte("q"))
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("player didn't quit")
+	}
+
+	if want := "hello world\r\n"; out.String() != want {
+		t.Fatalf("expected %q got %q", want, out.String())
+	}
+}
@@ -1,6 +1,12 @@
 package pipeline
 
-import "bytes"
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
 
 // control sequences
 const (
@@ -9,51 +15,378 @@ const (
 	CR  byte = '\015' // ^M
 )
 
-func skipAheadTo(data []byte, b []byte) []byte {
-	result := data
-	for i, curByte := range data {
-		if bytes.Contains(b, []byte{curByte}) {
-			result = data[i+1:]
-			break
+// how far along a line the cursor can be moved so a bogus sequence can't have
+// us pad a line out forever
+const maxLineCells = 1024
+
+const (
+	altScreenStarted = "[full screen program started]"
+	altScreenEnded   = "[full screen program ended]"
+)
+
+// what the dec line drawing characters look like in plain text
+var lineDrawing = map[rune]rune{
+	'j': '+', 'k': '+', 'l': '+', 'm': '+', 'n': '+',
+	't': '+', 'u': '+', 'v': '+', 'w': '+',
+	'q': '-', 'x': '|',
+}
+
+// Transcript turns pty output into plain lines of text with every escape
+// sequence stripped out. it's meant for screen readers, dumb terminals and
+// files so it only follows what the cursor does within a line; moving to
+// another row ends the line and whatever full screen programs draw on the
+// alternate screen is left out
+type Transcript struct {
+	w      io.Writer
+	parser *vtParser
+	err    error
+	// the line the cursor is on. a blank cell is a space and the right half
+	// of a wide character is empty
+	cells []string
+	col   int
+	row   int
+	// cells of the line already written by Flush and whether any of them has
+	// changed since
+	written int
+	dirty   bool
+	// set when a note was written after part of the line
+	noted bool
+	// set when what we've written doesn't end with a newline
+	midLine bool
+	// where DECSC left the cursor
+	savedRow, savedCol int
+	altScreen          bool
+	charsets           [2]byte
+	charset            int
+}
+
+func NewTranscript(w io.Writer) *Transcript {
+	t := &Transcript{w: w}
+	t.parser = newVTParser(t)
+	return t
+}
+
+// Write feeds pty output to the transcript writing out every line it
+// finishes. the line the cursor is on is held back until it's finished or
+// Flush is called
+func (t *Transcript) Write(data []byte) (int, error) {
+	t.parser.parse(data)
+	if t.err != nil {
+		return 0, t.err
+	}
+	return len(data), nil
+}
+
+// Flush writes out what's on the line the cursor is on so far like a prompt
+// waiting for input. lines that are being rewritten are left until they're
+// finished so a progress bar doesn't end up on the screen a hundred times
+func (t *Transcript) Flush() error {
+	if t.dirty || t.col < len(t.cells) || len(t.cells) <= t.written {
+		return t.err
+	}
+
+	if t.noted {
+		// start the line over since what's already written of it is above
+		// the note
+		t.written, t.noted = 0, false
+	}
+	t.writeString(strings.Join(t.cells[t.written:], ""))
+	t.written = len(t.cells)
+	return t.err
+}
+
+// Note writes msg on a line of its own without disturbing the line the cursor
+// is on
+func (t *Transcript) Note(msg string) error {
+	if t.midLine {
+		t.writeString("\n")
+	}
+	t.noted = t.written > 0
+	t.writeString(msg + "\n")
+	return t.err
+}
+
+// PlainText returns data as plain lines of text
+func PlainText(data []byte) []byte {
+	var buf bytes.Buffer
+	t := NewTranscript(&buf)
+	t.Write(data)
+	t.Flush()
+	return buf.Bytes()
+}
+
+func (t *Transcript) writeString(s string) {
+	if t.err != nil || s == "" {
+		return
+	}
+	_, t.err = io.WriteString(t.w, s)
+	t.midLine = !strings.HasSuffix(s, "\n")
+}
+
+// endLine writes out the line the cursor is on and starts a new one
+func (t *Transcript) endLine() {
+	switch {
+	case t.dirty || t.noted && len(t.cells) > t.written:
+		// what's already written of the line is out of date or above a note
+		// so it's written out again in full
+		if t.midLine {
+			t.writeString("\n")
 		}
+		t.writeString(strings.TrimRight(strings.Join(t.cells, ""), " ") + "\n")
+	case t.noted:
+		// nothing's been added to the line since the note
+	default:
+		t.writeString(strings.TrimRight(strings.Join(t.cells[t.written:], ""), " ") + "\n")
 	}
 
-	return result
+	t.cells = t.cells[:0]
+	t.col, t.written, t.dirty, t.noted = 0, 0, false, false
+}
+
+// moveTo puts the cursor at col on row, ending the line it's on if row is a
+// different one
+func (t *Transcript) moveTo(row, col int) {
+	if row != t.row && (len(t.cells) > 0 || t.written > 0) {
+		t.endLine()
+	}
+	t.row, t.col = row, min(max(col, 0), maxLineCells)
 }
 
-// returns a sanitized and clean pty output
-func sanitizeRawCode(data []byte) []byte {
-	result := make([]byte, 0, len(data))
+// touch marks the cells from col onwards as changed
+func (t *Transcript) touch(col int) {
+	if col < t.written {
+		t.dirty = true
+	}
+}
 
-	for len(data) > 0 {
-		b := data[0]
-		data = data[1:]
+// pad fills the line with blanks up to n cells
+func (t *Transcript) pad(n int) {
+	for len(t.cells) < n {
+		t.cells = append(t.cells, " ")
+	}
+}
 
-		switch b {
-		case ESC:
-			delimiter := data[0]
-			data = handleDelimiter(delimiter, data)
-		default:
-			result = append(result, b)
+func (t *Transcript) print(r rune) {
+	if t.altScreen {
+		return
+	}
+	if t.charsets[t.charset] == '0' {
+		if ch, ok := lineDrawing[r]; ok {
+			r = ch
 		}
 	}
 
-	return result
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		t.combine(r)
+		return
+	}
+
+	t.pad(t.col + width)
+	t.touch(t.col)
+	// don't leave half of a wide character we're writing over behind
+	if t.cells[t.col] == "" && t.col > 0 {
+		t.cells[t.col-1] = " "
+	}
+	if t.col+width < len(t.cells) && t.cells[t.col+width] == "" {
+		t.cells[t.col+width] = " "
+	}
+
+	t.cells[t.col] = string(r)
+	if width == 2 {
+		t.cells[t.col+1] = ""
+	}
+	t.col += width
+}
+
+// combine attaches a zero width character to the one before the cursor
+func (t *Transcript) combine(r rune) {
+	col := min(t.col, len(t.cells)) - 1
+	if col >= 0 && t.cells[col] == "" {
+		col -= 1
+	}
+	if col < 0 {
+		return
+	}
+	t.touch(col)
+	t.cells[col] += string(r)
+}
+
+func (t *Transcript) execute(b byte) {
+	if t.altScreen {
+		return
+	}
+
+	switch b {
+	case '\b':
+		t.col = max(min(t.col, len(t.cells))-1, 0)
+	case '\t':
+		// tabs are kept as they are since there's no telling where the
+		// terminal's tab stops are
+		t.pad(t.col + 1)
+		t.touch(t.col)
+		t.cells[t.col] = "\t"
+		t.col += 1
+	case '\n', '\v', '\f':
+		t.endLine()
+		t.row += 1
+	case '\r':
+		t.col = 0
+	case 0x0e: // SO
+		t.charset = 1
+	case 0x0f: // SI
+		t.charset = 0
+	}
 }
 
-func handleDelimiter(del byte, data []byte) []byte {
-	switch del {
-	case ']':
-		data = skipAheadTo(data, []byte{BEL})
-	case '[':
-		skipTo := make([]byte, 0)
-		switch data[1] {
-		case '?':
-			skipTo = append(skipTo, 'h', 'l')
+func (t *Transcript) csiDispatch(params []int, intermediates []byte, private byte, final byte) {
+	param := func(i, def int) int {
+		if i < len(params) && params[i] > 0 {
+			return params[i]
 		}
+		return def
+	}
 
-		data = skipAheadTo(data, []byte{'h', 'l'})
+	if private == '?' && (final == 'h' || final == 'l') {
+		for _, mode := range params {
+			if mode == 47 || mode == 1047 || mode == 1049 {
+				t.switchScreen(final == 'h')
+			}
+		}
+		return
+	}
+	if t.altScreen || private != 0 || len(intermediates) > 0 {
+		return
 	}
 
-	return data
+	switch final {
+	case 'A': // CUU
+		t.moveTo(t.row-param(0, 1), t.col)
+	case 'B', 'e': // CUD, VPR
+		t.moveTo(t.row+param(0, 1), t.col)
+	case 'C', 'a': // CUF, HPR
+		t.col = min(t.col+param(0, 1), maxLineCells)
+	case 'D': // CUB
+		t.col = max(t.col-param(0, 1), 0)
+	case 'E': // CNL
+		t.moveTo(t.row+param(0, 1), 0)
+	case 'F': // CPL
+		t.moveTo(t.row-param(0, 1), 0)
+	case 'G', '`': // CHA, HPA
+		t.col = min(param(0, 1)-1, maxLineCells)
+	case 'H', 'f': // CUP, HVP
+		t.moveTo(param(0, 1)-1, param(1, 1)-1)
+	case 'd': // VPA
+		t.moveTo(param(0, 1)-1, t.col)
+	case 'J': // ED
+		switch param(0, 0) {
+		case 0:
+			t.eraseLine(0)
+		case 2, 3:
+			// the screen is cleared so the line is done with
+			if len(t.cells) > 0 || t.written > 0 {
+				t.endLine()
+			}
+		}
+	case 'K': // EL
+		t.eraseLine(param(0, 0))
+	case 'X': // ECH
+		end := min(t.col+param(0, 1), len(t.cells))
+		for i := t.col; i < end; i++ {
+			t.touch(i)
+			t.cells[i] = " "
+		}
+	case 'P': // DCH
+		if t.col < len(t.cells) {
+			t.touch(t.col)
+			n := min(param(0, 1), len(t.cells)-t.col)
+			t.cells = append(t.cells[:t.col], t.cells[t.col+n:]...)
+		}
+	case '@': // ICH
+		if t.col < len(t.cells) {
+			t.touch(t.col)
+			blanks := strings.Split(strings.Repeat(" ", min(param(0, 1), maxLineCells)), "")
+			t.cells = append(t.cells[:t.col], append(blanks, t.cells[t.col:]...)...)
+			t.cells = t.cells[:min(len(t.cells), maxLineCells)]
+		}
+	}
 }
+
+// eraseLine blanks out the line after the cursor, before it or all of it
+func (t *Transcript) eraseLine(mode int) {
+	switch mode {
+	case 0:
+		if t.col < len(t.cells) {
+			t.touch(t.col)
+			t.cells = t.cells[:t.col]
+		}
+	case 1:
+		end := min(t.col+1, len(t.cells))
+		for i := 0; i < end; i++ {
+			t.touch(i)
+			t.cells[i] = " "
+		}
+	case 2:
+		t.touch(0)
+		t.cells = t.cells[:0]
+	}
+}
+
+// switchScreen notes a full screen program starting or ending in place of
+// whatever it draws
+func (t *Transcript) switchScreen(alt bool) {
+	if alt == t.altScreen {
+		return
+	}
+
+	if alt {
+		if len(t.cells) > 0 || t.written > 0 {
+			t.endLine()
+		}
+		t.writeString(altScreenStarted + "\n")
+	} else {
+		t.writeString(altScreenEnded + "\n")
+		t.cells = t.cells[:0]
+		t.col, t.written, t.dirty, t.noted = 0, 0, false, false
+	}
+	t.altScreen = alt
+}
+
+func (t *Transcript) escDispatch(intermediates []byte, final byte) {
+	if len(intermediates) > 0 {
+		switch intermediates[0] {
+		case '(':
+			t.charsets[0] = final
+		case ')':
+			t.charsets[1] = final
+		}
+		return
+	}
+	if t.altScreen {
+		return
+	}
+
+	switch final {
+	case '7': // DECSC
+		t.savedRow, t.savedCol = t.row, t.col
+	case '8': // DECRC
+		t.moveTo(t.savedRow, t.savedCol)
+	case 'D': // IND
+		col := t.col
+		t.execute('\n')
+		t.col = col
+	case 'E': // NEL
+		t.execute('\n')
+	case 'M': // RI
+		t.moveTo(t.row-1, t.col)
+	case 'c': // RIS
+		t.switchScreen(false)
+		if len(t.cells) > 0 || t.written > 0 {
+			t.endLine()
+		}
+		t.charsets, t.charset = [2]byte{}, 0
+	}
+}
+
+// nothing an operating system command does ends up in the text
+func (t *Transcript) oscDispatch(data []byte) {}
@@ -1,66 +1,13 @@
 package pipeline
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func Test_skipAheadTo(t *testing.T) {
-	type args struct {
-		data []byte
-		b    byte
-	}
-	tests := []struct {
-		name string
-		args args
-		want []byte
-	}{
-		{
-			name: "byte present in data",
-			args: args{data: []byte{1, 2, 3, 4, 5}, b: 3},
-			want: []byte{4, 5},
-		},
-		{
-			name: "byte not present in data",
-			args: args{data: []byte{1, 2, 4, 5}, b: 3},
-			want: []byte{1, 2, 4, 5},
-		},
-		{
-			name: "byte is the first element",
-			args: args{data: []byte{3, 4, 5}, b: 3},
-			want: []byte{4, 5},
-		},
-		{
-			name: "empty data slice",
-			args: args{data: []byte{}, b: 3},
-			want: []byte{},
-		},
-		{
-			name: "multiple occurrences of byte",
-			args: args{data: []byte{1, 3, 2, 3, 4}, b: 3},
-			want: []byte{2, 3, 4},
-		},
-		{
-			name: "single element equal to byte",
-			args: args{data: []byte{3}, b: 3},
-			want: []byte{},
-		},
-		{
-			name: "single element not equal to byte",
-			args: args{data: []byte{1}, b: 3},
-			want: []byte{1},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := skipAheadTo(tt.args.data, []byte{tt.args.b}); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("skipAheadTo() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_sanitizeRawCode(t *testing.T) {
+func TestPlainText(t *testing.T) {
 	type args struct {
 		data []byte
 	}
@@ -73,11 +20,11 @@ func Test_sanitizeRawCode(t *testing.T) {
 			name: "simple complex",
 			args: args{
 				data: []byte(`
-[?2004h[daedalus@theforge superluminal]$ ls
+[?2004h[daedalus@theforge superluminal]$ ls
 [?2004lREADME.md  btop.output	go.mod	go.sum	hello  hello_log  internal  log.output	main.go  out.gif  output.txt  protos  protos.sh  scripts  superluminal	tape.vhs  tmp  tmux.output
-[?2004h[daedalus@theforge superluminal]$ whoami
+[?2004h[daedalus@theforge superluminal]$ whoami
 [?2004ldaedalus
-[?2004h[daedalus@theforge superluminal]$ exit
+[?2004h[daedalus@theforge superluminal]$ exit
 [?2004lexit
 `),
 			},
@@ -95,21 +42,118 @@ exit
 			name: "simple colour output test",
 			args: args{
 				data: []byte(`
-]0;daedalus@theforge:~/projects/golang/personal/superluminal[?2004h[daedalus@theforge superluminal]$ ls --color=auto
+]0;daedalus@theforge:~/projects/golang/personal/superluminal[?2004h[daedalus@theforge superluminal]$ ls --color=auto
 [?2004lREADME.md  btop.output  go.mod  go.sum  hello  hello_log  [0m[01;34minternal[0m  log.output  log.output.bak  main.go  out.gif  [01;34mprotos[0m  [01;32mprotos.sh[0m  [01;34mscripts[0m  [01;32msuperluminal[0m  tape.vhs  [01;34mtmp[0m  tmux.output
-]0;daedalus@theforge:~/projects/golang/personal/superluminal[?2004h[daedalus@theforge superluminal]$ exit
+]0;daedalus@theforge:~/projects/golang/personal/superluminal[?2004h[daedalus@theforge superluminal]$ exit
 [?2004lexit
 `),
 			},
 			want: []byte(`
+[daedalus@theforge superluminal]$ ls --color=auto
+README.md  btop.output  go.mod  go.sum  hello  hello_log  internal  log.output  log.output.bak  main.go  out.gif  protos  protos.sh  scripts  superluminal  tape.vhs  tmp  tmux.output
+[daedalus@theforge superluminal]$ exit
+exit
 `),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := sanitizeRawCode(tt.args.data); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("sanitizeRawCode() = %v, want %v w len %d", got, tt.want, len(got))
+			if got := PlainText(tt.args.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlainText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainTextSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"trailing escape", "hello\x1b", "hello"},
+		{"trailing csi", "hello\x1b[3", "hello"},
+		{"carriage return overwrites", "50%\r100%\n", "100%\n"},
+		{"backspace", "lx\bs\n", "ls\n"},
+		{"erase to end of line", "hello world\r\x1b[Kbye\n", "bye\n"},
+		{"cursor forward", "a\x1b[3Cb\n", "a   b\n"},
+		{"cursor up ends the line", "one\x1b[Atwo\n", "one\n   two\n"},
+		{"cursor position", "\x1b[2J\x1b[1;1Htop\x1b[2;3Hnext\n", "top\n  next\n"},
+		{"delete characters", "abcdef\r\x1b[2Pz\n", "zdef\n"},
+		{"insert characters", "abc\r\x1b[2@\n", "  abc\n"},
+		{"dcs and osc strings", "\x1bP1$r0m\x1b\\a\x1b]8;;http://x\x1b\\b\x07c\n", "abc\n"},
+		{"charsets", "\x1b(0lqk\x1b(B ok\n", "+-+ ok\n"},
+		{"wide characters", "日本\r\x1b[2Cx\n", "日x\n"},
+		{"combining characters", "é\n", "é\n"},
+		{"trailing blanks", "hi   \n", "hi\n"},
+		{"full screen programs", "$ top\n\x1b[?1049h\x1b[Hcpu 10%\x1b[?1049l$ \n",
+			"$ top\n" + altScreenStarted + "\n" + altScreenEnded + "\n$\n"},
+		{"bogus movement", "\x1b[99999999Cx", strings.Repeat(" ", maxLineCells) + "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(PlainText([]byte(tt.data))); got != tt.want {
+				t.Errorf("PlainText() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestTranscript(t *testing.T) {
+	var buf bytes.Buffer
+	tr := NewTranscript(&buf)
+
+	// sequences split across writes are picked up where they left off
+	for _, b := range []byte("\x1b[1mbold\x1b[0m\n") {
+		tr.Write([]byte{b})
+	}
+	if buf.String() != "bold\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "bold\n")
+	}
+
+	// a prompt waiting for input is written out on flushing and what's typed
+	// after it carries on from there
+	buf.Reset()
+	tr.Write([]byte("$ "))
+	tr.Flush()
+	if buf.String() != "$ " {
+		t.Fatalf("got %q after flushing, want %q", buf.String(), "$ ")
+	}
+	tr.Write([]byte("ls"))
+	tr.Flush()
+	tr.Write([]byte("\r\n"))
+	if buf.String() != "$ ls\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "$ ls\n")
+	}
+
+	// a line that's rewritten after it was flushed is written out again in
+	// full once it's finished
+	buf.Reset()
+	tr.Write([]byte("$ lx"))
+	tr.Flush()
+	tr.Write([]byte("\b \bs"))
+	tr.Flush()
+	tr.Write([]byte("\r\n"))
+	if buf.String() != "$ lx\n$ ls\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "$ lx\n$ ls\n")
+	}
+
+	// a line being redrawn isn't flushed until it's finished
+	buf.Reset()
+	tr.Write([]byte("10%\r"))
+	tr.Flush()
+	tr.Write([]byte("100%\r\n"))
+	if buf.String() != "100%\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "100%\n")
+	}
+
+	// notes go on their own line
+	buf.Reset()
+	tr.Write([]byte("$ "))
+	tr.Flush()
+	tr.Note("[host] hello")
+	tr.Write([]byte("pwd\r\n"))
+	if want := "$ \n[host] hello\n$ pwd\n"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"willofdaedalus/superluminal/internal/utils"
+)
+
+const (
+	asciicastVersion = 2
+
+	eventOutput = "o"
+	eventInput  = "i"
+	eventResize = "r"
+)
+
+// asciicastHeader is the first line of an asciicast v2 file
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes what happens in the session to an asciicast v2 file which can
+// be played back with asciinema or superluminal itself
+type recorder struct {
+	path  string
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+	input bool
+	// the start of a utf-8 character that was cut off at the end of the last
+	// read; events have to be valid strings so it waits for the rest
+	partial []byte
+	mu      sync.Mutex
+}
+
+// newRecorder creates the file at path and writes the header. input events are
+// only written if recordInput is set since they can contain passwords
+func newRecorder(path string, rows, cols int, recordInput bool) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recorder{
+		path:  path,
+		file:  file,
+		w:     bufio.NewWriter(file),
+		start: time.Now(),
+		input: recordInput,
+	}
+
+	header := asciicastHeader{
+		Version:   asciicastVersion,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: r.start.Unix(),
+		Env: map[string]string{
+			"SHELL": getUserShell(),
+			"TERM":  os.Getenv("TERM"),
+		},
+	}
+	if err := r.writeLine(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// output records data the pty wrote
+func (r *recorder) output(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data = append(r.partial, data...)
+	cut := incompleteRuneStart(data)
+	r.partial = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return nil
+	}
+
+	return r.eventLocked(eventOutput, string(data[:cut]))
+}
+
+// keys records something typed into the pty if input is being recorded
+func (r *recorder) keys(data []byte) error {
+	if !r.input {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.eventLocked(eventInput, string(data))
+}
+
+// resize records the pty changing size
+func (r *recorder) resize(rows, cols int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.eventLocked(eventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// close writes out anything still buffered and closes the file
+func (r *recorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.partial) > 0 {
+		r.eventLocked(eventOutput, string(r.partial))
+		r.partial = nil
+	}
+
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// eventLocked writes a single event line; must be called with r.mu held. the
+// buffer is flushed after every event so a crash loses as little as possible
+func (r *recorder) eventLocked(kind, data string) error {
+	elapsed := time.Since(r.start).Seconds()
+	if err := r.writeLine([]any{elapsed, kind, data}); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+func (r *recorder) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	_, err = r.w.Write(line)
+	return err
+}
+
+// incompleteRuneStart returns where the utf-8 character cut off at the end of
+// data starts or len(data) if the last character is whole
+func incompleteRuneStart(data []byte) int {
+	// a utf-8 character is at most 4 bytes so only the last 3 can be a partial one
+	for i := len(data) - 1; i >= 0 && i >= len(data)-(utf8.UTFMax-1); i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if utf8.FullRune(data[i:]) {
+			return len(data)
+		}
+		return i
+	}
+
+	return len(data)
+}
+
+// StartRecording starts writing the session to an asciicast v2 file at path.
+// Input is only written if recordInput is set
+func (p *Pipeline) StartRecording(path string, recordInput bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.recorder != nil {
+		return fmt.Errorf("%w to %s", utils.ErrAlreadyRecording, p.recorder.path)
+	}
+
+	rows, cols := defaultRows, defaultCols
+	if p.screen != nil {
+		rows, cols = p.screen.rows, p.screen.cols
+	}
+
+	r, err := newRecorder(path, rows, cols, recordInput)
+	if err != nil {
+		return err
+	}
+
+	// the recording could be starting in the middle of the session so it opens
+	// with whatever is already on screen
+	if p.screen != nil && p.seq > 0 {
+		if err := r.output(p.screen.keyframe()); err != nil {
+			r.close()
+			return err
+		}
+	}
+	p.recorder = r
+
+	return nil
+}
+
+// StopRecording finishes the recording and returns where it was written
+func (p *Pipeline) StopRecording() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.recorder == nil {
+		return "", utils.ErrNotRecording
+	}
+
+	r := p.recorder
+	p.recorder = nil
+	return r.path, r.close()
+}
+
+// Recording returns the file the session is being recorded to if it is
+func (p *Pipeline) Recording() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.recorder == nil {
+		return "", false
+	}
+	return p.recorder.path, true
+}
+
+// recordLocked hands an event to the recorder if there is one and stops
+// recording if the file can't be written to anymore. must be called with p.mu held
+func (p *Pipeline) recordLocked(record func(r *recorder) error) {
+	if p.recorder == nil {
+		return
+	}
+
+	if err := record(p.recorder); err != nil {
+		log.Printf("couldn't write to recording %s; stopping: %v", p.recorder.path, err)
+		p.recorder.close()
+		p.recorder = nil
+	}
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+
+	if err := p.StartRecording(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.StartRecording(path, false); err == nil {
+		t.Fatal("expected an error starting a second recording")
+	}
+
+	// 世 split across two reads from the pty
+	p.broadcast([]byte("hello \xe4\xb8"))
+	p.broadcast([]byte("\x96\r\n"))
+	if err := p.Resize(30, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.StopRecording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != path {
+		t.Fatalf("expected recording at %s got %s", path, got)
+	}
+	if _, ok := p.Recording(); ok {
+		t.Fatal("expected recording to have stopped")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatal("recording is empty")
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Width != defaultCols || header.Height != defaultRows {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	want := [][2]string{
+		{eventOutput, "hello "},
+		{eventOutput, "世\r\n"},
+		{eventResize, "100x30"},
+	}
+	lastTime := 0.0
+	for i, w := range want {
+		if !scanner.Scan() {
+			t.Fatalf("expected %d events got %d", len(want), i)
+		}
+
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		if len(event) != 3 {
+			t.Fatalf("expected 3 fields in event got %v", event)
+		}
+
+		elapsed, _ := event[0].(float64)
+		if elapsed < lastTime {
+			t.Fatalf("event %d went back in time", i)
+		}
+		lastTime = elapsed
+
+		if event[1] != w[0] || event[2] != w[1] {
+			t.Fatalf("expected event %q %q got %q %q", w[0], w[1], event[1], event[2])
+		}
+	}
+	if scanner.Scan() {
+		t.Fatalf("unexpected event %s", scanner.Text())
+	}
+}
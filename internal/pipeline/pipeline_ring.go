@@ -0,0 +1,68 @@
+package pipeline
+
+import "willofdaedalus/superluminal/internal/payload/term"
+
+// frame is a payload that has already been encoded and is ready to go over the
+// wire. terminal frames also keep their raw data and the sequence numbers they
+// cover so they can be merged; control frames have no data
+type frame struct {
+	window   uint32
+	seq      uint64
+	firstSeq uint64
+	data     []byte
+	payload  []byte
+	// what the payload was encoded from for consumers that compress it
+	content *term.TerminalContent
+}
+
+// frameRing keeps the last few broadcast frames around so that clients that
+// missed some can ask for them again. once it's full the oldest frame is dropped
+type frameRing struct {
+	frames []frame
+	start  int
+	size   int
+}
+
+func newFrameRing(capacity int) *frameRing {
+	return &frameRing{
+		frames: make([]frame, capacity),
+	}
+}
+
+func (r *frameRing) push(f frame) {
+	if len(r.frames) == 0 {
+		return
+	}
+
+	end := (r.start + r.size) % len(r.frames)
+	r.frames[end] = f
+	if r.size < len(r.frames) {
+		r.size += 1
+		return
+	}
+	// we overwrote the oldest frame so move the start along
+	r.start = (r.start + 1) % len(r.frames)
+}
+
+// oldest returns the sequence number of the oldest frame still in the ring
+// and false if the ring is empty
+func (r *frameRing) oldest() (uint64, bool) {
+	if r.size == 0 {
+		return 0, false
+	}
+	return r.frames[r.start].seq, true
+}
+
+// between returns the payloads of the frames with sequence numbers in [from, to]
+// that are still in the ring in the order they were sent
+func (r *frameRing) between(from, to uint64) [][]byte {
+	result := make([][]byte, 0)
+	for i := 0; i < r.size; i++ {
+		f := r.frames[(r.start+i)%len(r.frames)]
+		if f.seq >= from && f.seq <= to {
+			result = append(result, f.payload)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameRing(t *testing.T) {
+	ring := newFrameRing(3)
+	if _, ok := ring.oldest(); ok {
+		t.Fatal("expected an empty ring to have no oldest frame")
+	}
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		ring.push(frame{seq: seq, payload: []byte{byte(seq)}})
+	}
+
+	oldest, ok := ring.oldest()
+	if !ok || oldest != 3 {
+		t.Fatalf("expected oldest frame 3 got %d", oldest)
+	}
+
+	got := ring.between(1, 4)
+	want := [][]byte{{3}, {4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("between() = %v, want %v", got, want)
+	}
+}
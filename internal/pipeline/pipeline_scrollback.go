@@ -0,0 +1,51 @@
+package pipeline
+
+// the most lines a client gets back for one request
+const maxScrollbackReply = 1000
+
+// SetScrollback changes how many of the lines that scrolled off the screen are
+// kept for clients to look back at. 0 or less stops keeping them
+func (p *Pipeline) SetScrollback(lines int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.screen.history.setCapacity(max(lines, 0))
+}
+
+// Scrollback returns the lines numbered from to to that scrolled off the screen
+// and are still kept along with the number of the first one and how many lines
+// have scrolled off altogether. lines are numbered from 1 and a to of 0 means
+// up to the newest line. nothing is given out while the stream is paused and
+// the lines that scrolled off while it was are left out for good
+func (p *Pipeline) Scrollback(from, to uint64) ([][]byte, uint64, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history := p.screen.history
+	if to == 0 {
+		to = history.total
+	}
+	if p.paused {
+		return nil, from, history.total
+	}
+
+	to = min(to, max(from, history.oldest())+maxScrollbackReply-1)
+	lines, first := history.between(from, to)
+	return lines, first, history.total
+}
+
+// LastScrollback returns the newest n lines that scrolled off the screen like
+// Scrollback does
+func (p *Pipeline) LastScrollback(n int) ([][]byte, uint64, uint64) {
+	p.mu.Lock()
+	total := p.screen.history.total
+	p.mu.Unlock()
+
+	n = min(n, maxScrollbackReply)
+	from := uint64(1)
+	if total > uint64(n) {
+		from = total - uint64(n) + 1
+	}
+
+	return p.Scrollback(from, total)
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// Source is what a pipeline streams to its consumers; normally the pty of a
+// program the pipeline started but it can be anything that behaves like a
+// terminal. Reading gives the terminal's output and writing types into it
+type Source interface {
+	io.ReadWriteCloser
+	// SetSize changes the size of the terminal the source draws to
+	SetSize(rows, cols int) error
+	// Wait blocks until whatever is behind the source has finished and
+	// returns its exit status
+	Wait() (int, error)
+}
+
+// ptySource is the pty of a program running in the session
+type ptySource struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+func (p ptySource) SetSize(rows, cols int) error {
+	return pty.Setsize(p.File, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+func (p ptySource) Wait() (int, error) {
+	if p.cmd == nil {
+		return 0, nil
+	}
+
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, err
+	}
+
+	// a program killed by a signal gets the status a shell would give it
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
@@ -1,12 +1,17 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
 	"hash/crc32"
+	"net"
 	"strings"
 	"testing"
 	"willofdaedalus/superluminal/internal/payload/base"
 	"willofdaedalus/superluminal/internal/payload/common"
+	"willofdaedalus/superluminal/internal/payload/info"
 	"willofdaedalus/superluminal/internal/payload/term"
+	"willofdaedalus/superluminal/internal/utils"
 )
 
 func TestANSI24BitComplexEncoding(t *testing.T) {
@@ -52,3 +57,275 @@ func TestANSI24BitComplexEncoding(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscribeSendsKeyframe(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+	p.broadcast([]byte("already on screen"))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	p.Subscribe(server)
+	defer p.Unsubscribe(server)
+
+	data, err := utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := payload.GetResize()
+	if size.GetRows() != defaultRows || size.GetCols() != defaultCols {
+		t.Fatalf("expected the first message to be the terminal size got %v", payload)
+	}
+
+	data, err = utils.ReadFull(context.Background(), client, utils.NewSyncTracker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err = base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := payload.GetTermContent()
+	if !content.GetKeyframe() {
+		t.Fatal("expected a keyframe after the terminal size")
+	}
+	if content.GetSequence() != 1 {
+		t.Fatalf("expected the keyframe to cover frame 1 got %d", content.GetSequence())
+	}
+	if !strings.Contains(string(content.GetData()), "already on screen") {
+		t.Fatalf("keyframe is missing what was on screen: %q", content.GetData())
+	}
+}
+
+func TestDrain(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	p.Subscribe(server)
+	defer p.Unsubscribe(server)
+
+	payload, err := base.EncodePayload(common.Header_HEADER_INFO,
+		base.GenerateInfo(info.Info_INFO_SHUTDOWN, "bye"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send(server, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody's reading so nothing can be written
+	ctx, cancel := context.WithTimeout(context.Background(), drainInterval*5)
+	defer cancel()
+	if err := p.Drain(ctx); err == nil {
+		t.Fatal("expected draining to give up while the client isn't reading")
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- p.Drain(context.Background()) }()
+
+	tracker := utils.NewSyncTracker()
+	var last *base.Payload
+	for i := 0; i < 3; i++ {
+		data, err := utils.ReadFull(context.Background(), client, tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last, err = base.DecodePayload(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last.GetInfo().GetInfoType() != info.Info_INFO_SHUTDOWN {
+		t.Fatalf("expected the payload sent last to be written last got %v", last)
+	}
+	if err := <-drained; err != nil {
+		t.Fatalf("expected the queue to be drained got %v", err)
+	}
+}
+
+func TestResizeBroadcast(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	p.Subscribe(server)
+	defer p.Unsubscribe(server)
+
+	if err := p.Resize(0, 80); err == nil {
+		t.Fatal("expected an error for an empty terminal")
+	}
+	if err := p.Resize(50, 132); err != nil {
+		t.Fatal(err)
+	}
+	if rows, cols := p.Size(); rows != 50 || cols != 132 {
+		t.Fatalf("expected the screen to be 50x132 got %dx%d", rows, cols)
+	}
+
+	// skip the size and keyframe sent on subscribe
+	tracker := utils.NewSyncTracker()
+	for i := 0; i < 2; i++ {
+		if _, err := utils.ReadFull(context.Background(), client, tracker); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := utils.ReadFull(context.Background(), client, tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := base.DecodePayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := payload.GetResize(); size.GetRows() != 50 || size.GetCols() != 132 {
+		t.Fatalf("expected a resize to 50x132 got %v", payload)
+	}
+}
+
+func TestPauseStream(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(defaultRows, defaultCols),
+		policy: DropOldest,
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	p.Subscribe(server)
+	defer p.Unsubscribe(server)
+
+	tracker := utils.NewSyncTracker()
+	next := func() *base.Payload {
+		t.Helper()
+		data, err := utils.ReadFull(context.Background(), client, tracker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := base.DecodePayload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+
+	// the size and keyframe sent on subscribe
+	next()
+	next()
+
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Paused() {
+		t.Fatal("expected the stream to be paused")
+	}
+	p.broadcast([]byte("secret stuff"))
+
+	if got := next().GetInfo().GetInfoType(); got != info.Info_INFO_STREAM_PAUSED {
+		t.Fatalf("expected the stream paused message got %v", got)
+	}
+
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	p.broadcast([]byte(" and more"))
+
+	// nothing from while it was paused should come through before this
+	if got := next().GetInfo().GetInfoType(); got != info.Info_INFO_STREAM_RESUMED {
+		t.Fatalf("expected the stream resumed message got %v", got)
+	}
+	content := next().GetTermContent()
+	if !content.GetKeyframe() {
+		t.Fatal("expected a keyframe after resuming")
+	}
+	if !strings.Contains(string(content.GetData()), "secret stuff") {
+		t.Fatalf("keyframe is missing what happened while paused: %q", content.GetData())
+	}
+
+	content = next().GetTermContent()
+	if content.GetKeyframe() || string(content.GetData()) != " and more" {
+		t.Fatalf("expected the output after resuming got %v", content)
+	}
+	if content.GetSequence() != 1 {
+		t.Fatalf("expected the paused output not to use up sequence numbers got %d", content.GetSequence())
+	}
+}
+
+func TestScrollback(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(2, 20),
+		policy: DropOldest,
+	}
+
+	for i := 1; i <= 5; i++ {
+		p.broadcast([]byte(fmt.Sprintf("line %d\r\n", i)))
+	}
+
+	// lines 1 to 4 have scrolled off the 2 line screen
+	lines, first, total := p.LastScrollback(2)
+	if first != 3 || total != 4 || len(lines) != 2 || string(lines[1]) != "line 4" {
+		t.Fatalf("expected lines 3-4 of 4 got %q from %d of %d", lines, first, total)
+	}
+
+	lines, first, _ = p.Scrollback(2, 3)
+	if first != 2 || len(lines) != 2 || string(lines[0]) != "line 2" {
+		t.Fatalf("expected lines 2-3 got %q from %d", lines, first)
+	}
+
+	p.SetScrollback(2)
+	lines, first, _ = p.Scrollback(1, 0)
+	if first != 3 || len(lines) != 2 {
+		t.Fatalf("expected only the newest 2 lines to be kept got %q from %d", lines, first)
+	}
+
+	p.Pause()
+	if lines, _, _ := p.Scrollback(1, 0); len(lines) != 0 {
+		t.Fatalf("expected nothing while the stream is paused got %q", lines)
+	}
+}
+
+func TestScrollbackWhilePaused(t *testing.T) {
+	p := &Pipeline{
+		ring:   newFrameRing(maxRingFrames),
+		screen: newVTerm(2, 20),
+		policy: DropOldest,
+	}
+
+	p.broadcast([]byte("before 1\r\nbefore 2\r\n"))
+	p.Pause()
+	for i := 1; i <= 5; i++ {
+		p.broadcast([]byte(fmt.Sprintf("secret %d\r\n", i)))
+	}
+	p.Resume()
+	p.broadcast([]byte("after 1\r\nafter 2\r\nafter 3\r\n"))
+
+	lines, first, total := p.Scrollback(1, 0)
+	var got []string
+	for _, line := range lines {
+		got = append(got, string(line))
+	}
+	// secret 5 was still on the screen when the stream resumed so everyone saw
+	// it in the keyframe
+	want := []string{"before 1", withheldLine, "secret 5", "after 1", "after 2"}
+	if first != 1 || total != uint64(len(want)) || strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q from 1 of %d got %q from %d of %d", want, len(want), got, first, total)
+	}
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import "os"
+
+// SetWindow sets the session window the pipeline's frames are marked with so
+// clients can tell the windows of a session apart
+func (p *Pipeline) SetWindow(id uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.window = id
+}
+
+// Window returns the session window the pipeline is streaming
+func (p *Pipeline) Window() uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.window
+}
+
+// HideLocally stops the pty's output from being shown on the host's screen
+// while another window is in front
+func (p *Pipeline) HideLocally() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hidden = true
+}
+
+// ShowLocally puts the pty's output back on the host's screen starting with a
+// redraw of what's on it now
+func (p *Pipeline) ShowLocally() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hidden = false
+	if p.screen != nil {
+		os.Stdout.Write(p.screen.keyframe())
+	}
+}
+
+func (p *Pipeline) shownLocally() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.hidden
+}
@@ -0,0 +1,324 @@
+package pipeline
+
+import "unicode/utf8"
+
+// the parser follows the state machine for DEC compatible terminals described at
+// https://vt100.net/emu/dec_ansi_parser with utf-8 decoding bolted onto the
+// ground state. it doesn't know what any of the sequences mean; it only splits
+// the stream up and hands the pieces to a vtHandler
+type parserState int
+
+const (
+	stateGround parserState = iota + 1
+	stateEscape
+	stateEscapeIntermediate
+	stateCsiEntry
+	stateCsiParam
+	stateCsiIntermediate
+	stateCsiIgnore
+	stateOscString
+	stateDcsString
+	stateIgnoreString
+)
+
+const (
+	maxParams       = 32
+	maxIntermediate = 4
+	maxOscLen       = 4096
+)
+
+// vtHandler is whatever wants to act on the pieces the parser splits the stream into
+type vtHandler interface {
+	// print is called for every printable character
+	print(r rune)
+	// execute is called for C0 control characters like CR and LF
+	execute(b byte)
+	// csiDispatch is called for a complete control sequence; private holds a
+	// leading '?', '>', '<' or '=' if there was one. missing params are -1
+	csiDispatch(params []int, intermediates []byte, private byte, final byte)
+	// escDispatch is called for a complete escape sequence
+	escDispatch(intermediates []byte, final byte)
+	// oscDispatch is called with the contents of an operating system command
+	oscDispatch(data []byte)
+}
+
+type vtParser struct {
+	handler       vtHandler
+	state         parserState
+	params        []int
+	curParam      int
+	hasParam      bool
+	intermediates []byte
+	private       byte
+	osc           []byte
+	// set when the last byte of a string was an ESC which may be the start of ST
+	stringEsc bool
+	// bytes of a utf-8 sequence that was split across writes
+	utf8Buf []byte
+}
+
+func newVTParser(handler vtHandler) *vtParser {
+	return &vtParser{
+		handler:       handler,
+		state:         stateGround,
+		params:        make([]int, 0, maxParams),
+		intermediates: make([]byte, 0, maxIntermediate),
+	}
+}
+
+// parse feeds data through the state machine. sequences split across calls are
+// picked up where they were left off
+func (p *vtParser) parse(data []byte) {
+	for len(data) > 0 {
+		if p.state == stateGround && len(p.utf8Buf) == 0 && data[0] >= utf8.RuneSelf {
+			data = p.parseUTF8(data)
+			continue
+		}
+
+		if len(p.utf8Buf) > 0 {
+			data = p.parseUTF8(data)
+			continue
+		}
+
+		p.advance(data[0])
+		data = data[1:]
+	}
+}
+
+// parseUTF8 decodes a single multi-byte character, holding on to it if it was
+// cut off at the end of the data
+func (p *vtParser) parseUTF8(data []byte) []byte {
+	if len(p.utf8Buf) == 0 {
+		r, size := utf8.DecodeRune(data)
+		if r != utf8.RuneError || size > 1 {
+			p.handler.print(r)
+			return data[size:]
+		}
+
+		if !utf8.FullRune(data) {
+			// the rest of the character is coming in the next write
+			p.utf8Buf = append(p.utf8Buf, data...)
+			return nil
+		}
+
+		p.handler.print(utf8.RuneError)
+		return data[1:]
+	}
+
+	// carry on with a character we started on in an earlier write
+	for len(data) > 0 && !utf8.FullRune(p.utf8Buf) {
+		if data[0] < 0x80 || data[0] >= 0xc0 {
+			// not a continuation byte so what we had was broken
+			p.utf8Buf = p.utf8Buf[:0]
+			p.handler.print(utf8.RuneError)
+			return data
+		}
+		p.utf8Buf = append(p.utf8Buf, data[0])
+		data = data[1:]
+	}
+
+	if utf8.FullRune(p.utf8Buf) {
+		r, _ := utf8.DecodeRune(p.utf8Buf)
+		p.utf8Buf = p.utf8Buf[:0]
+		p.handler.print(r)
+	}
+
+	return data
+}
+
+func (p *vtParser) clear() {
+	p.params = p.params[:0]
+	p.curParam = 0
+	p.hasParam = false
+	p.intermediates = p.intermediates[:0]
+	p.private = 0
+}
+
+func (p *vtParser) advance(b byte) {
+	// these are handled the same way no matter where we are
+	switch {
+	case b == 0x18 || b == 0x1a:
+		// CAN and SUB abort whatever sequence we're in
+		p.stringEsc = false
+		p.state = stateGround
+		return
+	case b == ESC && !p.inString():
+		p.clear()
+		p.state = stateEscape
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		if b < 0x20 || b == 0x7f {
+			p.execute(b)
+			return
+		}
+		p.handler.print(rune(b))
+
+	case stateEscape:
+		switch {
+		case b < 0x20:
+			p.execute(b)
+		case b >= 0x20 && b <= 0x2f:
+			p.collect(b)
+			p.state = stateEscapeIntermediate
+		case b == '[':
+			p.clear()
+			p.state = stateCsiEntry
+		case b == ']':
+			p.osc = p.osc[:0]
+			p.state = stateOscString
+		case b == 'P':
+			p.state = stateDcsString
+		case b == 'X' || b == '^' || b == '_':
+			p.state = stateIgnoreString
+		case b == 0x7f:
+		default:
+			p.handler.escDispatch(p.intermediates, b)
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case b < 0x20:
+			p.execute(b)
+		case b >= 0x20 && b <= 0x2f:
+			p.collect(b)
+		case b == 0x7f:
+		default:
+			p.handler.escDispatch(p.intermediates, b)
+			p.state = stateGround
+		}
+
+	case stateCsiEntry, stateCsiParam:
+		switch {
+		case b < 0x20:
+			p.execute(b)
+		case b >= '0' && b <= '9':
+			p.curParam = p.curParam*10 + int(b-'0')
+			if p.curParam > 65535 {
+				p.curParam = 65535
+			}
+			p.hasParam = true
+			p.state = stateCsiParam
+		case b == ';' || b == ':':
+			p.pushParam()
+			p.state = stateCsiParam
+		case b >= '<' && b <= '?':
+			if p.state != stateCsiEntry {
+				p.state = stateCsiIgnore
+				return
+			}
+			p.private = b
+			p.state = stateCsiParam
+		case b >= 0x20 && b <= 0x2f:
+			p.collect(b)
+			p.state = stateCsiIntermediate
+		case b >= 0x40 && b <= 0x7e:
+			p.dispatchCsi(b)
+		}
+
+	case stateCsiIntermediate:
+		switch {
+		case b < 0x20:
+			p.execute(b)
+		case b >= 0x20 && b <= 0x2f:
+			p.collect(b)
+		case b >= 0x30 && b <= 0x3f:
+			p.state = stateCsiIgnore
+		case b >= 0x40 && b <= 0x7e:
+			p.dispatchCsi(b)
+		}
+
+	case stateCsiIgnore:
+		switch {
+		case b < 0x20:
+			p.execute(b)
+		case b >= 0x40 && b <= 0x7e:
+			p.state = stateGround
+		}
+
+	case stateOscString:
+		if p.stringEsc {
+			p.stringEsc = false
+			if b == '\\' {
+				p.handler.oscDispatch(p.osc)
+				p.state = stateGround
+				return
+			}
+			// a lone ESC in the middle of a string starts a new sequence
+			p.clear()
+			p.state = stateEscape
+			p.advance(b)
+			return
+		}
+		switch {
+		case b == BEL:
+			p.handler.oscDispatch(p.osc)
+			p.state = stateGround
+		case b == ESC:
+			p.stringEsc = true
+		case b < 0x20:
+		default:
+			if len(p.osc) < maxOscLen {
+				p.osc = append(p.osc, b)
+			}
+		}
+
+	case stateDcsString, stateIgnoreString:
+		// we don't do anything with these so just wait for the terminator
+		if p.stringEsc {
+			p.stringEsc = false
+			if b == '\\' {
+				p.state = stateGround
+				return
+			}
+			p.clear()
+			p.state = stateEscape
+			p.advance(b)
+			return
+		}
+		if b == ESC {
+			p.stringEsc = true
+		} else if b == BEL && p.state == stateIgnoreString {
+			p.state = stateGround
+		}
+	}
+}
+
+func (p *vtParser) inString() bool {
+	return p.state == stateOscString || p.state == stateDcsString || p.state == stateIgnoreString
+}
+
+func (p *vtParser) execute(b byte) {
+	p.handler.execute(b)
+}
+
+func (p *vtParser) collect(b byte) {
+	if len(p.intermediates) < maxIntermediate {
+		p.intermediates = append(p.intermediates, b)
+	}
+}
+
+func (p *vtParser) pushParam() {
+	if len(p.params) >= maxParams {
+		return
+	}
+
+	if p.hasParam {
+		p.params = append(p.params, p.curParam)
+	} else {
+		p.params = append(p.params, -1)
+	}
+	p.curParam = 0
+	p.hasParam = false
+}
+
+func (p *vtParser) dispatchCsi(final byte) {
+	if p.hasParam || len(p.params) > 0 {
+		p.pushParam()
+	}
+	p.handler.csiDispatch(p.params, p.intermediates, p.private, final)
+	p.state = stateGround
+}
@@ -0,0 +1,843 @@
+package pipeline
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	defaultRows = 24
+	defaultCols = 80
+	tabWidth    = 8
+)
+
+// attribute flags for a cell
+const (
+	attrBold uint16 = 1 << iota
+	attrFaint
+	attrItalic
+	attrUnderline
+	attrBlink
+	attrInverse
+	attrHidden
+	attrStrike
+)
+
+// the ways a colour can be set; colorDefault is the terminal's own colour
+const (
+	colorDefault uint8 = iota
+	colorIndexed
+	colorRGB
+)
+
+type color struct {
+	mode  uint8
+	value uint32
+}
+
+type cellAttr struct {
+	fg    color
+	bg    color
+	flags uint16
+}
+
+// cell is a single character on the screen. ch is empty for a blank cell and
+// width is 0 for the right half of a wide character
+type cell struct {
+	ch    string
+	width int8
+	attr  cellAttr
+}
+
+func blankCell(attr cellAttr) cell {
+	// erased cells keep the background colour but nothing else
+	return cell{width: 1, attr: cellAttr{bg: attr.bg}}
+}
+
+type cursor struct {
+	row, col int
+	attr     cellAttr
+	// set after writing to the last column; the next character wraps first
+	pendingWrap bool
+	origin      bool
+	charsets    [2]byte
+	charset     int
+}
+
+// screenBuffer is one of the two screens a terminal has
+type screenBuffer struct {
+	lines [][]cell
+	saved cursor
+}
+
+func newScreenBuffer(rows, cols int) *screenBuffer {
+	b := &screenBuffer{lines: make([][]cell, rows)}
+	for i := range b.lines {
+		b.lines[i] = newLine(cols, cellAttr{})
+	}
+	b.saved = cursor{charsets: [2]byte{'B', 'B'}}
+	return b
+}
+
+func newLine(cols int, attr cellAttr) []cell {
+	line := make([]cell, cols)
+	for i := range line {
+		line[i] = blankCell(attr)
+	}
+	return line
+}
+
+// vterm is a virtual terminal that keeps track of what the screen of a terminal
+// fed the same output would look like. it's not safe for concurrent use; the
+// pipeline only touches it with its lock held
+type vterm struct {
+	rows, cols int
+	primary    *screenBuffer
+	alternate  *screenBuffer
+	screen     *screenBuffer
+	altActive  bool
+	cur        cursor
+	top        int
+	bottom     int
+	tabs       []bool
+	title      string
+	// private modes we don't act on but need to pass on to late joiners
+	modes map[int]bool
+	// DECAWM, DECTCEM and IRM
+	autowrap      bool
+	cursorVisible bool
+	insert        bool
+	lastPrinted   rune
+	parser        *vtParser
+	// lines that scrolled off the top of the primary screen
+	history *scrollback
+}
+
+// modes that are replayed as-is in a keyframe
+var passthroughModes = []int{
+	1,    // application cursor keys
+	1000, // mouse click tracking
+	1002, // mouse drag tracking
+	1003, // all mouse motion tracking
+	1004, // focus events
+	1005, // utf-8 mouse encoding
+	1006, // sgr mouse encoding
+	1015, // urxvt mouse encoding
+	2004, // bracketed paste
+}
+
+func newVTerm(rows, cols int) *vterm {
+	if rows <= 0 || cols <= 0 {
+		rows, cols = defaultRows, defaultCols
+	}
+
+	v := &vterm{}
+	v.reset(rows, cols)
+	v.parser = newVTParser(v)
+	v.history = newScrollback(DefaultScrollback)
+	return v
+}
+
+// reset puts the terminal back in the state it starts in
+func (v *vterm) reset(rows, cols int) {
+	v.rows, v.cols = rows, cols
+	v.primary = newScreenBuffer(rows, cols)
+	v.alternate = newScreenBuffer(rows, cols)
+	v.screen = v.primary
+	v.altActive = false
+	v.cur = cursor{charsets: [2]byte{'B', 'B'}}
+	v.top, v.bottom = 0, rows-1
+	v.title = ""
+	v.modes = make(map[int]bool)
+	v.autowrap = true
+	v.cursorVisible = true
+	v.insert = false
+	v.lastPrinted = 0
+	v.resetTabs()
+}
+
+// Write feeds pty output to the terminal
+func (v *vterm) Write(data []byte) (int, error) {
+	v.parser.parse(data)
+	return len(data), nil
+}
+
+func (v *vterm) resetTabs() {
+	v.tabs = make([]bool, v.cols)
+	for i := tabWidth; i < v.cols; i += tabWidth {
+		v.tabs[i] = true
+	}
+}
+
+// resize changes the size of both screens, keeping as much of what's on them as
+// will fit. like most terminals, rows are dropped off the top when shrinking so
+// that the cursor stays on screen
+func (v *vterm) resize(rows, cols int) {
+	if rows <= 0 || cols <= 0 || (rows == v.rows && cols == v.cols) {
+		return
+	}
+
+	for _, buf := range []*screenBuffer{v.primary, v.alternate} {
+		lines := buf.lines
+		// drop lines from the top if the cursor would end up off screen
+		if buf == v.screen && v.cur.row >= rows {
+			shift := v.cur.row - rows + 1
+			if buf == v.primary {
+				for _, line := range lines[:shift] {
+					v.history.push(line)
+				}
+			}
+			lines = lines[shift:]
+		}
+
+		resized := make([][]cell, rows)
+		for i := range resized {
+			resized[i] = newLine(cols, cellAttr{})
+			if i < len(lines) {
+				copy(resized[i], lines[i])
+				// don't leave half of a wide character on the edge
+				if last := resized[i][cols-1]; last.width == 2 {
+					resized[i][cols-1] = blankCell(last.attr)
+				}
+			}
+		}
+		buf.lines = resized
+		buf.saved.row = min(buf.saved.row, rows-1)
+		buf.saved.col = min(buf.saved.col, cols-1)
+	}
+
+	if v.cur.row >= rows {
+		v.cur.row = rows - 1
+	}
+	v.cur.col = min(v.cur.col, cols-1)
+	v.cur.pendingWrap = false
+	v.rows, v.cols = rows, cols
+	v.top, v.bottom = 0, rows-1
+	v.resetTabs()
+}
+
+func (v *vterm) print(r rune) {
+	r = v.translateCharset(r)
+
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		v.combine(r)
+		return
+	}
+	if width > v.cols {
+		return
+	}
+
+	if v.cur.pendingWrap {
+		v.cur.pendingWrap = false
+		if v.autowrap {
+			v.cur.col = 0
+			v.lineFeed()
+		}
+	}
+
+	// a wide character that doesn't fit wraps early
+	if v.cur.col+width > v.cols {
+		if !v.autowrap {
+			v.cur.col = v.cols - width
+		} else {
+			v.eraseCells(v.cur.row, v.cur.col, v.cols)
+			v.cur.col = 0
+			v.lineFeed()
+		}
+	}
+
+	line := v.screen.lines[v.cur.row]
+	if v.insert {
+		copy(line[v.cur.col+width:], line[v.cur.col:])
+	}
+
+	// a printed space is no different from a blank cell
+	ch := string(r)
+	if r == ' ' {
+		ch = ""
+	}
+
+	v.clearWide(v.cur.row, v.cur.col)
+	line[v.cur.col] = cell{ch: ch, width: int8(width), attr: v.cur.attr}
+	if width == 2 {
+		v.clearWide(v.cur.row, v.cur.col+1)
+		line[v.cur.col+1] = cell{width: 0, attr: v.cur.attr}
+	}
+	v.lastPrinted = r
+
+	if v.cur.col+width >= v.cols {
+		v.cur.col = v.cols - 1
+		v.cur.pendingWrap = true
+		return
+	}
+	v.cur.col += width
+}
+
+// combine attaches a zero width character to the one before the cursor
+func (v *vterm) combine(r rune) {
+	row, col := v.cur.row, v.cur.col
+	if !v.cur.pendingWrap {
+		col -= 1
+	}
+	if col < 0 {
+		return
+	}
+
+	line := v.screen.lines[row]
+	if line[col].width == 0 && col > 0 {
+		col -= 1
+	}
+	if line[col].ch == "" {
+		return
+	}
+	line[col].ch += string(r)
+}
+
+// clearWide blanks out the other half of a wide character at row, col so we
+// never end up with half of one on the screen
+func (v *vterm) clearWide(row, col int) {
+	line := v.screen.lines[row]
+	if col >= len(line) {
+		return
+	}
+
+	switch {
+	case line[col].width == 2 && col+1 < len(line):
+		line[col+1] = blankCell(line[col+1].attr)
+	case line[col].width == 0 && col > 0:
+		line[col-1] = blankCell(line[col-1].attr)
+	}
+}
+
+// dec special graphics; used by programs to draw boxes
+var decGraphics = map[rune]rune{
+	'`': '◆', 'a': '▒', 'f': '°', 'g': '±', 'j': '┘', 'k': '┐', 'l': '┌',
+	'm': '└', 'n': '┼', 'o': '⎺', 'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽',
+	't': '├', 'u': '┤', 'v': '┴', 'w': '┬', 'x': '│', 'y': '≤', 'z': '≥',
+	'{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+func (v *vterm) translateCharset(r rune) rune {
+	if v.cur.charsets[v.cur.charset] != '0' {
+		return r
+	}
+	if g, ok := decGraphics[r]; ok {
+		return g
+	}
+	return r
+}
+
+func (v *vterm) execute(b byte) {
+	switch b {
+	case '\b':
+		v.cur.pendingWrap = false
+		if v.cur.col > 0 {
+			v.cur.col -= 1
+		}
+	case '\t':
+		v.tabForward(1)
+	case '\n', '\v', '\f':
+		v.lineFeed()
+	case '\r':
+		v.cur.col = 0
+		v.cur.pendingWrap = false
+	case 0x0e: // SO
+		v.cur.charset = 1
+	case 0x0f: // SI
+		v.cur.charset = 0
+	}
+}
+
+// lineFeed moves the cursor down a line scrolling the region if it's at the bottom
+func (v *vterm) lineFeed() {
+	v.cur.pendingWrap = false
+	if v.cur.row == v.bottom {
+		if v.screen == v.primary && v.top == 0 {
+			// only lines pushed off the top of the whole screen end up in the
+			// scrollback like in a real terminal
+			v.history.push(v.screen.lines[0])
+		}
+		v.scrollUp(1)
+		return
+	}
+	if v.cur.row < v.rows-1 {
+		v.cur.row += 1
+	}
+}
+
+func (v *vterm) reverseIndex() {
+	v.cur.pendingWrap = false
+	if v.cur.row == v.top {
+		v.scrollDown(1)
+		return
+	}
+	if v.cur.row > 0 {
+		v.cur.row -= 1
+	}
+}
+
+// scrollUp moves the lines in the scroll region up by n, adding blank lines at the bottom
+func (v *vterm) scrollUp(n int) {
+	n = min(n, v.bottom-v.top+1)
+	lines := v.screen.lines
+	copy(lines[v.top:], lines[v.top+n:v.bottom+1])
+	for i := v.bottom - n + 1; i <= v.bottom; i++ {
+		lines[i] = newLine(v.cols, v.cur.attr)
+	}
+}
+
+// scrollDown moves the lines in the scroll region down by n, adding blank lines at the top
+func (v *vterm) scrollDown(n int) {
+	n = min(n, v.bottom-v.top+1)
+	lines := v.screen.lines
+	copy(lines[v.top+n:v.bottom+1], lines[v.top:v.bottom+1-n])
+	for i := v.top; i < v.top+n; i++ {
+		lines[i] = newLine(v.cols, v.cur.attr)
+	}
+}
+
+func (v *vterm) tabForward(n int) {
+	for ; n > 0 && v.cur.col < v.cols-1; n-- {
+		v.cur.col += 1
+		for v.cur.col < v.cols-1 && !v.tabs[v.cur.col] {
+			v.cur.col += 1
+		}
+	}
+}
+
+func (v *vterm) tabBackward(n int) {
+	for ; n > 0 && v.cur.col > 0; n-- {
+		v.cur.col -= 1
+		for v.cur.col > 0 && !v.tabs[v.cur.col] {
+			v.cur.col -= 1
+		}
+	}
+}
+
+// eraseCells blanks the cells in [from, to) on the given row
+func (v *vterm) eraseCells(row, from, to int) {
+	from, to = max(from, 0), min(to, v.cols)
+	if from >= to {
+		return
+	}
+
+	v.clearWide(row, from)
+	v.clearWide(row, to-1)
+	line := v.screen.lines[row]
+	for i := from; i < to; i++ {
+		line[i] = blankCell(v.cur.attr)
+	}
+}
+
+func (v *vterm) eraseLines(from, to int) {
+	for row := max(from, 0); row < min(to, v.rows); row++ {
+		v.screen.lines[row] = newLine(v.cols, v.cur.attr)
+	}
+}
+
+// moveTo puts the cursor at row, col clamping it to the screen or the scroll
+// region when origin mode is on
+func (v *vterm) moveTo(row, col int) {
+	minRow, maxRow := 0, v.rows-1
+	if v.cur.origin {
+		row += v.top
+		minRow, maxRow = v.top, v.bottom
+	}
+
+	v.cur.row = min(max(row, minRow), maxRow)
+	v.cur.col = min(max(col, 0), v.cols-1)
+	v.cur.pendingWrap = false
+}
+
+// relative moves stay inside the scroll region if the cursor started in it
+func (v *vterm) moveRows(n int) {
+	row := v.cur.row + n
+	if v.cur.row >= v.top && v.cur.row <= v.bottom {
+		row = min(max(row, v.top), v.bottom)
+	}
+	v.cur.row = min(max(row, 0), v.rows-1)
+	v.cur.pendingWrap = false
+}
+
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] <= 0 {
+		return def
+	}
+	return params[i]
+}
+
+func (v *vterm) csiDispatch(params []int, intermediates []byte, private byte, final byte) {
+	if len(intermediates) > 0 {
+		// things like DECSCUSR (CSI Ps SP q) don't change what's on screen
+		return
+	}
+
+	if private == '?' {
+		switch final {
+		case 'h':
+			v.setPrivateModes(params, true)
+		case 'l':
+			v.setPrivateModes(params, false)
+		}
+		return
+	}
+	if private != 0 {
+		return
+	}
+
+	n := param(params, 0, 1)
+	switch final {
+	case '@': // ICH
+		v.cur.pendingWrap = false
+		line := v.screen.lines[v.cur.row]
+		n = min(n, v.cols-v.cur.col)
+		v.clearWide(v.cur.row, v.cur.col)
+		copy(line[v.cur.col+n:], line[v.cur.col:])
+		for i := v.cur.col; i < v.cur.col+n; i++ {
+			line[i] = blankCell(v.cur.attr)
+		}
+	case 'A': // CUU
+		v.moveRows(-n)
+	case 'B', 'e': // CUD, VPR
+		v.moveRows(n)
+	case 'C', 'a': // CUF, HPR
+		v.cur.col = min(v.cur.col+n, v.cols-1)
+		v.cur.pendingWrap = false
+	case 'D': // CUB
+		v.cur.col = max(v.cur.col-n, 0)
+		v.cur.pendingWrap = false
+	case 'E': // CNL
+		v.moveRows(n)
+		v.cur.col = 0
+	case 'F': // CPL
+		v.moveRows(-n)
+		v.cur.col = 0
+	case 'G', '`': // CHA, HPA
+		v.cur.col = min(n-1, v.cols-1)
+		v.cur.pendingWrap = false
+	case 'H', 'f': // CUP, HVP
+		v.moveTo(param(params, 0, 1)-1, param(params, 1, 1)-1)
+	case 'I': // CHT
+		v.tabForward(n)
+	case 'J': // ED
+		v.eraseDisplay(param(params, 0, 0))
+	case 'K': // EL
+		v.eraseLine(param(params, 0, 0))
+	case 'L': // IL
+		v.insertLines(n)
+	case 'M': // DL
+		v.deleteLines(n)
+	case 'P': // DCH
+		v.cur.pendingWrap = false
+		line := v.screen.lines[v.cur.row]
+		n = min(n, v.cols-v.cur.col)
+		v.clearWide(v.cur.row, v.cur.col)
+		v.clearWide(v.cur.row, v.cur.col+n-1)
+		copy(line[v.cur.col:], line[v.cur.col+n:])
+		for i := v.cols - n; i < v.cols; i++ {
+			line[i] = blankCell(v.cur.attr)
+		}
+	case 'S': // SU
+		v.scrollUp(n)
+	case 'T': // SD
+		v.scrollDown(n)
+	case 'X': // ECH
+		v.cur.pendingWrap = false
+		v.eraseCells(v.cur.row, v.cur.col, v.cur.col+n)
+	case 'Z': // CBT
+		v.tabBackward(n)
+	case 'b': // REP
+		if v.lastPrinted != 0 {
+			for i := 0; i < min(n, v.rows*v.cols); i++ {
+				v.print(v.lastPrinted)
+			}
+		}
+	case 'd': // VPA
+		v.moveTo(n-1, v.cur.col)
+	case 'g': // TBC
+		switch param(params, 0, 0) {
+		case 0:
+			v.tabs[v.cur.col] = false
+		case 3:
+			v.tabs = make([]bool, v.cols)
+		}
+	case 'h', 'l': // SM, RM
+		for _, mode := range params {
+			if mode == 4 {
+				v.insert = final == 'h'
+			}
+		}
+	case 'm': // SGR
+		v.setGraphics(params)
+	case 'r': // DECSTBM
+		top := param(params, 0, 1) - 1
+		bottom := param(params, 1, v.rows) - 1
+		if bottom >= v.rows {
+			bottom = v.rows - 1
+		}
+		if top < bottom {
+			v.top, v.bottom = top, bottom
+			v.moveTo(0, 0)
+		}
+	case 's': // SCOSC
+		v.saveCursor()
+	case 'u': // SCORC
+		v.restoreCursor()
+	}
+}
+
+func (v *vterm) eraseDisplay(mode int) {
+	v.cur.pendingWrap = false
+	switch mode {
+	case 0:
+		v.eraseCells(v.cur.row, v.cur.col, v.cols)
+		v.eraseLines(v.cur.row+1, v.rows)
+	case 1:
+		v.eraseLines(0, v.cur.row)
+		v.eraseCells(v.cur.row, 0, v.cur.col+1)
+	case 2, 3:
+		v.eraseLines(0, v.rows)
+	}
+}
+
+func (v *vterm) eraseLine(mode int) {
+	v.cur.pendingWrap = false
+	switch mode {
+	case 0:
+		v.eraseCells(v.cur.row, v.cur.col, v.cols)
+	case 1:
+		v.eraseCells(v.cur.row, 0, v.cur.col+1)
+	case 2:
+		v.eraseCells(v.cur.row, 0, v.cols)
+	}
+}
+
+func (v *vterm) insertLines(n int) {
+	if v.cur.row < v.top || v.cur.row > v.bottom {
+		return
+	}
+	top := v.top
+	v.top = v.cur.row
+	v.scrollDown(n)
+	v.top = top
+	v.cur.col = 0
+	v.cur.pendingWrap = false
+}
+
+func (v *vterm) deleteLines(n int) {
+	if v.cur.row < v.top || v.cur.row > v.bottom {
+		return
+	}
+	top := v.top
+	v.top = v.cur.row
+	v.scrollUp(n)
+	v.top = top
+	v.cur.col = 0
+	v.cur.pendingWrap = false
+}
+
+func (v *vterm) setPrivateModes(params []int, set bool) {
+	for _, mode := range params {
+		switch mode {
+		case 6: // DECOM
+			v.cur.origin = set
+			v.moveTo(0, 0)
+		case 7: // DECAWM
+			v.autowrap = set
+		case 25: // DECTCEM
+			v.cursorVisible = set
+		case 47, 1047:
+			v.switchScreen(set, false)
+		case 1048:
+			if set {
+				v.saveCursor()
+			} else {
+				v.restoreCursor()
+			}
+		case 1049:
+			if set {
+				v.saveCursor()
+				v.switchScreen(true, true)
+			} else {
+				v.switchScreen(false, false)
+				v.restoreCursor()
+			}
+		default:
+			for _, m := range passthroughModes {
+				if m == mode {
+					v.modes[mode] = set
+				}
+			}
+		}
+	}
+}
+
+func (v *vterm) switchScreen(alt, clear bool) {
+	if alt == v.altActive {
+		return
+	}
+
+	v.altActive = alt
+	if alt {
+		v.screen = v.alternate
+		if clear {
+			v.eraseLines(0, v.rows)
+		}
+		return
+	}
+	v.screen = v.primary
+}
+
+func (v *vterm) saveCursor() {
+	v.screen.saved = v.cur
+}
+
+func (v *vterm) restoreCursor() {
+	v.cur = v.screen.saved
+	v.cur.row = min(v.cur.row, v.rows-1)
+	v.cur.col = min(v.cur.col, v.cols-1)
+}
+
+func (v *vterm) escDispatch(intermediates []byte, final byte) {
+	if len(intermediates) > 0 {
+		switch intermediates[0] {
+		case '(':
+			v.cur.charsets[0] = final
+		case ')':
+			v.cur.charsets[1] = final
+		case '#':
+			if final == '8' { // DECALN
+				for row := range v.screen.lines {
+					for col := range v.screen.lines[row] {
+						v.screen.lines[row][col] = cell{ch: "E", width: 1}
+					}
+				}
+			}
+		}
+		return
+	}
+
+	switch final {
+	case '7': // DECSC
+		v.saveCursor()
+	case '8': // DECRC
+		v.restoreCursor()
+	case 'D': // IND
+		v.lineFeed()
+	case 'E': // NEL
+		v.cur.col = 0
+		v.lineFeed()
+	case 'H': // HTS
+		v.tabs[v.cur.col] = true
+	case 'M': // RI
+		v.reverseIndex()
+	case 'c': // RIS
+		v.reset(v.rows, v.cols)
+	}
+}
+
+func (v *vterm) oscDispatch(data []byte) {
+	cmd, value, ok := strings.Cut(string(data), ";")
+	if !ok {
+		return
+	}
+
+	// we only care about the window title; everything else is either a
+	// query or something the client's terminal doesn't need to know
+	if cmd == "0" || cmd == "2" {
+		v.title = value
+	}
+}
+
+// setGraphics applies an SGR sequence to the current attributes
+func (v *vterm) setGraphics(params []int) {
+	if len(params) == 0 {
+		v.cur.attr = cellAttr{}
+		return
+	}
+
+	attr := &v.cur.attr
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p <= 0:
+			*attr = cellAttr{}
+		case p == 1:
+			attr.flags |= attrBold
+		case p == 2:
+			attr.flags |= attrFaint
+		case p == 3:
+			attr.flags |= attrItalic
+		case p == 4:
+			attr.flags |= attrUnderline
+		case p == 5 || p == 6:
+			attr.flags |= attrBlink
+		case p == 7:
+			attr.flags |= attrInverse
+		case p == 8:
+			attr.flags |= attrHidden
+		case p == 9:
+			attr.flags |= attrStrike
+		case p == 21 || p == 22:
+			attr.flags &^= attrBold | attrFaint
+		case p == 23:
+			attr.flags &^= attrItalic
+		case p == 24:
+			attr.flags &^= attrUnderline
+		case p == 25:
+			attr.flags &^= attrBlink
+		case p == 27:
+			attr.flags &^= attrInverse
+		case p == 28:
+			attr.flags &^= attrHidden
+		case p == 29:
+			attr.flags &^= attrStrike
+		case p >= 30 && p <= 37:
+			attr.fg = color{mode: colorIndexed, value: uint32(p - 30)}
+		case p == 38:
+			var skip int
+			attr.fg, skip = extendedColor(params[i+1:])
+			i += skip
+		case p == 39:
+			attr.fg = color{}
+		case p >= 40 && p <= 47:
+			attr.bg = color{mode: colorIndexed, value: uint32(p - 40)}
+		case p == 48:
+			var skip int
+			attr.bg, skip = extendedColor(params[i+1:])
+			i += skip
+		case p == 49:
+			attr.bg = color{}
+		case p >= 90 && p <= 97:
+			attr.fg = color{mode: colorIndexed, value: uint32(p - 90 + 8)}
+		case p >= 100 && p <= 107:
+			attr.bg = color{mode: colorIndexed, value: uint32(p - 100 + 8)}
+		}
+	}
+}
+
+// extendedColor reads a 256 colour or truecolour spec that followed a 38 or 48
+// and returns how many params it used up
+func extendedColor(params []int) (color, int) {
+	if len(params) == 0 {
+		return color{}, 0
+	}
+
+	switch params[0] {
+	case 5:
+		if len(params) < 2 {
+			return color{}, len(params)
+		}
+		return color{mode: colorIndexed, value: uint32(max(params[1], 0) & 0xff)}, 2
+	case 2:
+		if len(params) < 4 {
+			return color{}, len(params)
+		}
+		r, g, b := max(params[1], 0)&0xff, max(params[2], 0)&0xff, max(params[3], 0)&0xff
+		return color{mode: colorRGB, value: uint32(r<<16 | g<<8 | b)}, 4
+	}
+
+	return color{}, 1
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// keyframe serializes the current state of the terminal as escape sequences
+// that bring any terminal they're written to into the same state. this is what
+// late joiners get so they don't have to wait for a full redraw
+func (v *vterm) keyframe() []byte {
+	var buf bytes.Buffer
+
+	// start from a clean slate on the primary screen with no scroll region
+	buf.WriteString("\x1b[?1049l\x1b(B\x1b)B\x0f\x1b[0m\x1b[r\x1b[H\x1b[2J")
+	v.writeScreen(&buf, v.primary)
+
+	if v.altActive {
+		// 1049 clears the alternate screen on the way in
+		buf.WriteString("\x1b[?1049h\x1b[0m\x1b[H")
+		v.writeScreen(&buf, v.alternate)
+	}
+
+	if v.top != 0 || v.bottom != v.rows-1 {
+		fmt.Fprintf(&buf, "\x1b[%d;%dr", v.top+1, v.bottom+1)
+	}
+
+	// modes that aren't set get turned off in case an earlier stream left them on
+	for _, mode := range passthroughModes {
+		if v.modes[mode] {
+			fmt.Fprintf(&buf, "\x1b[?%dh", mode)
+		} else {
+			fmt.Fprintf(&buf, "\x1b[?%dl", mode)
+		}
+	}
+	if v.autowrap {
+		buf.WriteString("\x1b[?7h")
+	} else {
+		buf.WriteString("\x1b[?7l")
+	}
+	if v.insert {
+		buf.WriteString("\x1b[4h")
+	} else {
+		buf.WriteString("\x1b[4l")
+	}
+	if v.title != "" {
+		fmt.Fprintf(&buf, "\x1b]2;%s\x07", v.title)
+	}
+	if v.cur.charsets[1] == '0' {
+		buf.WriteString("\x1b)0")
+	}
+	if v.cur.charsets[0] == '0' {
+		buf.WriteString("\x1b(0")
+	}
+	if v.cur.charset == 1 {
+		buf.WriteByte(0x0e)
+	}
+
+	fmt.Fprintf(&buf, "\x1b[%d;%dH", v.cur.row+1, v.cur.col+1)
+	buf.WriteString(sgr(v.cur.attr))
+	if v.cursorVisible {
+		buf.WriteString("\x1b[?25h")
+	} else {
+		buf.WriteString("\x1b[?25l")
+	}
+
+	return buf.Bytes()
+}
+
+// writeScreen draws every line of the screen. blank cells at the end of a line
+// are skipped since the screen was just cleared
+func (v *vterm) writeScreen(buf *bytes.Buffer, screen *screenBuffer) {
+	current := cellAttr{}
+	for row, line := range screen.lines {
+		if lineEnd(line) < 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\x1b[%d;1H", row+1)
+		current = writeLine(buf, line, current)
+	}
+
+	if current != (cellAttr{}) {
+		buf.WriteString("\x1b[0m")
+	}
+}
+
+// lineEnd returns the column of the last cell in line that isn't blank or -1
+// if they all are
+func lineEnd(line []cell) int {
+	last := len(line) - 1
+	for last >= 0 && line[last].ch == "" && line[last].attr == (cellAttr{}) {
+		last -= 1
+	}
+	return last
+}
+
+// writeLine draws line up to its last cell that isn't blank starting with the
+// attributes in current and returns the ones it ends with
+func writeLine(buf *bytes.Buffer, line []cell, current cellAttr) cellAttr {
+	last := lineEnd(line)
+	for col := 0; col <= last; col++ {
+		c := line[col]
+		if c.width == 0 {
+			// right half of a wide character which was already written
+			continue
+		}
+
+		if c.attr != current {
+			buf.WriteString(sgr(c.attr))
+			current = c.attr
+		}
+		if c.ch == "" {
+			buf.WriteByte(' ')
+			continue
+		}
+		buf.WriteString(c.ch)
+	}
+
+	return current
+}
+
+// sgr returns the escape sequence that sets exactly the given attributes
+func sgr(attr cellAttr) string {
+	params := []string{"0"}
+
+	flags := []struct {
+		flag  uint16
+		param string
+	}{
+		{attrBold, "1"}, {attrFaint, "2"}, {attrItalic, "3"}, {attrUnderline, "4"},
+		{attrBlink, "5"}, {attrInverse, "7"}, {attrHidden, "8"}, {attrStrike, "9"},
+	}
+	for _, f := range flags {
+		if attr.flags&f.flag != 0 {
+			params = append(params, f.param)
+		}
+	}
+
+	params = append(params, colorParams(attr.fg, 30, 90, "38")...)
+	params = append(params, colorParams(attr.bg, 40, 100, "48")...)
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[")
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteByte(';')
+		}
+		buf.WriteString(p)
+	}
+	buf.WriteByte('m')
+	return buf.String()
+}
+
+func colorParams(c color, base, brightBase int, extended string) []string {
+	switch c.mode {
+	case colorIndexed:
+		if c.value < 8 {
+			return []string{strconv.Itoa(base + int(c.value))}
+		}
+		if c.value < 16 {
+			return []string{strconv.Itoa(brightBase + int(c.value) - 8)}
+		}
+		return []string{extended, "5", strconv.Itoa(int(c.value))}
+	case colorRGB:
+		return []string{
+			extended, "2",
+			strconv.Itoa(int(c.value >> 16 & 0xff)),
+			strconv.Itoa(int(c.value >> 8 & 0xff)),
+			strconv.Itoa(int(c.value & 0xff)),
+		}
+	}
+
+	return nil
+}
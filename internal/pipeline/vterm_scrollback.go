@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"bytes"
+)
+
+// DefaultScrollback is how many lines that scrolled off the screen a pipeline
+// keeps for clients to look back at
+const DefaultScrollback = 1000
+
+// what's kept in place of the lines that scrolled off while the stream was
+// paused
+const withheldLine = "[output hidden while the stream was paused]"
+
+// scrollback keeps the lines that have scrolled off the top of the primary
+// screen. they're kept already drawn as text and escape sequences so a line
+// costs about what it would in a terminal. once it's full the oldest line is
+// dropped
+type scrollback struct {
+	lines [][]byte
+	start int
+	size  int
+	// how many lines have ever scrolled off; lines are numbered from 1 in the
+	// order they did
+	total uint64
+	// set while the stream is paused. the lines that scroll off then aren't
+	// for anyone else's eyes so a single placeholder is kept in their place
+	withholding bool
+	withheld    bool
+}
+
+func newScrollback(capacity int) *scrollback {
+	return &scrollback{lines: make([][]byte, capacity)}
+}
+
+// push draws line and adds it as the newest line
+func (s *scrollback) push(line []cell) {
+	if s.withholding {
+		if !s.withheld {
+			s.withheld = true
+			s.add([]byte(withheldLine))
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	if writeLine(&buf, line, cellAttr{}) != (cellAttr{}) {
+		buf.WriteString("\x1b[0m")
+	}
+	s.add(buf.Bytes())
+}
+
+// add keeps an already drawn line as the newest one
+func (s *scrollback) add(line []byte) {
+	s.total += 1
+	if len(s.lines) == 0 {
+		return
+	}
+
+	end := (s.start + s.size) % len(s.lines)
+	s.lines[end] = line
+	if s.size < len(s.lines) {
+		s.size += 1
+		return
+	}
+	s.start = (s.start + 1) % len(s.lines)
+}
+
+// withhold starts or stops leaving out the lines that scroll off
+func (s *scrollback) withhold(on bool) {
+	s.withholding, s.withheld = on, false
+}
+
+// setCapacity changes how many lines are kept dropping the oldest ones if there
+// are too many for the new size
+func (s *scrollback) setCapacity(capacity int) {
+	keep := min(s.size, capacity)
+	lines := make([][]byte, capacity)
+	for i := 0; i < keep; i++ {
+		lines[i] = s.lines[(s.start+s.size-keep+i)%len(s.lines)]
+	}
+
+	s.lines, s.start, s.size = lines, 0, keep
+}
+
+// oldest returns the number of the oldest line still kept
+func (s *scrollback) oldest() uint64 {
+	return s.total - uint64(s.size) + 1
+}
+
+// between returns the lines numbered from to to inclusive that are still kept
+// along with the number of the first one
+func (s *scrollback) between(from, to uint64) ([][]byte, uint64) {
+	from = max(from, s.oldest())
+	to = min(to, s.total)
+	if s.size == 0 || from > to {
+		return nil, from
+	}
+
+	result := make([][]byte, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		i := (s.start + int(n-s.oldest())) % len(s.lines)
+		result = append(result, s.lines[i])
+	}
+
+	return result, from
+}
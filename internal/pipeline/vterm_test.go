@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lineText returns what's written on a row of the current screen with
+// trailing blanks trimmed
+func lineText(v *vterm, row int) string {
+	var b strings.Builder
+	for _, c := range v.screen.lines[row] {
+		if c.width == 0 {
+			continue
+		}
+		if c.ch == "" {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteString(c.ch)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func TestVTermPrint(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		rows  []string
+		row   int
+		col   int
+	}{
+		{
+			name:  "plain text with newlines",
+			input: "hello\r\nworld",
+			rows:  []string{"hello", "world"},
+			row:   1,
+			col:   5,
+		},
+		{
+			name:  "carriage return overwrites",
+			input: "hello\rj",
+			rows:  []string{"jello"},
+			row:   0,
+			col:   1,
+		},
+		{
+			name:  "backspace and erase to end of line",
+			input: "hello\b\b\x1b[K",
+			rows:  []string{"hel"},
+			row:   0,
+			col:   3,
+		},
+		{
+			name:  "cursor positioning",
+			input: "\x1b[2;3Hx\x1b[1;1Hy",
+			rows:  []string{"y", "  x"},
+			row:   0,
+			col:   1,
+		},
+		{
+			name:  "wrapping at the edge",
+			input: "abcdefghijk",
+			rows:  []string{"abcdefghij", "k"},
+			row:   1,
+			col:   1,
+		},
+		{
+			name:  "tabs",
+			input: "a\tb",
+			rows:  []string{"a       b"},
+			row:   0,
+			col:   9,
+		},
+		{
+			name:  "insert and delete characters",
+			input: "abcdef\x1b[1;2H\x1b[2P\x1b[1@",
+			rows:  []string{"a def"},
+			row:   0,
+			col:   1,
+		},
+		{
+			name:  "wide characters",
+			input: "你好",
+			rows:  []string{"你好"},
+			row:   0,
+			col:   4,
+		},
+		{
+			name:  "line drawing charset",
+			input: "\x1b(0lqk\x1b(B",
+			rows:  []string{"┌─┐"},
+			row:   0,
+			col:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newVTerm(4, 10)
+			v.Write([]byte(tt.input))
+
+			for i, want := range tt.rows {
+				if got := lineText(v, i); got != want {
+					t.Errorf("row %d = %q, want %q", i, got, want)
+				}
+			}
+			if v.cur.row != tt.row || v.cur.col != tt.col {
+				t.Errorf("cursor at %d,%d want %d,%d", v.cur.row, v.cur.col, tt.row, tt.col)
+			}
+		})
+	}
+}
+
+func TestVTermScroll(t *testing.T) {
+	v := newVTerm(3, 10)
+	v.Write([]byte("one\r\ntwo\r\nthree\r\nfour"))
+
+	want := []string{"two", "three", "four"}
+	for i, w := range want {
+		if got := lineText(v, i); got != w {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+
+	// scrolling inside a region leaves the rest of the screen alone
+	v.Write([]byte("\x1b[2;3r\x1b[3;1H\nfive"))
+	want = []string{"two", "four", "five"}
+	for i, w := range want {
+		if got := lineText(v, i); got != w {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestVTermSplitWrites(t *testing.T) {
+	v := newVTerm(2, 20)
+	input := []byte("\x1b[31mred\x1b[0m 世界")
+	// feed the input a byte at a time to split every sequence and character
+	for _, b := range input {
+		v.Write([]byte{b})
+	}
+
+	if got := lineText(v, 0); got != "red 世界" {
+		t.Fatalf("row 0 = %q", got)
+	}
+	red := v.screen.lines[0][0].attr.fg
+	if red.mode != colorIndexed || red.value != 1 {
+		t.Fatalf("expected red foreground got %+v", red)
+	}
+	if v.screen.lines[0][3].attr != (cellAttr{}) {
+		t.Fatal("expected attributes to be reset after the red text")
+	}
+}
+
+func TestVTermAlternateScreen(t *testing.T) {
+	v := newVTerm(3, 10)
+	v.Write([]byte("shell$ "))
+	v.Write([]byte("\x1b[?1049h\x1b[Hvim"))
+
+	if !v.altActive || lineText(v, 0) != "vim" {
+		t.Fatalf("expected to be on the alternate screen showing vim got %q", lineText(v, 0))
+	}
+
+	v.Write([]byte("\x1b[?1049l"))
+	if v.altActive || lineText(v, 0) != "shell$" {
+		t.Fatalf("expected the shell back on the primary screen got %q", lineText(v, 0))
+	}
+	if v.cur.col != 7 {
+		t.Fatalf("expected the cursor to be restored to column 7 got %d", v.cur.col)
+	}
+}
+
+func TestVTermKeyframe(t *testing.T) {
+	inputs := []string{
+		"plain\r\ntext",
+		"\x1b[1;31mbold red\x1b[0m \x1b[38;5;200mindexed\x1b[48;2;1;2;3m rgb \x1b[0m",
+		"prompt$ \x1b[?1049h\x1b[2;5Hin vim\x1b[?25l\x1b[?2004h",
+		"\x1b]2;my title\x07\x1b[2;4r\x1b[3;1Hregion",
+		"wide 你好 chars\x1b[4;1H\x1b(0lqqk",
+	}
+
+	for _, input := range inputs {
+		src := newVTerm(5, 20)
+		src.Write([]byte(input))
+
+		dst := newVTerm(5, 20)
+		// dirty the destination first to make sure the keyframe starts clean
+		dst.Write([]byte("garbage\x1b[?1000h\x1b[7m"))
+		dst.Write(src.keyframe())
+
+		if !reflect.DeepEqual(src.primary.lines, dst.primary.lines) {
+			t.Errorf("primary screens differ for %q", input)
+		}
+		if src.altActive != dst.altActive {
+			t.Errorf("alternate screen state differs for %q", input)
+		}
+		if src.altActive && !reflect.DeepEqual(src.alternate.lines, dst.alternate.lines) {
+			t.Errorf("alternate screens differ for %q", input)
+		}
+		if src.cur.row != dst.cur.row || src.cur.col != dst.cur.col {
+			t.Errorf("cursor differs for %q: %d,%d vs %d,%d",
+				input, src.cur.row, src.cur.col, dst.cur.row, dst.cur.col)
+		}
+		if src.cur.attr != dst.cur.attr {
+			t.Errorf("attributes differ for %q", input)
+		}
+		if src.top != dst.top || src.bottom != dst.bottom {
+			t.Errorf("scroll region differs for %q", input)
+		}
+		if src.cursorVisible != dst.cursorVisible || src.title != dst.title {
+			t.Errorf("cursor visibility or title differ for %q", input)
+		}
+		for _, mode := range passthroughModes {
+			if src.modes[mode] != dst.modes[mode] {
+				t.Errorf("mode %d differs for %q", mode, input)
+			}
+		}
+	}
+}
+
+func TestVTermResize(t *testing.T) {
+	v := newVTerm(3, 10)
+	v.Write([]byte("one\r\ntwo\r\nthree"))
+	v.resize(2, 4)
+
+	if lineText(v, 0) != "two" || lineText(v, 1) != "thre" {
+		t.Fatalf("unexpected screen after resize %q %q", lineText(v, 0), lineText(v, 1))
+	}
+	if v.cur.row != 1 || v.cur.col != 3 {
+		t.Fatalf("expected cursor clamped to 1,3 got %d,%d", v.cur.row, v.cur.col)
+	}
+}
+
+func TestVTermScrollback(t *testing.T) {
+	v := newVTerm(2, 10)
+	v.history.setCapacity(3)
+	v.Write([]byte("one\r\n\x1b[1mtwo\x1b[0m\r\nthree\r\nfour\r\nfive"))
+
+	if v.history.total != 3 {
+		t.Fatalf("expected 3 lines to have scrolled off got %d", v.history.total)
+	}
+	lines, first := v.history.between(1, 3)
+	if first != 1 || len(lines) != 3 {
+		t.Fatalf("expected lines 1-3 got %d from %d", len(lines), first)
+	}
+	if string(lines[0]) != "one" || string(lines[1]) != "\x1b[0;1mtwo\x1b[0m" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+
+	// the oldest line goes once it's full and the alternate screen doesn't
+	// count at all
+	v.Write([]byte("\r\nsix\x1b[?1049h\r\n\r\n\r\n\x1b[?1049l"))
+	lines, first = v.history.between(1, 10)
+	if first != 2 || len(lines) != 3 || string(lines[2]) != "four" {
+		t.Fatalf("expected lines 2-4 got %q from %d", lines, first)
+	}
+
+	// shrinking keeps the newest lines
+	v.history.setCapacity(1)
+	lines, first = v.history.between(1, 10)
+	if first != 4 || len(lines) != 1 {
+		t.Fatalf("expected only line 4 got %q from %d", lines, first)
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// deflate keeps back references to at most this much of what came before
+const deflateWindow = 1 << 15
+
+var (
+	// every sync flush ends in the same empty stored block so it's left off the
+	// wire and put back before inflating
+	deflateSyncTail = []byte{0x00, 0x00, 0xff, 0xff}
+	// an empty final block so the reader stops at the end of the frame
+	deflateFinalTail = []byte{0x01, 0x00, 0x00, 0xff, 0xff}
+)
+
+// Deflater compresses a stream of frames. the frames share one dictionary so
+// output that looks like what was sent a moment ago costs next to nothing,
+// which means they have to be inflated in the same order they were deflated
+type Deflater struct {
+	buf   bytes.Buffer
+	w     *flate.Writer
+	fresh bool
+}
+
+func NewDeflater() *Deflater {
+	d := &Deflater{fresh: true}
+	// the faster levels give up on frames as small as most terminal output
+	// and store them as they are. it's only ever a few kilobytes a second
+	// so the extra work doesn't matter. the level can't be wrong so the error
+	// never happens
+	d.w, _ = flate.NewWriter(&d.buf, flate.BestCompression)
+	return d
+}
+
+// Reset forgets every frame deflated so far
+func (d *Deflater) Reset() {
+	d.buf.Reset()
+	d.w.Reset(&d.buf)
+	d.fresh = true
+}
+
+// Deflate compresses data carrying on from the frames before it. reset is true
+// when it's the first frame since the deflater was made or reset which tells
+// the other side to start over with an empty dictionary too
+func (d *Deflater) Deflate(data []byte) (out []byte, reset bool, err error) {
+	d.buf.Reset()
+	if _, err := d.w.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := d.w.Flush(); err != nil {
+		return nil, false, err
+	}
+
+	out = bytes.TrimSuffix(d.buf.Bytes(), deflateSyncTail)
+	out = append([]byte(nil), out...)
+	reset = d.fresh
+	d.fresh = false
+	return out, reset, nil
+}
+
+// Inflater undoes what a Deflater did. frames have to be given to it in the
+// order they were deflated
+type Inflater struct {
+	r    io.ReadCloser
+	dict []byte
+}
+
+func NewInflater() *Inflater {
+	return &Inflater{}
+}
+
+// Reset forgets every frame inflated so far
+func (i *Inflater) Reset() {
+	i.dict = i.dict[:0]
+}
+
+// Inflate decompresses a frame that's size bytes once inflated
+func (i *Inflater) Inflate(data []byte, size int) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateSyncTail),
+		bytes.NewReader(deflateFinalTail))
+	if i.r == nil {
+		i.r = flate.NewReaderDict(src, i.dict)
+	} else if err := i.r.(flate.Resetter).Reset(src, i.dict); err != nil {
+		return nil, err
+	}
+
+	// read one byte more than we expect so a frame that's lying about its
+	// size can't make us inflate forever
+	out, err := io.ReadAll(io.LimitReader(i.r, int64(size)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInflateFailed, err)
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("%w: expected %d bytes got %d", ErrInflateFailed, size, len(out))
+	}
+
+	// the deflater can refer back to anything in its window so that's what the
+	// next frame starts off with
+	i.dict = append(i.dict, out...)
+	if len(i.dict) > deflateWindow {
+		i.dict = append(i.dict[:0], i.dict[len(i.dict)-deflateWindow:]...)
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDeflateInflate(t *testing.T) {
+	d := NewDeflater()
+	i := NewInflater()
+
+	frames := []string{
+		"\x1b[1;32muser@host\x1b[0m:~$ ls\r\n",
+		"main.go  go.mod  go.sum  README.md\r\n",
+		"\x1b[1;32muser@host\x1b[0m:~$ ls\r\n",
+		"",
+		"main.go  go.mod  go.sum  README.md\r\n",
+	}
+	for n, frame := range frames {
+		out, reset, err := d.Deflate([]byte(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reset != (n == 0) {
+			t.Fatalf("frame %d: expected reset only on the first frame", n)
+		}
+		if n == 2 && len(out) >= len(frame)/2 {
+			t.Fatalf("expected a repeated frame to take next to nothing got %d bytes", len(out))
+		}
+
+		got, err := i.Inflate(out, len(frame))
+		if err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+		if string(got) != frame {
+			t.Fatalf("frame %d: expected %q got %q", n, frame, got)
+		}
+	}
+
+	// a reset on both sides starts over with an empty dictionary
+	d.Reset()
+	i.Reset()
+	out, reset, err := d.Deflate([]byte(frames[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reset {
+		t.Fatal("expected reset after the deflater was reset")
+	}
+	if got, err := i.Inflate(out, len(frames[0])); err != nil || string(got) != frames[0] {
+		t.Fatalf("expected %q after reset got %q (%v)", frames[0], got, err)
+	}
+
+	out, _, err = d.Deflate([]byte(frames[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Inflate(out, len(frames[1])+1); !errors.Is(err, ErrInflateFailed) {
+		t.Fatalf("expected a frame lying about its size to fail got %v", err)
+	}
+}
+
+func TestInflateOutOfStep(t *testing.T) {
+	d := NewDeflater()
+	data := bytes.Repeat([]byte("abcdefgh"), 64)
+	if _, _, err := d.Deflate(data); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := d.Deflate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second frame refers back to the first which this inflater never saw
+	got, err := NewInflater().Inflate(out, len(data))
+	if err == nil && bytes.Equal(got, data) {
+		t.Fatal("expected inflating without the earlier frames to fail")
+	}
+}
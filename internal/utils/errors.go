@@ -4,14 +4,18 @@ import "errors"
 
 // server related errors
 var (
-	ErrCtxTimeOut       = errors.New("sprlmnl: context timed out")
-	ErrFailedServerAuth = errors.New("sprlmnl: client failed server auth")
-	ErrClientExchFailed = errors.New("sprlmnl: couldn't reach client after retries")
-	ErrConnectionClosed = errors.New("sprlmnl: connection closed on other side")
-	ErrWrongServer      = errors.New("sprlmnl: connected to non-superluminal server; exiting")
-	ErrDecodeFailed     = errors.New("sprlmnl: couldn't decode data")
-	ErrWrongPass        = errors.New("sprlmnl: client submitted the wrong passphrase")
-	ErrServerFull       = errors.New("sprlmnl: server is full")
+	ErrCtxTimeOut         = errors.New("sprlmnl: context timed out")
+	ErrFailedServerAuth   = errors.New("sprlmnl: client failed server auth")
+	ErrClientExchFailed   = errors.New("sprlmnl: couldn't reach client after retries")
+	ErrConnectionClosed   = errors.New("sprlmnl: connection closed on other side")
+	ErrWrongServer        = errors.New("sprlmnl: connected to non-superluminal server; exiting")
+	ErrDecodeFailed       = errors.New("sprlmnl: couldn't decode data")
+	ErrWrongPass          = errors.New("sprlmnl: client submitted the wrong passphrase")
+	ErrServerFull         = errors.New("sprlmnl: server is full")
+	ErrServerUnresponsive = errors.New("sprlmnl: server stopped responding")
+	ErrNoServerCert       = errors.New("sprlmnl: server didn't present a certificate")
+	ErrFingerprintChanged = errors.New("sprlmnl: server fingerprint doesn't match the pinned one")
+	ErrServerFailedAuth   = errors.New("sprlmnl: server couldn't prove it knows the passphrase")
 )
 
 var (
@@ -22,10 +26,33 @@ var (
 	ErrFailedAfterRetries      = errors.New("sprlmnl: couldn't send message after retries")
 	ErrUnknownHeader           = errors.New("sprlmnl: unknown server header")
 	ErrLongWait                = errors.New("sprlmnl: waited too long for input")
+	ErrConsumerTooSlow         = errors.New("sprlmnl: consumer couldn't keep up with the stream")
+	ErrApprovalDenied          = errors.New("sprlmnl: host didn't let the client in")
+	ErrApprovalTimeout         = errors.New("sprlmnl: host didn't let the client in in time")
+	ErrNoSuchClient            = errors.New("sprlmnl: no such client")
+	ErrAmbiguousClient         = errors.New("sprlmnl: more than one client goes by that name; use an id from list")
+	ErrReadOnlyClient          = errors.New("sprlmnl: client doesn't have write access")
+	ErrAlreadyRecording        = errors.New("sprlmnl: session is already being recorded")
+	ErrNotRecording            = errors.New("sprlmnl: session isn't being recorded")
+	ErrResumeFailed            = errors.New("sprlmnl: session doesn't have the client's place anymore")
+	ErrReconnectFailed         = errors.New("sprlmnl: couldn't get back into the session")
+	ErrReconnecting            = errors.New("sprlmnl: reconnecting to the session")
+	ErrClientKicked            = errors.New("sprlmnl: host removed the client from the session")
+	ErrClientBanned            = errors.New("sprlmnl: client is banned from the session")
+	ErrNoSuchWindow            = errors.New("sprlmnl: no such window")
+	ErrLastWindow              = errors.New("sprlmnl: can't close the session's last window")
+	ErrVersionMismatch         = errors.New("sprlmnl: client and session don't speak a common protocol version")
+	ErrUnsupportedVersion      = errors.New("sprlmnl: payload is from a newer protocol version")
+	ErrNotSupported            = errors.New("sprlmnl: the other side doesn't support that")
 )
 
 // payload related errors
 var (
 	ErrUnspecifiedPayload    = errors.New("sprlmnl: payload is unspecified")
 	ErrPayloadHeaderMismatch = errors.New("header and payload type passed do not match")
+	ErrCrcMismatch           = errors.New("sprlmnl: crc doesn't match")
+	ErrPakeBadMessage        = errors.New("sprlmnl: invalid key exchange message")
+	ErrDecryptFailed         = errors.New("sprlmnl: couldn't decrypt data from peer")
+	ErrBadRecording          = errors.New("sprlmnl: not an asciicast v2 recording")
+	ErrInflateFailed         = errors.New("sprlmnl: couldn't decompress frame")
 )
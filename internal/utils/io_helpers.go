@@ -76,13 +76,7 @@ func TryWriteCtx(ctx context.Context, conn net.Conn, data []byte) error {
 				return fmt.Errorf("failed after %d retries: %w", maxTries, err)
 			}
 
-			// calculate backoff with jitter
-			backoff := baseBackoff * time.Duration(1<<uint(tries))
-			if backoff > MaxBackoffTime {
-				backoff = MaxBackoffTime
-			}
-			jitter := time.Duration(rand.Int63n(int64(backoff / 4)))
-			backoff = backoff + jitter
+			backoff := Backoff(tries, baseBackoff, MaxBackoffTime)
 
 			// wait for backoff period or context cancellation
 			log.Printf("retrying write after error: %v (try %d/%d, waiting %v)",
@@ -100,6 +94,19 @@ func TryWriteCtx(ctx context.Context, conn net.Conn, data []byte) error {
 	return ErrFailedAfterRetries
 }
 
+// Backoff returns how long to wait before retrying for the given try starting at 0. the
+// wait doubles from base every try up to limit with some jitter on top so everyone
+// retrying at once doesn't stay in step
+func Backoff(try int, base, limit time.Duration) time.Duration {
+	backoff := limit
+	if try < 32 {
+		backoff = min(base*time.Duration(1<<uint(try)), limit)
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff/4) + 1))
+
+	return backoff + jitter
+}
+
 // NOTE; remember to retry the logic in the future for great UX
 func ReadFull(ctx context.Context, conn net.Conn, tracker *SyncTracker) ([]byte, error) {
 	tracker.IncrementRead()
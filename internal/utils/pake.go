@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// the key exchange is SPAKE2 (RFC 9382) over P-256. each side blinds a random
+// point with the passphrase and only someone who knows the passphrase can unblind
+// the other side's point, so the passphrase never goes over the wire and anyone
+// watching learns nothing they could check guesses against. someone pretending
+// to be either side gets exactly one guess per attempt
+
+type PakeRole int
+
+const (
+	PakeClient PakeRole = iota + 1
+	PakeServer
+)
+
+const (
+	pakeClientID = "superluminal client"
+	pakeServerID = "superluminal server"
+	pakeKeyLen   = 32
+)
+
+var (
+	pakeCurve = elliptic.P256()
+	// M and N blind the client's and server's points. nobody can know their
+	// discrete logs since they come from hashing fixed strings
+	pakeMx, pakeMy = hashToPoint("superluminal spake2 M")
+	pakeNx, pakeNy = hashToPoint("superluminal spake2 N")
+)
+
+// Pake is one side of a key exchange. it's good for a single attempt; a new one
+// is needed for every try
+type Pake struct {
+	role   PakeRole
+	w      *big.Int
+	secret *big.Int
+	msg    []byte
+}
+
+// PakeKeys is what both sides end up with when they used the same passphrase
+type PakeKeys struct {
+	// sent by each side to prove it derived the same keys
+	ClientConfirm []byte
+	ServerConfirm []byte
+	// keys for encrypting everything each side sends after the exchange
+	ClientKey []byte
+	ServerKey []byte
+}
+
+func NewPake(role PakeRole, passphrase string) (*Pake, error) {
+	params := pakeCurve.Params()
+
+	// w is the passphrase as a scalar
+	sum := sha512.Sum512([]byte("superluminal spake2 w\x00" + normalizePassphrase(passphrase)))
+	w := new(big.Int).SetBytes(sum[:])
+	w.Mod(w, params.N)
+
+	// a random scalar in [1, N-1]
+	secret, err := rand.Int(rand.Reader, new(big.Int).Sub(params.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	secret.Add(secret, big.NewInt(1))
+
+	bx, by := pakeMx, pakeMy
+	if role == PakeServer {
+		bx, by = pakeNx, pakeNy
+	}
+
+	x, y := pakeCurve.ScalarBaseMult(scalarBytes(secret))
+	wx, wy := pakeCurve.ScalarMult(bx, by, scalarBytes(w))
+	x, y = pakeCurve.Add(x, y, wx, wy)
+
+	return &Pake{
+		role:   role,
+		w:      w,
+		secret: secret,
+		msg:    elliptic.MarshalCompressed(pakeCurve, x, y),
+	}, nil
+}
+
+// Message returns what has to be sent to the other side
+func (p *Pake) Message() []byte {
+	return p.msg
+}
+
+// Finish combines the other side's message with ours into the shared keys. the
+// keys only match the other side's if both used the same passphrase which is
+// what the confirmations are for. bound is whatever else was sent in the clear
+// that both sides have to have seen the same way; it's left out when empty
+func (p *Pake) Finish(peerMsg, bound []byte) (*PakeKeys, error) {
+	px, py := elliptic.UnmarshalCompressed(pakeCurve, peerMsg)
+	if px == nil {
+		return nil, ErrPakeBadMessage
+	}
+
+	// take the passphrase's blinding off the other side's point
+	bx, by := pakeNx, pakeNy
+	if p.role == PakeServer {
+		bx, by = pakeMx, pakeMy
+	}
+	wx, wy := pakeCurve.ScalarMult(bx, by, scalarBytes(p.w))
+	wy.Sub(pakeCurve.Params().P, wy)
+	ux, uy := pakeCurve.Add(px, py, wx, wy)
+
+	kx, ky := pakeCurve.ScalarMult(ux, uy, scalarBytes(p.secret))
+	if kx.Sign() == 0 && ky.Sign() == 0 {
+		return nil, ErrPakeBadMessage
+	}
+
+	clientMsg, serverMsg := p.msg, peerMsg
+	if p.role == PakeServer {
+		clientMsg, serverMsg = peerMsg, p.msg
+	}
+
+	parts := [][]byte{
+		[]byte(pakeClientID),
+		[]byte(pakeServerID),
+		clientMsg,
+		serverMsg,
+		elliptic.MarshalCompressed(pakeCurve, kx, ky),
+		scalarBytes(p.w),
+	}
+	if len(bound) > 0 {
+		parts = append(parts, bound)
+	}
+	transcript := pakeTranscript(parts...)
+	secret := sha256.Sum256(transcript)
+
+	clientConfirmKey := deriveKey(secret[:], "client confirmation")
+	serverConfirmKey := deriveKey(secret[:], "server confirmation")
+
+	return &PakeKeys{
+		ClientConfirm: confirmation(clientConfirmKey, transcript),
+		ServerConfirm: confirmation(serverConfirmKey, transcript),
+		ClientKey:     deriveKey(secret[:], "client traffic"),
+		ServerKey:     deriveKey(secret[:], "server traffic"),
+	}, nil
+}
+
+// hashToPoint finds a point on the curve by hashing seed until the hash is a
+// valid x coordinate
+func hashToPoint(seed string) (*big.Int, *big.Int) {
+	for counter := uint32(0); ; counter++ {
+		sum := sha256.Sum256(binary.BigEndian.AppendUint32([]byte(seed), counter))
+		x, y := elliptic.UnmarshalCompressed(pakeCurve, append([]byte{0x02}, sum[:]...))
+		if x != nil {
+			return x, y
+		}
+	}
+}
+
+// pakeTranscript joins every part with its length in front so no two
+// different transcripts can look the same
+func pakeTranscript(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(part)))
+		out = append(out, part...)
+	}
+	return out
+}
+
+func deriveKey(secret []byte, info string) []byte {
+	key := make([]byte, pakeKeyLen)
+	// hkdf can only fail when asked for more than 255 hashes worth of key
+	io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key)
+	return key
+}
+
+func confirmation(key, transcript []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(transcript)
+	return mac.Sum(nil)
+}
+
+// scalarBytes returns the scalar as a fixed size big endian number
+func scalarBytes(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// ConfirmationMatches reports whether the confirmation the other side sent is
+// the one we expected, in constant time
+func ConfirmationMatches(got, want []byte) bool {
+	return hmac.Equal(got, want)
+}
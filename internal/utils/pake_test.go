@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPake(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientPass  string
+		serverPass  string
+		clientBound []byte
+		serverBound []byte
+		wantMatch   bool
+	}{
+		{"same passphrase", "correct horse battery", "correct horse battery", nil, nil, true},
+		{"surrounding whitespace is ignored", " correct horse battery\n", "correct horse battery", nil, nil, true},
+		{"wrong passphrase", "correct horse battery", "correct horse staple", nil, nil, false},
+		{"same bound data", "correct horse battery", "correct horse battery", []byte("v2"), []byte("v2"), true},
+		{"tampered bound data", "correct horse battery", "correct horse battery", []byte("v2"), []byte("v1"), false},
+		{"bound data on one side", "correct horse battery", "correct horse battery", []byte("v2"), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewPake(PakeClient, tt.clientPass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := NewPake(PakeServer, tt.serverPass)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clientKeys, err := client.Finish(server.Message(), tt.clientBound)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serverKeys, err := server.Finish(client.Message(), tt.serverBound)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ConfirmationMatches(clientKeys.ClientConfirm, serverKeys.ClientConfirm); got != tt.wantMatch {
+				t.Fatalf("expected client confirmation match %v got %v", tt.wantMatch, got)
+			}
+			if got := ConfirmationMatches(serverKeys.ServerConfirm, clientKeys.ServerConfirm); got != tt.wantMatch {
+				t.Fatalf("expected server confirmation match %v got %v", tt.wantMatch, got)
+			}
+			if got := bytes.Equal(clientKeys.ClientKey, serverKeys.ClientKey); got != tt.wantMatch {
+				t.Fatalf("expected traffic keys match %v got %v", tt.wantMatch, got)
+			}
+		})
+	}
+}
+
+func TestPakeBadMessage(t *testing.T) {
+	server, err := NewPake(PakeServer, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range [][]byte{nil, []byte("not a point"), make([]byte, 33)} {
+		if _, err := server.Finish(msg, nil); !errors.Is(err, ErrPakeBadMessage) {
+			t.Fatalf("expected a bad message error for %x got %v", msg, err)
+		}
+	}
+}
+
+func TestSecureConn(t *testing.T) {
+	clientKey := bytes.Repeat([]byte{1}, 32)
+	serverKey := bytes.Repeat([]byte{2}, 32)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	client, err := NewSecureConn(a, clientKey, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewSecureConn(b, serverKey, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// big enough to be split over several records
+	msg := bytes.Repeat([]byte("superluminal "), maxRecordSize/4)
+	go client.Write(msg)
+
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(server, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Fatal("message changed on the way through")
+	}
+
+	// a record sealed with the wrong key doesn't open
+	wrong, err := NewSecureConn(a, serverKey, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go wrong.Write([]byte("forged"))
+
+	if _, err := server.Read(got); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected a decrypt error got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// biggest chunk of plaintext sealed into a single record
+	maxRecordSize = 16 * 1024
+)
+
+// secureConn encrypts and authenticates everything written to the connection
+// with the keys from the key exchange. every record is its length followed by
+// the sealed data; the nonce is a counter so records that get replayed, dropped
+// or reordered fail to open
+type secureConn struct {
+	net.Conn
+	send    cipher.AEAD
+	recv    cipher.AEAD
+	sendSeq uint64
+	recvSeq uint64
+	// decrypted data that hasn't been read yet
+	plain []byte
+	wmu   sync.Mutex
+	rmu   sync.Mutex
+}
+
+// NewSecureConn wraps conn so everything written is sealed with sendKey and
+// everything read has to have been sealed with recvKey
+func NewSecureConn(conn net.Conn, sendKey, recvKey []byte) (net.Conn, error) {
+	send, err := chacha20poly1305.New(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := chacha20poly1305.New(recvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secureConn{Conn: conn, send: send, recv: recv}, nil
+}
+
+func (s *secureConn) Write(b []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), maxRecordSize)]
+
+		record := make([]byte, 4, 4+len(chunk)+s.send.Overhead())
+		record = s.send.Seal(record, s.nonce(s.sendSeq), chunk, nil)
+		binary.BigEndian.PutUint32(record, uint32(len(record)-4))
+		s.sendSeq += 1
+
+		if _, err := s.Conn.Write(record); err != nil {
+			return written, err
+		}
+
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+
+	return written, nil
+}
+
+func (s *secureConn) Read(b []byte) (int, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	if len(s.plain) == 0 {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(s.Conn, header); err != nil {
+			return 0, err
+		}
+
+		recordLen := binary.BigEndian.Uint32(header)
+		if recordLen > maxRecordSize+uint32(s.recv.Overhead()) {
+			return 0, fmt.Errorf("%w: record of %d bytes is too big", ErrDecryptFailed, recordLen)
+		}
+
+		record := make([]byte, recordLen)
+		if _, err := io.ReadFull(s.Conn, record); err != nil {
+			return 0, err
+		}
+
+		plain, err := s.recv.Open(record[:0], s.nonce(s.recvSeq), record, nil)
+		if err != nil {
+			return 0, ErrDecryptFailed
+		}
+		s.recvSeq += 1
+		s.plain = plain
+	}
+
+	n := copy(b, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+func (s *secureConn) nonce(seq uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[chacha20poly1305.NonceSize-8:], seq)
+	return nonce
+}
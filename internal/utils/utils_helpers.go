@@ -44,6 +44,11 @@ func PrependLength(payload []byte) []byte {
 	return message
 }
 
+// RLEncode follows every byte that repeats with how many times it does. a run
+// can't be told apart from digits that were in data to begin with so nothing
+// sent over the wire uses it
+//
+// Deprecated: terminal frames are compressed with a Deflater
 func RLEncode(data []byte) []byte {
 	if len(data) == 0 {
 		return nil
@@ -79,6 +84,9 @@ func RLEncode(data []byte) []byte {
 	return encoded
 }
 
+// RLDecode undoes RLEncode as long as data had no digits in it
+//
+// Deprecated: terminal frames are decompressed with an Inflater
 func RLDecode(data []byte) []byte {
 	if len(data) == 0 {
 		return nil
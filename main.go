@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 	"willofdaedalus/superluminal/internal/backend"
 	"willofdaedalus/superluminal/internal/client"
+	"willofdaedalus/superluminal/internal/pipeline"
 
 	"golang.org/x/term"
 )
@@ -15,11 +20,90 @@ import (
 var (
 	startServer       bool
 	defaultConnection string
+	overflowPolicy    string
+	useTLS            bool
+	certFile          string
+	keyFile           string
+	knownHostsFile    string
+	requireApproval   bool
+	approvalTimeout   time.Duration
+	altScreen         bool
+	recordFile        string
+	recordInput       bool
+	resumeGrace       time.Duration
+	redact            bool
+	redactPatterns    []string
+	scrollbackLines   int
+	compress          bool
+	batchWindow       time.Duration
+	maxFPS            int
+	// what the session shares; everything after -- is the command to run
+	commandDir   string
+	commandEnv   []string
+	commandUnset []string
+	commandTerm  string
+	// something already running to share instead of a command
+	tmuxTarget string
+	mirrorPath string
+	// how the client wants the session sent to it and shown
+	screenDiffs bool
+	transcript  bool
 )
 
 func init() {
 	flag.StringVar(&defaultConnection, "c", "", "the host and port to connect to")
 	flag.BoolVar(&startServer, "s", false, "start a superluminal session server")
+	flag.StringVar(&overflowPolicy, "overflow", "snapshot",
+		"what to do with clients that can't keep up (snapshot, drop, coalesce or disconnect)")
+	flag.BoolVar(&useTLS, "tls", false, "encrypt the session with tls")
+	flag.StringVar(&certFile, "cert", "", "certificate for the session (generated if missing)")
+	flag.StringVar(&keyFile, "key", "", "private key for the session certificate (generated if missing)")
+	flag.StringVar(&knownHostsFile, "known-hosts", "", "file of pinned session fingerprints")
+	flag.BoolVar(&altScreen, "alt-screen", false, "show the session in the terminal's alternate screen")
+	flag.BoolVar(&screenDiffs, "diff", false, "be sent changes to the screen instead of the raw output (for slow links)")
+	flag.BoolVar(&transcript, "transcript", false,
+		"print the session as plain text without any escape sequences (for screen readers and files)")
+	flag.BoolVar(&requireApproval, "approve", true, "clients need the host's approval to join")
+	flag.DurationVar(&approvalTimeout, "approve-timeout", 2*time.Minute,
+		"how long clients wait for approval before they're turned away")
+	flag.DurationVar(&resumeGrace, "resume-grace", 2*time.Minute,
+		"how long a dropped client's place is held for it to come back (0 to not hold it)")
+	flag.StringVar(&recordFile, "record", "", "record the session to an asciicast file")
+	flag.BoolVar(&recordInput, "record-input", false, "include what's typed in recordings")
+	flag.BoolVar(&redact, "redact", false, "mask secrets like tokens and keys in what clients see")
+	flag.Func("redact-pattern", "a regex of something to mask in what clients see instead of the "+
+		"built in secrets (can be repeated)", func(pattern string) error {
+		redactPatterns = append(redactPatterns, pattern)
+		return nil
+	})
+	flag.IntVar(&scrollbackLines, "scrollback", pipeline.DefaultScrollback,
+		"lines that scrolled off the screen kept for clients to look back at (0 keeps none)")
+	flag.BoolVar(&compress, "compress", true, "deflate terminal output for clients that can take it")
+	flag.DurationVar(&batchWindow, "batch", pipeline.DefaultBatchWindow,
+		"how long output is held back to go out with what comes right after it (0 to not hold it)")
+	flag.IntVar(&maxFPS, "max-fps", pipeline.DefaultFrameRate, "most frames a second sent to each client (0 for no limit)")
+	flag.StringVar(&commandDir, "dir", "", "directory the shared command starts in")
+	flag.Func("env", "KEY=VALUE to set for the shared command (can be repeated)", func(kv string) error {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("expected KEY=VALUE")
+		}
+		commandEnv = append(commandEnv, kv)
+		return nil
+	})
+	flag.Func("unset", "environment variable to hide from the shared command (can be repeated)", func(name string) error {
+		commandUnset = append(commandUnset, name)
+		return nil
+	})
+	flag.StringVar(&commandTerm, "term", "", "TERM for the shared command (defaults to this terminal's)")
+	flag.StringVar(&tmuxTarget, "tmux", "", "share a pane of a running tmux session (session, window or pane)")
+	flag.StringVar(&mirrorPath, "mirror", "", "share the terminal output being written to a file or fifo")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: superluminal [flags] [host:port]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       superluminal -s [flags] [-- command [args...]]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       superluminal -s -tmux <target> | -mirror <file> [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       superluminal play [flags] <file>")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 }
 
@@ -35,6 +119,114 @@ func validateClientNum(in string) error {
 	return err
 }
 
+// runSession applies the server flags to session and runs it until it ends. It
+// returns the exit status of the session's program
+func runSession(session *backend.Session) int {
+	policy, err := pipeline.ParseOverflowPolicy(overflowPolicy)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	session.SetOverflowPolicy(policy)
+	session.SetApprovalRequired(requireApproval)
+	session.SetApprovalTimeout(approvalTimeout)
+	session.SetResumeGrace(resumeGrace)
+	session.SetScrollback(scrollbackLines)
+	session.SetCompression(compress)
+	session.SetBatching(batchWindow)
+	session.SetFrameRate(maxFPS)
+
+	if useTLS {
+		fingerprint, err := session.EnableTLS(certFile, keyFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		// clients pin this the first time they connect and are told to check it matches
+		fmt.Println("session fingerprint:", fingerprint)
+	}
+
+	if redact || len(redactPatterns) > 0 {
+		if err := session.RedactSecrets(redactPatterns...); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	session.SetRecordInput(recordInput)
+	if recordFile != "" {
+		if err := session.StartRecording(recordFile); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		panic(err)
+	}
+	defer term.Restore(int(os.Stdin.Fd()), oldState)
+	session.Start()
+
+	status, _ := session.GetExitStatus()
+	return status
+}
+
+// playRecording plays an asciicast recording in this terminal or serves it to
+// clients like a live session
+func playRecording(args []string) error {
+	playFlags := flag.NewFlagSet("play", flag.ExitOnError)
+	speed := playFlags.Float64("speed", 1, "how many times faster than it was recorded")
+	idleLimit := playFlags.Duration("idle-limit", 0, "longest pause between events (0 keeps the recorded pauses)")
+	paused := playFlags.Bool("paused", false, "wait for space before starting")
+	serve := playFlags.Bool("serve", false, "stream the recording to clients instead of playing it here")
+	playFlags.Usage = func() {
+		fmt.Fprintln(playFlags.Output(), "usage: superluminal play [flags] <file>")
+		fmt.Fprintln(playFlags.Output(), "keys: space pauses, left/right seek, +/- change the speed, q quits")
+		playFlags.PrintDefaults()
+	}
+	playFlags.Parse(args)
+
+	if playFlags.NArg() != 1 {
+		playFlags.Usage()
+		os.Exit(2)
+	}
+
+	cast, err := pipeline.LoadCast(playFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	opts := pipeline.PlaybackOptions{
+		Speed:     *speed,
+		IdleLimit: *idleLimit,
+		Paused:    *paused,
+	}
+
+	if *serve {
+		session, err := backend.NewPlaybackSession("hello", 5, cast, opts)
+		if err != nil {
+			return err
+		}
+		runSession(session)
+		return nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			os.Stdout.WriteString("\x1b[0m\x1b[?25h\r\n")
+			term.Restore(fd, oldState)
+		}()
+	}
+
+	player := pipeline.NewPlayer(cast, os.Stdout, nil, opts)
+	go io.Copy(player, os.Stdin)
+
+	return player.Play(context.Background())
+}
+
 // TODO; remember to disable signal processing for bubbletea
 func main() {
 	// model, err := ui.NewModel(startServer)
@@ -48,26 +240,62 @@ func main() {
 	// 	log.Fatal(err)
 	// }
 
-	if startServer {
-		session, err := backend.NewSession("hello", 5)
-		if err != nil {
+	if !startServer && flag.Arg(0) == "play" {
+		if err := playRecording(flag.Args()[1:]); err != nil {
 			log.Fatal(err.Error())
 		}
+		return
+	}
 
-		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if startServer {
+		var session *backend.Session
+		var err error
+		switch {
+		case tmuxTarget != "" && mirrorPath != "":
+			log.Fatal("-tmux and -mirror can't be used together")
+		case tmuxTarget != "":
+			session, err = backend.NewTmuxSession("hello", 5, tmuxTarget)
+		case mirrorPath != "":
+			session, err = backend.NewMirrorSession("hello", 5, mirrorPath)
+		default:
+			cmd := pipeline.Command{
+				Args:  flag.Args(),
+				Dir:   commandDir,
+				Env:   commandEnv,
+				Unset: commandUnset,
+				Term:  commandTerm,
+			}
+			session, err = backend.NewCommandSession("hello", 5, cmd)
+		}
 		if err != nil {
-			panic(err)
+			log.Fatal(err.Error())
 		}
-		defer term.Restore(int(os.Stdin.Fd()), oldState)
-		session.Start()
-
+		// the host leaves the same way the shared program did
+		os.Exit(runSession(session))
 	} else {
 		errChan := make(chan error, 1)
 		client := client.New("hello")
+		client.UseAltScreen(altScreen)
+		if screenDiffs {
+			client.UseScreenDiffs()
+		}
+		if transcript {
+			client.UseTranscript()
+		}
+		// the terminal goes into raw mode once we're in the session
+		defer client.RestoreTerminal()
 		addr := "localhost:42024"
 
-		if len(os.Args) > 1 {
-			addr = os.Args[1]
+		if defaultConnection != "" {
+			addr = defaultConnection
+		} else if flag.NArg() > 0 {
+			addr = flag.Arg(0)
+		}
+
+		if useTLS {
+			if err := client.UseTLS(knownHostsFile); err != nil {
+				log.Fatal(err.Error())
+			}
 		}
 
 		// err := client.ConnectToSession("localhost", "42024")
@@ -1,7 +1,7 @@
 #!/bin/bash
 
 # Create necessary directories
-mkdir -p internal/payload/{auth,base,error,heartbeat,term,info}
+mkdir -p internal/payload/{auth,base,error,heartbeat,term,info,resend,resize,input,window,scrollback,screen}
 
 # First, create individual proto files in a protos directory
 mkdir -p protos